@@ -1,15 +1,28 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"errors"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
 	"golang.org/x/oauth2"
 
+	"github.com/to-dy/music-playlist-converter/api/services/applemusic"
+	"github.com/to-dy/music-playlist-converter/api/services/deezer"
+	"github.com/to-dy/music-playlist-converter/api/services/jellyfin"
+	"github.com/to-dy/music-playlist-converter/api/services/local"
+	"github.com/to-dy/music-playlist-converter/api/services/plex"
+	"github.com/to-dy/music-playlist-converter/api/services/soundcloud"
 	"github.com/to-dy/music-playlist-converter/api/services/spotify"
+	"github.com/to-dy/music-playlist-converter/api/services/subsonic"
+	"github.com/to-dy/music-playlist-converter/api/services/tidal"
 	"github.com/to-dy/music-playlist-converter/api/services/youtube"
 	"github.com/to-dy/music-playlist-converter/api/stores/session"
 )
@@ -17,6 +30,17 @@ import (
 const (
 	SPOTIFY_AUTH_STATE = "spotify_auth_state"
 	YOUTUBE_AUTH_STATE = "youtube_auth_state"
+	DEEZER_AUTH_STATE  = "deezer_auth_state"
+
+	SOUNDCLOUD_AUTH_STATE = "soundcloud_auth_state"
+	// soundcloud requires PKCE, the code verifier is kept until the callback
+	SOUNDCLOUD_CODE_VERIFIER = "soundcloud_code_verifier"
+)
+
+// seconds, RFC 8628 defaults the poll interval to 5 seconds and raises it by 5 seconds on slow_down
+const (
+	tidalDefaultPollInterval = 5
+	tidalSlowDownIncrement   = 5
 )
 
 func InitiateOAuthFlow(c *fiber.Ctx) error {
@@ -40,7 +64,7 @@ func InitiateOAuthFlow(c *fiber.Ctx) error {
 
 		url := spotify.OauthConfig.AuthCodeURL(state)
 
-		sess.Set(session.ConvertTo, "spotify")
+		sess.Set(session.ConvertTo, spotify.ProviderName)
 		sess.Save()
 
 		return c.Redirect(url, fiber.StatusTemporaryRedirect)
@@ -53,7 +77,44 @@ func InitiateOAuthFlow(c *fiber.Ctx) error {
 
 		url := youtube.OauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
 
-		sess.Set(session.ConvertTo, "youtube")
+		sess.Set(session.ConvertTo, youtube.ProviderName)
+		sess.Save()
+
+		return c.Redirect(url, fiber.StatusTemporaryRedirect)
+
+	case "/deezer":
+		c.Cookie(&fiber.Cookie{
+			Name:  DEEZER_AUTH_STATE,
+			Value: state,
+		})
+
+		url := deezer.AuthCodeURL(state)
+
+		sess.Set(session.ConvertTo, deezer.ProviderName)
+		sess.Save()
+
+		return c.Redirect(url, fiber.StatusTemporaryRedirect)
+
+	case "/soundcloud":
+		verifier, url, err := soundcloud.AuthCodeURL(state)
+
+		if err != nil {
+			log.Println("Error creating soundcloud code verifier - " + err.Error())
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		c.Cookie(&fiber.Cookie{
+			Name:  SOUNDCLOUD_AUTH_STATE,
+			Value: state,
+		})
+
+		c.Cookie(&fiber.Cookie{
+			Name:     SOUNDCLOUD_CODE_VERIFIER,
+			Value:    verifier,
+			HTTPOnly: true,
+		})
+
+		sess.Set(session.ConvertTo, soundcloud.ProviderName)
 		sess.Save()
 
 		return c.Redirect(url, fiber.StatusTemporaryRedirect)
@@ -132,7 +193,397 @@ func HandleOAuthCallback(c *fiber.Ctx) error {
 
 		return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?success=true")
 
+	case "/deezer_callback":
+		storedState := c.Cookies(DEEZER_AUTH_STATE)
+
+		// deezer reports a denied authorization with error_reason
+		if reason := c.Query("error_reason"); reason != "" {
+			return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?error=" + url.QueryEscape(reason))
+		}
+
+		if state == "" || state != storedState {
+			return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?error=state-mismatch")
+		}
+
+		c.ClearCookie(DEEZER_AUTH_STATE)
+
+		token, err := deezer.Exchange(code)
+
+		if err != nil {
+			return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?error=" + url.QueryEscape(err.Error()))
+		}
+
+		deezer.StoreAuthCodeToken(token, sess.ID())
+
+		sess.Set(session.AuthCodeToken, token.AccessToken)
+		sess.Save()
+
+		return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?success=true")
+
+	case "/soundcloud_callback":
+		storedState := c.Cookies(SOUNDCLOUD_AUTH_STATE)
+		verifier := c.Cookies(SOUNDCLOUD_CODE_VERIFIER)
+
+		if authError != "" {
+			return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?error=" + authError)
+		}
+
+		if state == "" || state != storedState {
+			return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?error=state-mismatch")
+		}
+
+		c.ClearCookie(SOUNDCLOUD_AUTH_STATE, SOUNDCLOUD_CODE_VERIFIER)
+
+		token, err := soundcloud.Exchange(c.Context(), code, verifier)
+
+		if err != nil {
+			return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?error=" + err.Error())
+		}
+
+		soundcloud.StoreAuthCodeToken(token, sess.ID())
+
+		sess.Set(session.AuthCodeToken, token.AccessToken)
+		sess.Save()
+
+		return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?success=true")
+
 	default:
 		return c.Redirect(os.Getenv("UI_BASE_URL") + "/auth?error=unsupported-callback")
 	}
 }
+
+/*
+apple music has no oauth redirect flow, the client authorizes the user with MusicKit JS
+using the developer token returned here and sends the Music User Token back to AppleMusicCallback
+*/
+func InitiateAppleMusicAuth(c *fiber.Ctx) error {
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	developerToken, err := applemusic.DeveloperToken()
+
+	if err != nil {
+		log.Println("Error signing apple music developer token - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	sess.Set(session.ConvertTo, applemusic.ProviderName)
+	sess.Save()
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: map[string]interface{}{
+		"developerToken": developerToken,
+	}})
+}
+
+// stores the Music User Token returned by MusicKit JS for the session
+func AppleMusicCallback(c *fiber.Ctx) error {
+	c.Accepts(fiber.MIMEApplicationJSON)
+
+	bodyData := struct {
+		MusicUserToken string `json:"musicUserToken"`
+	}{}
+
+	if err := c.BodyParser(&bodyData); err != nil {
+		log.Println("Error parsing body - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	if bodyData.MusicUserToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("musicUserToken is required", &ErrorSource{Parameter: "musicUserToken"})},
+		})
+	}
+
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	applemusic.StoreUserToken(bodyData.MusicUserToken, sess.ID())
+
+	sess.Set(session.AuthCodeToken, bodyData.MusicUserToken)
+	sess.Save()
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+/*
+starts the tidal device login, the client shows userCode and verificationUri to the user
+then polls TidalCallback every `interval` seconds until the login is approved
+*/
+func InitiateTidalAuth(c *fiber.Ctx) error {
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	authorization, err := tidal.StartDeviceAuthorization()
+
+	if err != nil {
+		log.Println("Error starting tidal device authorization - " + err.Error())
+		return c.SendStatus(fiber.StatusBadGateway)
+	}
+
+	// the device code stays on the server, it is all that is needed to get the token
+	interval := authorization.Interval
+	if interval <= 0 {
+		interval = tidalDefaultPollInterval
+	}
+
+	sess.Set(session.TidalDeviceCode, authorization.DeviceCode)
+	sess.Set(session.TidalPollInterval, interval)
+	sess.Delete(session.TidalLastPoll)
+	sess.Set(session.ConvertTo, tidal.ProviderName)
+	sess.Save()
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: map[string]interface{}{
+		"userCode":                authorization.UserCode,
+		"verificationUri":         authorization.VerificationUri,
+		"verificationUriComplete": authorization.VerificationUriComplete,
+		"expiresIn":               authorization.ExpiresIn,
+		"interval":                interval,
+	}})
+}
+
+/*
+polls the pending tidal device login of the session, responds 202 until the user approved it.
+polls made before the interval passed are answered without asking tidal, the interval grows
+when tidal asks to slow down (RFC 8628 section 3.5) and is returned so the client backs off
+*/
+func TidalCallback(c *fiber.Ctx) error {
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	deviceCode, ok := sess.Get(session.TidalDeviceCode).(string)
+
+	if !ok || deviceCode == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("no pending TIDAL login, start it with /api/auth/tidal", &ErrorSource{})},
+		})
+	}
+
+	interval, ok := sess.Get(session.TidalPollInterval).(int)
+	if !ok || interval <= 0 {
+		interval = tidalDefaultPollInterval
+	}
+
+	now := time.Now().Unix()
+
+	if lastPoll, ok := sess.Get(session.TidalLastPoll).(int64); ok && now-lastPoll < int64(interval) {
+		return tidalPending(c, "pending", interval)
+	}
+
+	sess.Set(session.TidalLastPoll, now)
+
+	token, err := tidal.PollDeviceToken(deviceCode)
+
+	switch {
+	case errors.Is(err, tidal.ErrSlowDown):
+		interval += tidalSlowDownIncrement
+		sess.Set(session.TidalPollInterval, interval)
+		sess.Save()
+
+		return tidalPending(c, "slow_down", interval)
+
+	case errors.Is(err, tidal.ErrAuthorizationPending):
+		sess.Save()
+
+		return tidalPending(c, "pending", interval)
+
+	case errors.Is(err, tidal.ErrDeviceCodeExpired) || errors.Is(err, tidal.ErrAccessDenied):
+		sess.Delete(session.TidalDeviceCode)
+		sess.Save()
+
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError(err.Error(), &ErrorSource{})},
+		})
+
+	case err != nil:
+		log.Println("Error polling tidal device token - " + err.Error())
+		return c.SendStatus(fiber.StatusBadGateway)
+	}
+
+	tidal.StoreAuthCodeToken(token, sess.ID())
+
+	sess.Delete(session.TidalDeviceCode)
+	sess.Delete(session.TidalPollInterval)
+	sess.Delete(session.TidalLastPoll)
+	sess.Set(session.AuthCodeToken, token.AccessToken)
+	sess.Save()
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: map[string]interface{}{
+		"status": "authorized",
+	}})
+}
+
+// the client waits interval seconds before polling again, also sent as Retry-After
+func tidalPending(c *fiber.Ctx, status string, interval int) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(interval))
+
+	return c.Status(fiber.StatusAccepted).JSON(&ApiOkResponse{Data: map[string]interface{}{
+		"status":   status,
+		"interval": interval,
+	}})
+}
+
+/*
+subsonic servers have no oauth, the user logs in with the username and password of the server account.
+only the salted token is kept for the session
+*/
+func SubsonicLogin(c *fiber.Ctx) error {
+	c.Accepts(fiber.MIMEApplicationJSON)
+
+	bodyData := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{}
+
+	if err := c.BodyParser(&bodyData); err != nil {
+		log.Println("Error parsing body - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	if bodyData.Username == "" || bodyData.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("username and password are required", &ErrorSource{Parameter: "username"})},
+		})
+	}
+
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	err = subsonic.Login(bodyData.Username, bodyData.Password, sess.ID())
+
+	if errors.Is(err, subsonic.ErrWrongCredentials) {
+		return c.Status(fiber.StatusUnauthorized).JSON(ApiErrorResponse{
+			Errors: Errors{&ErrorObject{
+				Status: fiber.StatusUnauthorized,
+				Title:  "Unauthorized",
+				Detail: err.Error(),
+				Source: &ErrorSource{},
+			}},
+		})
+	}
+
+	if err != nil {
+		log.Println("Error logging in to subsonic - " + err.Error())
+		return c.SendStatus(fiber.StatusBadGateway)
+	}
+
+	sess.Set(session.ConvertTo, subsonic.ProviderName)
+	sess.Set(session.AuthCodeToken, bodyData.Username)
+	sess.Save()
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+/*
+jellyfin and plex are read and written with the server credentials from the environment, the local music folder
+is read from LOCAL_MUSIC_DIR. only the operator holding MEDIA_SERVER_TOKEN may connect, connecting checks the server or folder is reachable and selects it as the conversion target of the session
+*/
+func ConnectMediaServer(c *fiber.Ctx) error {
+	if checkErr := checkMediaServerToken(c); checkErr != nil {
+		return checkErr()
+	}
+
+	path := c.Path()
+	path = strings.TrimPrefix(path, "/api/auth")
+
+	var providerName string
+	var ping func() error
+
+	switch path {
+	case "/jellyfin":
+		providerName = jellyfin.ProviderName
+		ping = jellyfin.Ping
+
+	case "/plex":
+		providerName = plex.ProviderName
+		ping = plex.Ping
+
+	case "/local":
+		providerName = local.ProviderName
+		ping = local.Ping
+
+	default:
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	if err := ping(); err != nil {
+		log.Println("Error connecting to " + providerName + " - " + err.Error())
+		return c.SendStatus(fiber.StatusBadGateway)
+	}
+
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	sess.Set(session.ConvertTo, providerName)
+	// there is no user token, the session only needs to be marked as authorized
+	sess.Set(session.AuthCodeToken, providerName)
+	sess.Set(session.MediaServerAccess, true)
+	sess.Save()
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+/*
+the media servers are used with the server credentials, they are disabled until MEDIA_SERVER_TOKEN is set
+and the request sends it as a bearer token in the Authorization header
+*/
+func checkMediaServerToken(c *fiber.Ctx) func() error {
+	token := os.Getenv("MEDIA_SERVER_TOKEN")
+
+	if token == "" {
+		return func() error {
+			return c.Status(fiber.StatusForbidden).JSON(ApiErrorResponse{
+				Errors: Errors{&ErrorObject{
+					Status: fiber.StatusForbidden,
+					Title:  "Forbidden",
+					Detail: "Media servers are disabled, set MEDIA_SERVER_TOKEN to enable them",
+					Source: &ErrorSource{},
+				}},
+			})
+		}
+	}
+
+	bearer := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+
+	if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+		return func() error {
+			return c.Status(fiber.StatusUnauthorized).JSON(ApiErrorResponse{
+				Errors: Errors{&ErrorObject{
+					Status: fiber.StatusUnauthorized,
+					Title:  "Unauthorized",
+					Detail: "A valid media server token is required",
+					Source: &ErrorSource{Parameter: fiber.HeaderAuthorization},
+				}},
+			})
+		}
+	}
+
+	return nil
+}
+
+// playlists of the media servers are read with the server credentials, only sessions connected by the operator may read them
+func isMediaServer(providerName string) bool {
+	switch providerName {
+	case jellyfin.ProviderName, plex.ProviderName, local.ProviderName:
+		return true
+	}
+
+	return false
+}
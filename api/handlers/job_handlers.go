@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/converter"
+	"github.com/to-dy/music-playlist-converter/api/services/formats"
+	"github.com/to-dy/music-playlist-converter/api/services/jobs"
+	"github.com/to-dy/music-playlist-converter/api/services/local"
+	"github.com/to-dy/music-playlist-converter/api/stores/session"
+)
+
+// comment sent on idle streams so proxies don't close them
+const heartbeatInterval = 15 * time.Second
+
+// returns the status, per track results and created playlist url of a conversion job
+func GetJob(c *fiber.Ctx) error {
+	job, handleJobErr := getSessionJob(c, c.Params("id"))
+	if handleJobErr != nil {
+		return handleJobErr()
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: job.Snapshot()})
+}
+
+/*
+reports the tracks of the source playlist missing on the target,
+e.g the tracks to get before a playlist converted to a media server is complete
+*/
+func GetJobMissingTracks(c *fiber.Ctx) error {
+	job, handleJobErr := getSessionJob(c, c.Params("id"))
+	if handleJobErr != nil {
+		return handleJobErr()
+	}
+
+	missing := job.MissingTracks()
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: map[string]interface{}{
+		"status":        job.Status(),
+		"target":        job.Request.Target,
+		"missingTracks": missing,
+		"missingCount":  len(missing),
+	}})
+}
+
+// columns of a conversion report, per track of the source playlist
+var reportHeaders = []string{
+	"Position", "Status", "Strategy", "Confidence", "Added",
+	"Track Name", "Artist Name(s)", "Album Name", "Duration (ms)", "ISRC", "Track URL",
+	"Match Track Name", "Match Artist Name(s)", "Match Album Name", "Match Duration (ms)", "Match ISRC", "Match Track URL",
+}
+
+/*
+exports the per track results of a conversion job as a csv file,
+tracks not searched yet are left out
+*/
+func GetJobReport(c *fiber.Ctx) error {
+	if format := c.Query("format", "csv"); format != "csv" {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("format must be csv", &ErrorSource{Parameter: "?format"})},
+		})
+	}
+
+	job, handleJobErr := getSessionJob(c, c.Params("id"))
+	if handleJobErr != nil {
+		return handleJobErr()
+	}
+
+	snapshot := job.Snapshot()
+	source, sourceOk := services.GetProvider(snapshot.Source)
+	target, targetOk := services.GetProvider(snapshot.Target)
+
+	if !sourceOk || !targetOk {
+		log.Println("unknown provider of job " + snapshot.Id)
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(reportHeaders)
+
+	for index, track := range snapshot.Tracks {
+		if track == nil {
+			continue
+		}
+
+		row := []string{
+			strconv.Itoa(index + 1), string(track.Status), string(track.Strategy),
+			strconv.FormatFloat(track.Confidence, 'f', 3, 64), strconv.FormatBool(track.Added),
+		}
+
+		row = append(row, reportColumns(source, track.Source)...)
+		row = append(row, reportColumns(target, track.Match)...)
+
+		writer.Write(row)
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		log.Println("Error writing report - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	setAttachment(c, formats.SafeFileName(snapshot.Title+" report")+".csv")
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// name, artists, album, duration, ISRC and url of a track, empty columns when there is no track
+func reportColumns(provider services.Provider, track *services.SearchTrack) []string {
+	if track == nil {
+		return []string{"", "", "", "", "", ""}
+	}
+
+	entry := trackEntry(provider, track)
+
+	duration := ""
+	if entry.Duration > 0 {
+		duration = strconv.FormatInt(entry.Duration, 10)
+	}
+
+	return []string{entry.Title, entry.Artist, entry.Album, duration, entry.ISRC, entry.Location}
+}
+
+/*
+exports the tracks a conversion job matched on the target as a playlist file e.g a rekordbox xml or traktor nml,
+the matches are looked up in the local music folder when one is configured and the session has media server access,
+so DJ software gets the files to play
+*/
+func ExportJobPlaylist(c *fiber.Ctx) error {
+	format, ok := formats.GetFormat(c.Query("format"))
+
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("format must be one of "+strings.Join(formats.FormatNames(), ", "), &ErrorSource{Parameter: "?format"})},
+		})
+	}
+
+	job, handleJobErr := getSessionJob(c, c.Params("id"))
+	if handleJobErr != nil {
+		return handleJobErr()
+	}
+
+	snapshot := job.Snapshot()
+	target, targetOk := services.GetProvider(snapshot.Target)
+
+	if !targetOk {
+		log.Println("unknown provider of job " + snapshot.Id)
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// matches of conversions to the music folder are its files already,
+	// other matches are only looked up for sessions connected with MEDIA_SERVER_TOKEN as the export holds server paths
+	resolveLocal := target.Name() != local.ProviderName && sess.Get(session.MediaServerAccess) == true && local.Ping() == nil
+	localFiles := 0
+
+	playlist := &formats.Playlist{Title: snapshot.Title, Entries: []*formats.Entry{}}
+
+	for _, track := range snapshot.Tracks {
+		if track == nil || track.Status != converter.TrackFound || track.Match == nil {
+			continue
+		}
+
+		entry := trackEntry(target, track.Match)
+
+		if target.Name() == local.ProviderName {
+			localFiles++
+		} else if resolveLocal {
+			path, found, err := local.ResolveFile(track.Match)
+
+			if err != nil {
+				log.Println("Error resolving local file - " + err.Error())
+			}
+
+			if found {
+				entry.Location = path
+				localFiles++
+			}
+		}
+
+		playlist.Entries = append(playlist.Entries, entry)
+	}
+
+	var buf bytes.Buffer
+
+	// sent with the conflict too, so the client can tell why nothing could be exported
+	c.Set("X-Local-Files", strconv.Itoa(localFiles))
+
+	if err := format.Encode(&buf, playlist); err != nil {
+		if errors.Is(err, formats.ErrNoLocalFiles) {
+			return noLocalFilesResponse(c, format)
+		}
+
+		log.Println("Error encoding " + format.Name() + " playlist - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	setAttachment(c, formats.SafeFileName(playlist.Title)+format.Extensions()[0])
+	c.Set(fiber.HeaderContentType, format.ContentType())
+
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+/*
+SSE handler
+streams the events of a conversion job, events emitted before subscribing are sent first
+*/
+func StreamJob(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+
+	job, handleJobErr := getSessionJob(c, c.Params("id"))
+	if handleJobErr != nil {
+		return handleJobErr()
+	}
+
+	return streamJob(c, job)
+}
+
+// stops the conversion before its next track, the tracks added so far stay on the created playlist
+func CancelJob(c *fiber.Ctx) error {
+	return controlJob(c, jobs.GlobalManager.Cancel)
+}
+
+// pauses the conversion before its next track
+func PauseJob(c *fiber.Ctx) error {
+	return controlJob(c, jobs.GlobalManager.Pause)
+}
+
+func ResumeJob(c *fiber.Ctx) error {
+	return controlJob(c, jobs.GlobalManager.Resume)
+}
+
+// applies action to the job of the session, responds with the updated job or 409 if the job is not in a state allowing it
+func controlJob(c *fiber.Ctx, action func(job *jobs.Job) error) error {
+	job, handleJobErr := getSessionJob(c, c.Params("id"))
+	if handleJobErr != nil {
+		return handleJobErr()
+	}
+
+	if err := action(job); err != nil {
+		return c.Status(fiber.StatusConflict).JSON(ApiErrorResponse{
+			Errors: Errors{&ErrorObject{
+				Status: fiber.StatusConflict,
+				Title:  "Conflict",
+				Detail: err.Error(),
+				Source: &ErrorSource{Parameter: "id"},
+			}},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: job.Snapshot()})
+}
+
+// JSON Schema of the conversion event payloads
+func GetEventsSchema(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "application/schema+json")
+
+	return c.Status(fiber.StatusOK).Send(converter.EventsSchema)
+}
+
+// jobs are only visible to the session that started them
+func getSessionJob(c *fiber.Ctx, id string) (*jobs.Job, func() error) {
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return nil, func() error {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	job, found := jobs.GlobalManager.Get(id)
+
+	if !found || job.Request.SessionId != sess.ID() {
+		return nil, func() error {
+			return c.Status(fiber.StatusNotFound).JSON(ApiErrorResponse{
+				Errors: Errors{&ErrorObject{
+					Status: fiber.StatusNotFound,
+					Title:  "Not Found",
+					Detail: "conversion job not found",
+					Source: &ErrorSource{Parameter: "id"},
+				}},
+			})
+		}
+	}
+
+	return job, nil
+}
+
+/*
+the job keeps running if the client disconnects
+
+a client reconnecting with the Last-Event-ID header, or the lastEventId query parameter, only receives the events it missed
+*/
+func streamJob(c *fiber.Ctx, job *jobs.Job) error {
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+
+	lastEventId := lastEventID(c)
+
+	c.Response().SetBodyStreamWriter(func(w *bufio.Writer) {
+		history, events, unsubscribe := job.Subscribe(lastEventId)
+		defer unsubscribe()
+
+		for _, event := range history {
+			if err := streamEvent(w, event.Id, event.Name, event.Data); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+
+				if err := streamEvent(w, event.Id, event.Name, event.Data); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if err := streamHeartbeat(w); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// 0 when the client is not reconnecting
+func lastEventID(c *fiber.Ctx) int64 {
+	value := c.Get("Last-Event-ID")
+
+	if value == "" {
+		value = c.Query("lastEventId")
+	}
+
+	if value == "" {
+		return 0
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil || id < 0 {
+		log.Println("invalid Last-Event-ID - " + value)
+		return 0
+	}
+
+	return id
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/jobs"
+	"github.com/to-dy/music-playlist-converter/api/services/local"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/api/stores/session"
+)
+
+// provider with a single track, its searches find the searched track
+type echoProvider struct{}
+
+func (p *echoProvider) Name() string                                  { return "test-echo" }
+func (p *echoProvider) DisplayName() string                           { return "Echo" }
+func (p *echoProvider) Hosts() []string                               { return []string{} }
+func (p *echoProvider) ResolvePlaylistURL(u *url.URL) (string, error) { return "", nil }
+func (p *echoProvider) PlaylistURL(id string) string                  { return id }
+
+func (p *echoProvider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	return nil, nil
+}
+
+func (p *echoProvider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	return services.SearchTrackList{
+		{Id: "1", Title: "Title", Artists: shared_types.Artists{{Name: "Artist"}}},
+	}, false, nil
+}
+
+func (p *echoProvider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	return services.SearchTrackList{track}, nil
+}
+
+func (p *echoProvider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return "created", nil
+}
+
+func (p *echoProvider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	return nil
+}
+
+func init() {
+	services.RegisterProvider(&echoProvider{})
+}
+
+// starts a session, with media server access when access is true, and returns its cookie and id
+func newSession(t *testing.T, app *fiber.App, access bool) (cookie string, sessionId string) {
+	t.Helper()
+
+	path := "/session"
+	if access {
+		path += "?access=true"
+	}
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(res.Body)
+	cookie, _, _ = strings.Cut(res.Header.Get(fiber.HeaderSetCookie), ";")
+
+	return cookie, string(body)
+}
+
+func TestExportJobPlaylistResolvesLocalFilesWithMediaServerAccess(t *testing.T) {
+	musicDir := t.TempDir()
+	trackPath := filepath.Join(musicDir, "Artist - Title.mp3")
+
+	if err := os.WriteFile(trackPath, []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	local.UseMusicDir(musicDir, filepath.Join(t.TempDir(), "index.json"))
+	t.Cleanup(func() { local.UseMusicDir("", "") })
+
+	app := fiber.New()
+
+	app.Get("/session", func(c *fiber.Ctx) error {
+		sess, err := session.Store.Get(c)
+		if err != nil {
+			return err
+		}
+
+		if c.Query("access") == "true" {
+			sess.Set(session.MediaServerAccess, true)
+		}
+
+		id := sess.ID()
+
+		if err := sess.Save(); err != nil {
+			return err
+		}
+
+		return c.SendString(id)
+	})
+	app.Get("/jobs/:id/export", ExportJobPlaylist)
+
+	jobs.GlobalManager.Start()
+
+	tests := []struct {
+		name       string
+		access     bool
+		localFiles string
+	}{
+		{name: "session without media server access", access: false, localFiles: "0"},
+		{name: "session connected with the operator token", access: true, localFiles: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookie, sessionId := newSession(t, app, tt.access)
+
+			job, err := jobs.GlobalManager.Enqueue(jobs.Request{
+				SessionId: sessionId, Source: "test-echo", Target: "test-echo", PlaylistId: "source", Title: "Set",
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+
+			for job.Status() != jobs.StatusCompleted {
+				if time.Now().After(deadline) {
+					t.Fatalf("job status = %s, want completed", job.Status())
+				}
+
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/jobs/"+job.Id+"/export?format=m3u8", nil)
+			req.Header.Set(fiber.HeaderCookie, cookie)
+
+			res, err := app.Test(req)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, _ := io.ReadAll(res.Body)
+
+			if res.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want 200\n%s", res.StatusCode, body)
+			}
+
+			if got := res.Header.Get("X-Local-Files"); got != tt.localFiles {
+				t.Errorf("X-Local-Files = %s, want %s", got, tt.localFiles)
+			}
+
+			if tt.access != strings.Contains(string(body), trackPath) {
+				t.Errorf("export holds the local path = %v, want %v\n%s", !tt.access, tt.access, body)
+			}
+
+			if !tt.access && strings.Contains(string(body), musicDir) {
+				t.Errorf("export without media server access holds a server path\n%s", body)
+			}
+		})
+	}
+}
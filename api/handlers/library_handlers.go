@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/upload"
+	"github.com/to-dy/music-playlist-converter/api/stores/session"
+)
+
+type libraryPlaylistSummary struct {
+	Id          string `json:"id"`
+	Title       string `json:"title"`
+	TracksCount int    `json:"tracksCount"`
+}
+
+/*
+reads an uploaded library file holding several playlists e.g an iTunes Library.xml,
+the returned playlists are picked with SelectLibraryPlaylist
+*/
+func UploadLibrary(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("file is required", &ErrorSource{Parameter: "file"})},
+		})
+	}
+
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		log.Println("Error opening uploaded file - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	defer f.Close()
+
+	library, err := upload.ParseLibrary(file.Filename, f, sess.ID())
+
+	if err != nil {
+		detail := "invalid library file"
+
+		if errors.Is(err, upload.ErrUnsupportedLibrary) {
+			detail = "unsupported library file, upload an iTunes Library.xml, a rekordbox xml, a Traktor nml, a Spotify data export or a Google Takeout of YouTube Music"
+		}
+
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError(detail, &ErrorSource{Parameter: "file"})},
+		})
+	}
+
+	if err := upload.GlobalStore.SaveLibrary(library); err != nil {
+		log.Println("Error saving library - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// the session cookie has to be set before the library is read back
+	if err := sess.Save(); err != nil {
+		log.Println("Error saving session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&ApiOkResponse{Data: librarySummary(library)})
+}
+
+// lists the playlists of an uploaded library
+func GetLibrary(c *fiber.Ctx) error {
+	library, _, handleLibraryErr := getSessionLibrary(c, c.Params("id"))
+	if handleLibraryErr != nil {
+		return handleLibraryErr()
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: librarySummary(library)})
+}
+
+/*
+makes a playlist of an uploaded library the playlist of the session,
+it is converted with ConvertPlaylist once the target is authorized
+*/
+func SelectLibraryPlaylist(c *fiber.Ctx) error {
+	library, sess, handleLibraryErr := getSessionLibrary(c, c.Params("id"))
+	if handleLibraryErr != nil {
+		return handleLibraryErr()
+	}
+
+	uploaded, err := library.Upload(c.Params("playlistId"))
+
+	if errors.Is(err, upload.ErrPlaylistNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(ApiErrorResponse{
+			Errors: Errors{&ErrorObject{
+				Status: fiber.StatusNotFound,
+				Title:  "Not Found",
+				Detail: "library playlist not found",
+				Source: &ErrorSource{Parameter: "playlistId"},
+			}},
+		})
+	}
+
+	if errors.Is(err, upload.ErrNoTracks) {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("library playlist has no tracks", &ErrorSource{Parameter: "playlistId"})},
+		})
+	}
+
+	if err := saveUploadSession(sess, uploaded); err != nil {
+		log.Println("Error saving upload - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{
+		Data: map[string]interface{}{
+			"isPlaylistValid":      true,
+			"supportedConversions": services.ConversionTargets(upload.ProviderName),
+		},
+	})
+}
+
+type libraryConversion struct {
+	PlaylistId string `json:"playlistId"`
+	Title      string `json:"title"`
+	JobId      string `json:"jobId,omitempty"`
+	StatusUrl  string `json:"statusUrl,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+/*
+converts several playlists of an uploaded library at once, each playlist gets its own conversion job
+titled after the playlist. no playlistIds converts every playlist of the library,
+the target has to be authorized first like for ConvertPlaylist
+*/
+func ConvertLibraryPlaylists(c *fiber.Ctx) error {
+	c.Accepts(fiber.MIMEApplicationJSON)
+
+	bodyData := struct {
+		PlaylistIds []string `json:"playlistIds"`
+	}{}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&bodyData); err != nil {
+			log.Println("Error parsing body - " + err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+				Errors: Errors{getBadRequestError("invalid request body", &ErrorSource{Pointer: "/playlistIds"})},
+			})
+		}
+	}
+
+	library, sess, handleLibraryErr := getSessionLibrary(c, c.Params("id"))
+	if handleLibraryErr != nil {
+		return handleLibraryErr()
+	}
+
+	token := sess.Get(session.AuthCodeToken)
+	convertTo := sess.Get(session.ConvertTo)
+
+	if token == nil || convertTo == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("invalid session", &ErrorSource{})},
+		})
+	}
+
+	if !services.IsConversionTarget(convertTo.(string)) {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("playlist conversion from "+upload.ProviderName+" to "+convertTo.(string)+" not supported", &ErrorSource{})},
+		})
+	}
+
+	playlistIds := bodyData.PlaylistIds
+	if len(playlistIds) == 0 {
+		for _, playlist := range library.Playlists {
+			playlistIds = append(playlistIds, playlist.Id)
+		}
+	}
+
+	converting := []*libraryConversion{}
+	skipped := []*libraryConversion{}
+
+	for _, playlistId := range playlistIds {
+		conversion := &libraryConversion{PlaylistId: playlistId}
+
+		if playlist := library.Playlist(playlistId); playlist != nil {
+			conversion.Title = playlist.Title
+		}
+
+		uploaded, err := library.Upload(playlistId)
+
+		if errors.Is(err, upload.ErrPlaylistNotFound) {
+			conversion.Reason = "library playlist not found"
+			skipped = append(skipped, conversion)
+			continue
+		}
+
+		if errors.Is(err, upload.ErrNoTracks) {
+			conversion.Reason = "library playlist has no tracks"
+			skipped = append(skipped, conversion)
+			continue
+		}
+
+		if err := upload.GlobalStore.Save(uploaded); err != nil {
+			log.Println("Error saving upload - " + err.Error())
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		job, handleJobErr := startConversionJob(c, &sessionPlaylist{
+			Id:         uploaded.Id,
+			Title:      uploaded.Title,
+			Url:        upload.PlaylistURL(uploaded.Id),
+			Source:     upload.ProviderName,
+			TrackCount: len(uploaded.Tracks),
+			NewTitle:   uploaded.Title,
+			NewSource:  convertTo.(string),
+			SessionId:  sess.ID(),
+		})
+
+		// the queue is full, the remaining playlists can be converted later
+		if handleJobErr != nil {
+			conversion.Reason = "conversion could not be queued"
+			skipped = append(skipped, conversion)
+			continue
+		}
+
+		conversion.JobId = job.Id
+		conversion.StatusUrl = "/api/jobs/" + job.Id
+		converting = append(converting, conversion)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(&ApiOkResponse{Data: map[string]interface{}{
+		"jobs":    converting,
+		"skipped": skipped,
+	}})
+}
+
+func librarySummary(library *upload.Library) map[string]interface{} {
+	playlists := []*libraryPlaylistSummary{}
+
+	for _, playlist := range library.Playlists {
+		playlists = append(playlists, &libraryPlaylistSummary{
+			Id:          playlist.Id,
+			Title:       playlist.Title,
+			TracksCount: len(playlist.Tracks),
+		})
+	}
+
+	return map[string]interface{}{
+		"libraryId": library.Id,
+		"format":    library.Format,
+		"playlists": playlists,
+	}
+}
+
+func getSessionLibrary(c *fiber.Ctx, id string) (*upload.Library, *fibersession.Session, func() error) {
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return nil, nil, func() error {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	library, err := upload.GlobalStore.GetLibrary(id)
+
+	if err != nil {
+		log.Println("Error getting library - " + err.Error())
+		return nil, nil, func() error {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	if library == nil || library.SessionId != sess.ID() {
+		return nil, nil, func() error {
+			return c.Status(fiber.StatusNotFound).JSON(ApiErrorResponse{
+				Errors: Errors{&ErrorObject{
+					Status: fiber.StatusNotFound,
+					Title:  "Not Found",
+					Detail: "library not found",
+					Source: &ErrorSource{Parameter: "id"},
+				}},
+			})
+		}
+	}
+
+	return library, sess, nil
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services/local"
+)
+
+// rescans the local music folder, tags are only read again for new and changed files. only the operator may rescan
+func ScanLocalMusic(c *fiber.Ctx) error {
+	if checkErr := checkMediaServerToken(c); checkErr != nil {
+		return checkErr()
+	}
+
+	result, err := local.Scan()
+
+	if errors.Is(err, local.ErrMusicDirNotSet) {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError(err.Error(), &ErrorSource{})},
+		})
+	}
+
+	if err != nil {
+		log.Println("Error scanning local music - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: result})
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/formats"
+	"github.com/to-dy/music-playlist-converter/api/services/upload"
+	"github.com/to-dy/music-playlist-converter/api/stores/session"
+)
+
+// rows returned with the csv headers to confirm the columns
+const csvSampleRows = 5
+
+/*
+exports the tracks of the playlist verified in the session as a playlist file,
+the `format` query parameter is the name of the file format e.g m3u8
+*/
+func ExportPlaylist(c *fiber.Ctx) error {
+	format, ok := formats.GetFormat(c.Query("format"))
+
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("format must be one of "+strings.Join(formats.FormatNames(), ", "), &ErrorSource{Parameter: "?format"})},
+		})
+	}
+
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	playlistSource := sess.Get(session.PlaylistSource)
+	playlistId := sess.Get(session.PlaylistID)
+	playlistName := sess.Get(session.PlaylistName)
+
+	if playlistSource == nil || playlistId == nil || playlistName == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("invalid session", &ErrorSource{})},
+		})
+	}
+
+	provider, ok := services.GetProvider(playlistSource.(string))
+
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("unsupported playlist source", &ErrorSource{})},
+		})
+	}
+
+	tracks, truncated, err := provider.GetPlaylistTracks(playlistId.(string), sess.ID())
+
+	if err != nil {
+		log.Println(provider.Name()+" GetPlaylistTracks error", err)
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	playlist := &formats.Playlist{Title: playlistName.(string), Entries: []*formats.Entry{}}
+
+	for _, track := range tracks {
+		playlist.Entries = append(playlist.Entries, trackEntry(provider, track))
+	}
+
+	var buf bytes.Buffer
+
+	if err := format.Encode(&buf, playlist); err != nil {
+		if errors.Is(err, formats.ErrNoLocalFiles) {
+			return noLocalFilesResponse(c, format)
+		}
+
+		log.Println("Error encoding " + format.Name() + " playlist - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	setAttachment(c, formats.SafeFileName(playlist.Title)+format.Extensions()[0])
+	c.Set(fiber.HeaderContentType, format.ContentType())
+
+	if truncated {
+		c.Set("X-Playlist-Truncated", "true")
+	}
+
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// formats referencing tracks only by file, e.g traktor, can't be written without a single local file
+func noLocalFilesResponse(c *fiber.Ctx, format formats.Format) error {
+	return c.Status(fiber.StatusConflict).JSON(ApiErrorResponse{
+		Errors: Errors{&ErrorObject{
+			Status: fiber.StatusConflict,
+			Title:  "Conflict",
+			Detail: "none of the tracks is a local file, " + format.Name() + " playlists only reference local files",
+			Source: &ErrorSource{Parameter: "?format"},
+		}},
+	})
+}
+
+/*
+the location of an exported track is its url on the provider,
+tracks without a url are referenced by "{provider}:{track id}", tracks without an id have no location
+*/
+func trackEntry(provider services.Provider, track *services.SearchTrack) *formats.Entry {
+	location := ""
+
+	if linker, ok := provider.(services.TrackLinker); ok && track.Id != "" {
+		location = linker.TrackURL(track.Id)
+	}
+
+	if location == "" && track.Id != "" {
+		location = provider.Name() + ":" + track.Id
+	}
+
+	artists := []string{}
+
+	for _, artist := range track.Artists {
+		artists = append(artists, artist.Name)
+	}
+
+	return &formats.Entry{
+		Location: location,
+		Title:    track.Title,
+		Artist:   strings.Join(artists, ", "),
+		Album:    track.Album.Name,
+		Duration: track.Duration,
+		ISRC:     track.ISRC,
+	}
+}
+
+// filename* keeps non ascii names intact, older clients use the ascii filename
+func setAttachment(c *fiber.Ctx, fileName string) {
+	ascii := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return '_'
+		}
+
+		return r
+	}, fileName)
+
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+ascii+`"; filename*=UTF-8''`+url.PathEscape(fileName))
+}
+
+/*
+returns the headers and first rows of an uploaded csv file with the columns detected from the headers,
+the confirmed columns are sent with the file to start the conversion
+*/
+func ReadCSVColumns(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("file is required", &ErrorSource{Parameter: "file"})},
+		})
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		log.Println("Error opening uploaded file - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	defer f.Close()
+
+	header, err := formats.ReadCSVHeader(f, csvSampleRows)
+
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("invalid csv file", &ErrorSource{Parameter: "file"})},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: header})
+}
+
+// reads an uploaded playlist file and makes it the playlist of the session
+func startUploadSession(c *fiber.Ctx, file *multipart.FileHeader) func() error {
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return func() error {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		log.Println("Error opening uploaded file - " + err.Error())
+		return func() error {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	defer f.Close()
+
+	var uploaded *upload.Upload
+
+	// csv columns confirmed by the user, detected from the headers otherwise
+	if columnsValue := c.FormValue("columns"); columnsValue != "" {
+		columns := &formats.CSVColumns{}
+
+		if err := json.Unmarshal([]byte(columnsValue), columns); err != nil {
+			return func() error {
+				return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+					Errors: Errors{getBadRequestError("invalid csv columns", &ErrorSource{Parameter: "columns"})},
+				})
+			}
+		}
+
+		uploaded, err = upload.ParseCSV(file.Filename, f, columns, sess.ID())
+	} else {
+		uploaded, err = upload.Parse(file.Filename, f, sess.ID())
+	}
+
+	if err != nil {
+		detail := "invalid playlist file"
+
+		switch {
+		case errors.Is(err, upload.ErrUnsupportedFormat):
+			detail = "playlist file must be one of " + strings.Join(formats.FormatNames(), ", ")
+		case errors.Is(err, upload.ErrNoTracks):
+			detail = "playlist file has no tracks"
+		case errors.Is(err, formats.ErrCSVTitleColumn):
+			detail = "csv title column not found, set the columns of the file"
+		}
+
+		return func() error {
+			return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+				Errors: Errors{getBadRequestError(detail, &ErrorSource{Parameter: "file"})},
+			})
+		}
+	}
+
+	if err := saveUploadSession(sess, uploaded); err != nil {
+		log.Println("Error saving upload - " + err.Error())
+		return func() error {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	return nil
+}
+
+// stores an upload and makes it the playlist of the session
+func saveUploadSession(sess *fibersession.Session, uploaded *upload.Upload) error {
+	if err := upload.GlobalStore.Save(uploaded); err != nil {
+		return err
+	}
+
+	pl := &sessionPlaylist{
+		Id:         uploaded.Id,
+		Title:      uploaded.Title,
+		Url:        upload.PlaylistURL(uploaded.Id),
+		Source:     upload.ProviderName,
+		TrackCount: len(uploaded.Tracks),
+	}
+
+	return saveSessionPlaylist(sess, pl)
+}
@@ -2,35 +2,19 @@ package handlers
 
 import (
 	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gookit/goutil/arrutil"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
 
 	"github.com/to-dy/music-playlist-converter/api/services"
-	"github.com/to-dy/music-playlist-converter/api/services/spotify"
-	"github.com/to-dy/music-playlist-converter/api/services/youtube"
+	"github.com/to-dy/music-playlist-converter/api/services/jobs"
 	"github.com/to-dy/music-playlist-converter/api/stores/session"
 )
 
-type supportedPlaylist struct {
-	YouTube string
-	Spotify string
-}
-
-var supportedPlaylistsHost = []string{"music.youtube.com", "www.youtube.com", "youtube.com", "open.spotify.com"}
-
-var supportedConversions = &supportedPlaylist{
-	YouTube: "youtube",
-	Spotify: "spotify",
-}
-
-var supportedConversionsList = []string{supportedConversions.YouTube, supportedConversions.Spotify}
-
 type sessionPlaylist struct {
 	Id         string
 	Title      string
@@ -61,7 +45,9 @@ func VerifyPlaylist(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
-	if !arrutil.Contains(supportedPlaylistsHost, parsedURL.Host) {
+	provider, supported := services.FindProviderByURL(parsedURL)
+
+	if !supported {
 		log.Println("unsupported playlist host - " + parsedURL.Host)
 
 		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
@@ -69,104 +55,74 @@ func VerifyPlaylist(c *fiber.Ctx) error {
 		})
 	}
 
-	queryParams := parsedURL.Query()
-
-	switch parsedURL.Host {
-	// youtube hosts
-	case supportedPlaylistsHost[0], supportedPlaylistsHost[1], supportedPlaylistsHost[2]:
+	playlistId, resolveErr := provider.ResolvePlaylistURL(parsedURL)
 
-		if parsedURL.Path != "/playlist" || len(queryParams) < 1 || queryParams["list"] == nil {
-			log.Println("Invalid YouTubeMusic playlist - " + parsedURL.Path)
-
-			return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
-				Errors: Errors{getBadRequestError("Invalid YouTubeMusic playlist url", nil)},
-			})
-		}
-
-		playlist, checkErr := youtube.FindPlaylist(queryParams["list"][0])
-
-		if checkErr != nil {
-			log.Println("youtube.FindPlaylist error", checkErr)
-			// TODO: check and handle based on error type
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
+	if resolveErr != nil {
+		log.Println("Invalid " + provider.DisplayName() + " playlist - " + parsedURL.String())
 
-		if playlist != nil {
-			log.Println("playlist exists - " + queryParams["list"][0])
-
-			pl := &sessionPlaylist{
-				Id:         playlist.Id,
-				Title:      playlist.Snippet.Title,
-				Url:        parsedURL.String(),
-				Source:     supportedConversions.YouTube,
-				TrackCount: int(playlist.ContentDetails.ItemCount),
-			}
-
-			if err := startSession(c, pl); err != nil {
-				return c.SendStatus(fiber.StatusInternalServerError)
-			}
-
-			return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{
-				Data: map[string]interface{}{
-					"isPlaylistValid":      true,
-					"supportedConversions": []string{supportedConversions.Spotify},
-				},
-			})
-		} else {
-			log.Println("playlist not found - ", checkErr)
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("Invalid "+provider.DisplayName()+" playlist url", nil)},
+		})
+	}
 
-			return c.Status(fiber.StatusNotFound).JSON(ApiErrorResponse{
-				Errors: Errors{getBadRequestError("YouTubeMusic playlist does not exist, it might have been deleted", nil)},
-			})
-		}
-		// spotify host
-	case supportedPlaylistsHost[3]:
-		pathParts := strings.Split(parsedURL.Path, "/")
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
 
-		if len(pathParts) < 3 || pathParts[1] != "playlist" {
+	if isMediaServer(provider.Name()) && sess.Get(session.MediaServerAccess) != true {
+		return c.Status(fiber.StatusUnauthorized).JSON(ApiErrorResponse{
+			Errors: Errors{&ErrorObject{
+				Status: fiber.StatusUnauthorized,
+				Title:  "Unauthorized",
+				Detail: "Connect to " + provider.DisplayName() + " first to read its playlists",
+				Source: &ErrorSource{},
+			}},
+		})
+	}
 
-			return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
-				Errors: Errors{getBadRequestError("Invalid Spotify playlist url", nil)},
-			})
-		}
+	// private playlists are read with the tokens of the session
+	playlist, checkErr := provider.FindPlaylist(playlistId, sess.ID())
 
-		playlist, checkErr := spotify.FindPlaylist(pathParts[2])
+	if errors.Is(checkErr, services.ErrEmptyPlaylist) {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError(provider.DisplayName()+" playlist has no tracks to convert", nil)},
+		})
+	}
 
-		if checkErr != nil {
-			log.Println("spotify.FindPlaylist error", checkErr)
-			// TODO: check and handle based on error type
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
+	if checkErr != nil {
+		log.Println(provider.Name()+" FindPlaylist error", checkErr)
+		// TODO: check and handle based on error type
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
 
-		if playlist != nil {
-			pl := &sessionPlaylist{
-				Id:         playlist.Id,
-				Title:      playlist.Name,
-				Url:        parsedURL.String(),
-				Source:     supportedConversions.Spotify,
-				TrackCount: int(playlist.Tracks.Total),
-			}
+	if playlist == nil {
+		log.Println("playlist not found - " + playlistId)
 
-			if err := startSession(c, pl); err != nil {
-				return c.SendStatus(fiber.StatusInternalServerError)
-			}
+		return c.Status(fiber.StatusNotFound).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError(provider.DisplayName()+" playlist does not exist, it might have been deleted", nil)},
+		})
+	}
 
-			return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{
-				Data: map[string]interface{}{
-					"isPlaylistValid":      true,
-					"supportedConversions": []string{supportedConversions.YouTube},
-				},
-			})
-		} else {
+	pl := &sessionPlaylist{
+		Id:         playlist.Id,
+		Title:      playlist.Title,
+		Url:        parsedURL.String(),
+		Source:     provider.Name(),
+		TrackCount: playlist.TrackCount,
+	}
 
-			return c.Status(fiber.StatusNotFound).JSON(ApiErrorResponse{
-				Errors: Errors{getBadRequestError("Spotify playlist does not exist, it might have been deleted", nil)},
-			})
-		}
+	if err := startSession(c, pl); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
-	// if for any reason we reach here, return a server error
-	return c.SendStatus(fiber.StatusInternalServerError)
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{
+		Data: map[string]interface{}{
+			"isPlaylistValid":      true,
+			"supportedConversions": services.ConversionTargets(provider.Name()),
+		},
+	})
 }
 
 func PreviewPlaylistConversion(c *fiber.Ctx) error {
@@ -186,7 +142,7 @@ func PreviewPlaylistConversion(c *fiber.Ctx) error {
 	// value set on oauth start
 	convertTo := sess.Get(session.ConvertTo)
 
-	if sessionUrl == nil || token == nil || playlistSource != nil || playlistName == nil || playlistTracksCount == nil {
+	if sessionUrl == nil || token == nil || playlistSource == nil || playlistName == nil || playlistTracksCount == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
 			Errors: Errors{getBadRequestError("invalid session", &ErrorSource{})},
 		})
@@ -204,15 +160,16 @@ func PreviewPlaylistConversion(c *fiber.Ctx) error {
 }
 
 /*
-converts valid playlist url to a supported source
+starts converting valid playlist url to a supported source in the background
 
-it uses the PlaylistURL stored in the session
+it uses the PlaylistURL stored in the session, the returned job id is used to follow the conversion.
+a multipart request can upload a playlist file in the `file` field to convert instead e.g an m3u8 file
 */
 func ConvertPlaylist(c *fiber.Ctx) error {
 	c.Accepts(fiber.MIMEApplicationJSON)
 
 	bodyData := struct {
-		Title string `json:"title"`
+		Title string `json:"title" form:"title"`
 	}{}
 
 	if err := c.BodyParser(&bodyData); err != nil {
@@ -226,25 +183,68 @@ func ConvertPlaylist(c *fiber.Ctx) error {
 		})
 	}
 
+	// an uploaded playlist file replaces the playlist verified in the session
+	if file, err := c.FormFile("file"); err == nil {
+		if handleUploadErr := startUploadSession(c, file); handleUploadErr != nil {
+			return handleUploadErr()
+		}
+	}
+
 	playlistInfo, handlePlInfoErr := getSessionPlaylistInfo(c, bodyData.Title)
 	if handlePlInfoErr != nil {
-		handlePlInfoErr()
+		return handlePlInfoErr()
 	}
 
-	return handleConvertPlaylist(c, playlistInfo, nil)
+	job, handleJobErr := startConversionJob(c, playlistInfo)
+	if handleJobErr != nil {
+		return handleJobErr()
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(&ApiOkResponse{Data: map[string]interface{}{
+		"jobId":     job.Id,
+		"statusUrl": "/api/jobs/" + job.Id,
+	}})
 }
 
 /*
 SSE handler
-converts valid playlist url to a supported source while streaming the process to the client
+streams the events of a conversion job to the client
 
-it uses the PlaylistURL stored in the session
+with the `job` query parameter it subscribes to an existing job of the session,
+otherwise it starts converting the PlaylistURL stored in the session to a new playlist titled `title`.
+a client reconnecting with Last-Event-ID resumes the stream of the job it started instead of starting a new one
 */
 func StreamConvertPlaylist(c *fiber.Ctx) error {
 	c.Set(fiber.HeaderCacheControl, "no-cache")
 
 	// validation checks
 
+	if jobId := c.Query("job"); jobId != "" {
+		job, handleJobErr := getSessionJob(c, jobId)
+		if handleJobErr != nil {
+			return handleJobErr()
+		}
+
+		return streamJob(c, job)
+	}
+
+	if lastEventID(c) > 0 {
+		sess, err := session.Store.Get(c)
+		if err != nil {
+			log.Println("Error getting session - " + err.Error())
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		if jobId, ok := sess.Get(session.ConversionJob).(string); ok {
+			job, handleJobErr := getSessionJob(c, jobId)
+			if handleJobErr != nil {
+				return handleJobErr()
+			}
+
+			return streamJob(c, job)
+		}
+	}
+
 	qTitle := c.Query("title")
 	if qTitle == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
@@ -254,29 +254,32 @@ func StreamConvertPlaylist(c *fiber.Ctx) error {
 
 	playlistInfo, handlePlInfoErr := getSessionPlaylistInfo(c, qTitle)
 	if handlePlInfoErr != nil {
-		handlePlInfoErr()
+		return handlePlInfoErr()
 	}
 
-	// validations passed, get ready to start steaming
+	job, handleJobErr := startConversionJob(c, playlistInfo)
+	if handleJobErr != nil {
+		return handleJobErr()
+	}
 
-	c.Set(fiber.HeaderContentType, "text/event-stream")
-	c.Set(fiber.HeaderConnection, "keep-alive")
+	return streamJob(c, job)
+}
 
-	c.Response().SetBodyStreamWriter(func(w *bufio.Writer) {
-		// w.Flush()
-		// go handleConvertPlaylist(c, playlistInfo, w)
-		handleConvertPlaylist(c, playlistInfo, w)
-	})
+func streamEvent(w *bufio.Writer, id int64, event string, data string) error {
+	fmt.Fprintf(w, "id: %d\n", id)
+	fmt.Fprintf(w, "event: %s\n", event)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+
+	if err := w.Flush(); err != nil {
+		log.Println("Error flushing writer - " + err.Error())
+		return err
+	}
 
 	return nil
 }
 
-func streamEvent(w *bufio.Writer, event string, data string) error {
-	ev := fmt.Sprintf("event: %s\n", event)
-	dt := fmt.Sprintf("data: %s\n", data)
-
-	w.Write([]byte(ev))
-	w.Write([]byte(dt))
+func streamHeartbeat(w *bufio.Writer) error {
+	w.WriteString(": heartbeat\n\n")
 
 	if err := w.Flush(); err != nil {
 		log.Println("Error flushing writer - " + err.Error())
@@ -286,44 +289,57 @@ func streamEvent(w *bufio.Writer, event string, data string) error {
 	return nil
 }
 
-func handleConvertPlaylist(c *fiber.Ctx, playlistInfo *sessionPlaylist, streamWriter *bufio.Writer) error {
-	// platform we want to convert to
-	switch playlistInfo.NewSource {
-	// youtube
-	case supportedConversions.YouTube:
-		// youtube -> spotify
-		if playlistInfo.Source == supportedConversions.Spotify {
-			return youtubeToSpotify(c, playlistInfo, streamWriter)
+// validates the conversion of the session playlist and queues it
+func startConversionJob(c *fiber.Ctx, playlistInfo *sessionPlaylist) (*jobs.Job, func() error) {
+	_, sourceOk := services.GetProvider(playlistInfo.Source)
+	targetOk := services.IsConversionTarget(playlistInfo.NewSource)
+
+	if !sourceOk || !targetOk || playlistInfo.Source == playlistInfo.NewSource {
+		return nil, func() error {
+			return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+				Errors: Errors{getBadRequestError("playlist conversion from "+playlistInfo.Source+" to "+playlistInfo.NewSource+" not supported", &ErrorSource{})},
+			})
 		}
+	}
 
-		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
-			Errors: Errors{getBadRequestError("playlist conversion from Youtube to "+playlistInfo.Source+" not supported", &ErrorSource{})},
-		})
+	job, err := jobs.GlobalManager.Enqueue(jobs.Request{
+		SessionId:  playlistInfo.SessionId,
+		Source:     playlistInfo.Source,
+		Target:     playlistInfo.NewSource,
+		PlaylistId: playlistInfo.Id,
+		Title:      playlistInfo.NewTitle,
+	})
 
-		// spotify
-	case supportedConversions.Spotify:
-		//  spotify -> youtube
-		if playlistInfo.Source == supportedConversions.YouTube {
-			return spotifyToYoutube(c, playlistInfo, streamWriter)
+	if err != nil {
+		log.Println("Error queueing conversion - " + err.Error())
+
+		return nil, func() error {
+			return c.SendStatus(fiber.StatusServiceUnavailable)
 		}
+	}
 
-		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
-			Errors: Errors{getBadRequestError("playlist conversion from Youtube to "+playlistInfo.Source+" not supported", &ErrorSource{})},
-		})
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return job, nil
+	}
+
+	sess.Set(session.ConversionJob, job.Id)
+
+	if err := sess.Save(); err != nil {
+		log.Println("Error saving session - " + err.Error())
 	}
 
-	// if for any reason we reach here, return a server error
-	return c.SendStatus(fiber.StatusInternalServerError)
+	return job, nil
 }
 
-func getSessionPlaylistInfo(c *fiber.Ctx, newTitle string) (*sessionPlaylist, func()) {
+func getSessionPlaylistInfo(c *fiber.Ctx, newTitle string) (*sessionPlaylist, func() error) {
 	sess, err := session.Store.Get(c)
 	if err != nil {
 		log.Println("Error getting session - " + err.Error())
-		return nil, func() {
-			c.SendStatus(fiber.StatusInternalServerError)
+		return nil, func() error {
+			return c.SendStatus(fiber.StatusInternalServerError)
 		}
-		// return nil, err
 	}
 
 	sessionUrl := sess.Get(session.PlaylistURL)
@@ -334,20 +350,22 @@ func getSessionPlaylistInfo(c *fiber.Ctx, newTitle string) (*sessionPlaylist, fu
 	playlistTracksCount := sess.Get(session.PlaylistTracksCount)
 	playlistId := sess.Get(session.PlaylistID)
 
-	if sessionUrl == nil || token == nil || convertTo == nil || playlistSource == nil || playlistName == nil || playlistTracksCount == nil {
+	id, idOk := playlistId.(string)
+
+	if sessionUrl == nil || token == nil || convertTo == nil || playlistSource == nil || playlistName == nil || playlistTracksCount == nil || !idOk {
 
-		return nil, func() {
-			c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+		return nil, func() error {
+			return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
 				Errors: Errors{getBadRequestError("invalid session", &ErrorSource{})},
 			})
 		}
 	}
 
-	if !arrutil.Contains(supportedConversionsList, convertTo) {
+	if _, ok := services.GetProvider(convertTo.(string)); !ok {
 		log.Println("unsupported playlist conversion - " + convertTo.(string))
 
-		return nil, func() {
-			c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+		return nil, func() error {
+			return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
 				Errors: Errors{getBadRequestError("unsupported playlist conversion source", &ErrorSource{})},
 			})
 		}
@@ -355,7 +373,7 @@ func getSessionPlaylistInfo(c *fiber.Ctx, newTitle string) (*sessionPlaylist, fu
 	}
 
 	playlistInfo := &sessionPlaylist{
-		Id:         playlistId.(string),
+		Id:         id,
 		Title:      playlistName.(string),
 		Url:        sessionUrl.(string),
 		Source:     playlistSource.(string),
@@ -368,363 +386,6 @@ func getSessionPlaylistInfo(c *fiber.Ctx, newTitle string) (*sessionPlaylist, fu
 	return playlistInfo, nil
 }
 
-func youtubeToSpotify(c *fiber.Ctx, playlistInfo *sessionPlaylist, streamWriter *bufio.Writer) error {
-	spotifyUserId, userIdErr := spotify.GetUserId(playlistInfo.SessionId)
-	if userIdErr != nil {
-		log.Println("spotifyUserId error", userIdErr)
-
-		if streamWriter != nil {
-			streamEvent(streamWriter, "error", "error getting users spotify user id ")
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "Conversion process aborted",
-				"info": fiber.Map{
-					"tracks_found":          0,
-					"tracks_not_found":      0,
-					"conversion_successful": false,
-				},
-			})
-
-			// tell client to close connection
-			streamEvent(streamWriter, "done", string(d))
-		} else {
-
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-	}
-
-	ytTracks, getTracksErr := youtube.YTMusic_GetPlaylistTracks(playlistInfo.Id)
-
-	if getTracksErr != nil {
-		log.Println("youtubeToSpotify YTMusic_GetPlaylistTracks error", getTracksErr)
-
-		if streamWriter != nil {
-			streamEvent(streamWriter, "error", "error getting playlist on YouTubeMusic")
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "Conversion process aborted",
-				"info": fiber.Map{
-					"tracks_found":          0,
-					"tracks_not_found":      0,
-					"conversion_successful": false,
-				},
-			})
-			// tell client to close connection
-			streamEvent(streamWriter, "done", string(d))
-		} else {
-
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-	}
-
-	tracks := youtube.ToSearchTrackList(ytTracks)
-
-	if streamWriter != nil {
-		streamEvent(streamWriter, "info", "creating playlist on Spotify")
-	}
-
-	spotifyPlId, cr8plErr := spotify.CreatePlaylist(playlistInfo.NewTitle, spotifyUserId, playlistInfo.SessionId)
-
-	if cr8plErr != nil {
-		log.Println("spotify.CreatePlaylist error", cr8plErr)
-
-		if streamWriter != nil {
-			streamEvent(streamWriter, "error", "error creating playlist on Spotify")
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "Conversion process aborted",
-				"info": fiber.Map{
-					"tracks_found":          0,
-					"tracks_not_found":      0,
-					"conversion_successful": false,
-				},
-			})
-			// tell client to close connection
-			streamEvent(streamWriter, "done", string(d))
-		} else {
-
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-	}
-
-	if streamWriter != nil {
-		streamEvent(streamWriter, "info", "Playlist created Spotify")
-
-		// go youtubeToSpotifyStream(tracks, playlistInfo, spotifyPlId, streamWriter)
-		youtubeToSpotifyStream(tracks, playlistInfo, spotifyPlId, streamWriter)
-	} else {
-
-		addErr := spotify.AddTracksToPlaylist(spotifyPlId, tracks, playlistInfo.SessionId)
-
-		if addErr != nil {
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-
-		return c.Status(fiber.StatusCreated).JSON(&ApiOkResponse{Data: map[string]interface{}{
-			"playlistUrl": "https://open.spotify.com/playlist/" + spotifyPlId,
-		}})
-	}
-
-	// if for any reason we reach here, return a server error
-	return c.SendStatus(fiber.StatusInternalServerError)
-}
-
-func youtubeToSpotifyStream(tracks []*services.SearchTrack, playlistInfo *sessionPlaylist, spotifyPlId string, streamWriter *bufio.Writer) error {
-	streamEvent(streamWriter, "info", "Preparing to add tracks to playlist on Spotify")
-
-	uris := []string{}
-
-	for _, track := range tracks {
-		trackJson, mErr := json.Marshal(track)
-		if mErr != nil {
-			log.Println("error marshaling track: ", mErr)
-
-			streamEvent(streamWriter, "track_search", "error encountered with track: "+string(trackJson))
-			continue
-		}
-
-		artist := ""
-		if len(track.Artists) > 0 {
-			artist = track.Artists[0].Name
-		}
-
-		d, _ := json.Marshal(fiber.Map{
-			"message": "Searching track on Spotify: " + track.Title + " by " + artist,
-			"track":   track,
-			"status":  "searching",
-		})
-		streamEvent(streamWriter, "track_search", string(d))
-
-		entry, found, err := spotify.SearchTrack(track.Title, artist)
-
-		if err != nil {
-			log.Println("error searching track: ", err)
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "error searching for track",
-				"track":   track,
-				"success": false,
-				"status":  "error",
-			})
-			streamEvent(streamWriter, "track_search", string(d))
-
-			continue
-		}
-
-		if !found {
-			d, _ := json.Marshal(fiber.Map{
-				"message": "track not found",
-				"track":   track,
-				"success": false,
-				"status":  "error",
-			})
-			streamEvent(streamWriter, "track_search", string(d))
-
-			continue
-		}
-
-		if entry != nil {
-			uris = append(uris, entry.Uri)
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "Track found",
-				"track":   track,
-				"success": true,
-				"status":  "done",
-			})
-			streamEvent(streamWriter, "track_search", string(d))
-		}
-	}
-
-	addTracksErr := spotify.AddTracksToPlaylistByUris(spotifyPlId, uris, playlistInfo.SessionId)
-
-	if addTracksErr != nil {
-		streamEvent(streamWriter, "error", "error adding tracks to playlist")
-	}
-
-	d, _ := json.Marshal(fiber.Map{
-		"message": "Conversion process complete",
-		"info": fiber.Map{
-			"tracks_found":          len(uris),
-			"tracks_not_found":      len(tracks) - len(uris),
-			"conversion_successful": len(uris) == len(tracks) && addTracksErr == nil,
-		},
-	})
-
-	// tell client to close connection
-	streamEvent(streamWriter, "done", string(d))
-
-	return nil
-}
-
-func spotifyToYoutube(c *fiber.Ctx, playlistInfo *sessionPlaylist, streamWriter *bufio.Writer) error {
-	spTracks, getTracksErr := spotify.GetPlaylistTracks(playlistInfo.Id)
-	if getTracksErr != nil {
-		log.Println("spotifyToYoutube GetPlaylistTracks error", getTracksErr)
-
-		if streamWriter != nil {
-			streamEvent(streamWriter, "error", "Unable to get playlist tracks")
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "Conversion process aborted",
-				"info": fiber.Map{
-					"tracks_found":          0,
-					"tracks_not_found":      0,
-					"conversion_successful": false,
-				},
-			})
-
-			// tell client to close connection
-			streamEvent(streamWriter, "done", string(d))
-		} else {
-
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-	}
-
-	tracks := spotify.ToSearchTrackList(spTracks)
-
-	if streamWriter != nil {
-		streamEvent(streamWriter, "info", "Creating playlist on YoutubeMusic")
-	}
-
-	ytPlId, createErr := youtube.CreatePlaylist(playlistInfo.NewTitle, playlistInfo.SessionId)
-
-	if createErr != nil {
-		if streamWriter != nil {
-			log.Println("youtube.CreatePlaylist error", createErr)
-
-			streamEvent(streamWriter, "error", "Error creating playlist on YoutubeMusic")
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "Conversion process aborted",
-				"info": fiber.Map{
-					"tracks_found":          0,
-					"tracks_not_found":      0,
-					"conversion_successful": false,
-				},
-			})
-			// tell client to close connection
-			streamEvent(streamWriter, "done", string(d))
-		} else {
-
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-	}
-	if streamWriter != nil {
-		streamEvent(streamWriter, "info", "Playlist created Spotify")
-
-		spotifyToYoutubeStream(tracks, playlistInfo, ytPlId, streamWriter)
-	} else {
-
-		addErr := youtube.AddTracksToPlaylist(ytPlId, tracks, playlistInfo.SessionId)
-
-		if addErr != nil {
-
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-
-		return c.Status(fiber.StatusCreated).JSON(&ApiOkResponse{Data: map[string]interface{}{
-			"playlistUrl": "https://music.youtube.com/playlist?list=" + ytPlId,
-		}})
-	}
-
-	// if for any reason we reach here, return a server error
-	return c.SendStatus(fiber.StatusInternalServerError)
-}
-
-func spotifyToYoutubeStream(tracks []*services.SearchTrack, playlistInfo *sessionPlaylist, youtubePlId string, streamWriter *bufio.Writer) error {
-	streamEvent(streamWriter, "info", "Preparing to add tracks to playlist on YoutubeMusic")
-
-	tracksFound := 0
-	tracksNotFound := 0
-	var addErr error
-
-	for _, track := range tracks {
-		trackJson, mErr := json.Marshal(track)
-		if mErr != nil {
-			log.Println("error marshaling track: ", mErr)
-
-			streamEvent(streamWriter, "track_search", "error encountered with track: "+string(trackJson))
-			continue
-		}
-
-		artist := ""
-		if len(track.Artists) > 0 {
-			artist = track.Artists[0].Name
-		}
-
-		d, _ := json.Marshal(fiber.Map{
-			"message": "Searching for track on YoutubeMusic: " + track.Title + " by " + artist,
-			"track":   track,
-			"status":  "searching",
-		})
-		streamEvent(streamWriter, "track_search", string(d))
-		entry, found, sErr := youtube.YTMusic_SearchTrack(track.Title, artist)
-
-		if sErr != nil {
-			log.Println("error searching track: ", sErr)
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "error searching for track",
-				"track":   track,
-				"success": false,
-				"status":  "error",
-			})
-			streamEvent(streamWriter, "track_search", string(d))
-
-			continue
-		}
-
-		if !found {
-			tracksNotFound++
-
-			d, _ := json.Marshal(fiber.Map{
-				"message": "track not found",
-				"track":   track,
-				"success": false,
-				"status":  "error",
-			})
-			streamEvent(streamWriter, "track_search", string(d))
-
-			continue
-		}
-
-		dt, _ := json.Marshal(fiber.Map{
-			"message": "Track found",
-			"track":   track,
-			"success": true,
-			"status":  "done",
-		})
-
-		streamEvent(streamWriter, "track_search", string(dt))
-		streamEvent(streamWriter, "info", "Adding track to playlist on YoutubeMusic")
-
-		addErr = youtube.AddTrackToPlaylist(youtubePlId, entry, playlistInfo.SessionId)
-
-		if addErr != nil {
-			streamEvent(streamWriter, "error", "error adding track to playlist")
-		}
-
-		tracksFound++
-		streamEvent(streamWriter, "info", "track added to playlist on YoutubeMusic")
-	}
-
-	d, _ := json.Marshal(fiber.Map{
-		"message": "Conversion process complete",
-		"info": fiber.Map{
-			"tracks_found":          tracksFound,
-			"tracks_not_found":      tracksNotFound,
-			"conversion_successful": tracksFound == len(tracks) && addErr == nil,
-		},
-	})
-
-	// tell client to close connection
-	streamEvent(streamWriter, "done", string(d))
-
-	return nil
-}
-
 func startSession(c *fiber.Ctx, pl *sessionPlaylist) error {
 	sess, err := session.Store.Get(c)
 
@@ -733,6 +394,10 @@ func startSession(c *fiber.Ctx, pl *sessionPlaylist) error {
 		return err
 	}
 
+	return saveSessionPlaylist(sess, pl)
+}
+
+func saveSessionPlaylist(sess *fibersession.Session, pl *sessionPlaylist) error {
 	sess.Set(session.PlaylistID, pl.Id)
 	sess.Set(session.PlaylistURL, pl.Url)
 	sess.Set(session.PlaylistSource, pl.Source)
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/api/services/tracklist"
+	"github.com/to-dy/music-playlist-converter/api/services/upload"
+	"github.com/to-dy/music-playlist-converter/api/stores/session"
+)
+
+// title of confirmed tracklists sent without one
+const defaultTracklistTitle = "Tracklist"
+
+/*
+parses a pasted tracklist, one track per line,
+the tracks are returned to be confirmed or corrected before ConfirmTracklist
+*/
+func ParseTracklist(c *fiber.Ctx) error {
+	bodyData := struct {
+		Text string `json:"text"`
+	}{}
+
+	if err := c.BodyParser(&bodyData); err != nil {
+		log.Println("Error parsing body - " + err.Error())
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if strings.TrimSpace(bodyData.Text) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("text is required", &ErrorSource{Parameter: "text"})},
+		})
+	}
+
+	tracks := tracklist.Parse(bodyData.Text)
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{Data: map[string]interface{}{
+		"tracks":      tracks,
+		"tracksCount": len(tracks),
+	}})
+}
+
+/*
+makes the confirmed tracks of a tracklist the playlist of the session,
+it is converted with ConvertPlaylist once the target is authorized.
+the text is parsed when no tracks are sent
+*/
+func ConfirmTracklist(c *fiber.Ctx) error {
+	bodyData := struct {
+		Title  string                   `json:"title"`
+		Text   string                   `json:"text"`
+		Tracks services.SearchTrackList `json:"tracks"`
+	}{}
+
+	if err := c.BodyParser(&bodyData); err != nil {
+		log.Println("Error parsing body - " + err.Error())
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	tracks := confirmedTracks(bodyData.Tracks)
+	if len(bodyData.Tracks) == 0 {
+		tracks = tracklist.Parse(bodyData.Text)
+	}
+
+	title := strings.TrimSpace(bodyData.Title)
+	if title == "" {
+		title = defaultTracklistTitle
+	}
+
+	sess, err := session.Store.Get(c)
+	if err != nil {
+		log.Println("Error getting session - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	uploaded, err := upload.FromTracks(title, "tracklist", tracks, sess.ID())
+
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ApiErrorResponse{
+			Errors: Errors{getBadRequestError("tracklist has no tracks", &ErrorSource{Parameter: "tracks"})},
+		})
+	}
+
+	if err := saveUploadSession(sess, uploaded); err != nil {
+		log.Println("Error saving upload - " + err.Error())
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&ApiOkResponse{
+		Data: map[string]interface{}{
+			"isPlaylistValid":      true,
+			"supportedConversions": services.ConversionTargets(upload.ProviderName),
+			"tracksCount":          len(tracks),
+		},
+	})
+}
+
+// keeps the title, artists, album, duration and ISRC of the tracks sent by the client, tracks without a title are dropped
+func confirmedTracks(tracks services.SearchTrackList) services.SearchTrackList {
+	confirmed := services.SearchTrackList{}
+
+	for _, track := range tracks {
+		if track == nil || strings.TrimSpace(track.Title) == "" {
+			continue
+		}
+
+		artists := shared_types.Artists{}
+
+		for _, artist := range track.Artists {
+			if name := strings.TrimSpace(artist.Name); name != "" {
+				artists = append(artists, shared_types.Artist{Name: name})
+			}
+		}
+
+		confirmed = append(confirmed, &services.SearchTrack{
+			Title:    strings.TrimSpace(track.Title),
+			Artists:  artists,
+			Duration: track.Duration,
+			Album:    shared_types.Album{Name: strings.TrimSpace(track.Album.Name)},
+			ISRC:     strings.TrimSpace(track.ISRC),
+		})
+	}
+
+	return confirmed
+}
@@ -3,19 +3,70 @@ package router
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
-	"github.com/gofiber/fiber/v2/middleware/session"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
 
 	"github.com/to-dy/music-playlist-converter/api/router/routes"
+	"github.com/to-dy/music-playlist-converter/api/services/formats"
+	"github.com/to-dy/music-playlist-converter/api/services/jobs"
+	"github.com/to-dy/music-playlist-converter/api/services/upload"
+	"github.com/to-dy/music-playlist-converter/api/stores/jobstore"
+	"github.com/to-dy/music-playlist-converter/api/stores/session"
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
 )
 
-var SessionStore *session.Store
+var SessionStore *fibersession.Store
+
+// MB, uploaded libraries are larger than the default limit of 4MB
+const defaultMaxUploadSize = 64
+
+const (
+	// days finished jobs, uploads, libraries and expired tokens are kept
+	defaultRetentionDays = 7
+	sweepInterval        = time.Hour
+)
 
 func SetupServer() {
-	app := fiber.New()
+	maxUploadSize := defaultMaxUploadSize
+
+	if value := os.Getenv("MAX_UPLOAD_SIZE_MB"); value != "" {
+		size, err := strconv.Atoi(value)
+
+		if err != nil || size <= 0 {
+			log.Fatal("MAX_UPLOAD_SIZE_MB must be a positive number")
+		}
+
+		maxUploadSize = size
+	}
+
+	if value := os.Getenv("MAX_ZIP_FILE_SIZE_MB"); value != "" {
+		size, err := strconv.Atoi(value)
+
+		if err != nil || size <= 0 {
+			log.Fatal("MAX_ZIP_FILE_SIZE_MB must be a positive number")
+		}
+
+		formats.MaxZipFileSize = int64(size) * 1024 * 1024
+	}
+
+	retentionDays := defaultRetentionDays
+
+	if value := os.Getenv("RETENTION_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+
+		if err != nil || days <= 0 {
+			log.Fatal("RETENTION_DAYS must be a positive number")
+		}
+
+		retentionDays = days
+	}
+
+	app := fiber.New(fiber.Config{BodyLimit: maxUploadSize * 1024 * 1024})
 
 	// server logging
 	app.Use(logger.New())
@@ -25,8 +76,60 @@ func SetupServer() {
 
 	routes.SetupRoutes(app)
 
+	// persist jobs and tokens so conversions resume after a restart
+	store, err := jobstore.OpenFromEnv()
+	if err != nil {
+		log.Fatal("Error opening job store - " + err.Error())
+	}
+
+	defer store.Close()
+
+	// tokens and sessions hold credentials, they are only persisted encrypted
+	if store.PersistsSecrets() {
+		if err := tokenstore.GlobalTokenStore.UsePersister(store); err != nil {
+			log.Fatal("Error loading persisted tokens - " + err.Error())
+		}
+
+		session.UseStorage(store.Sessions())
+	} else {
+		log.Println("TOKEN_ENCRYPTION_KEY is not set, sessions and tokens are kept in memory only and are lost on restart")
+	}
+
+	jobs.GlobalManager.UseStore(store)
+	upload.GlobalStore.UsePersister(store)
+
+	// background conversion workers
+	jobs.GlobalManager.Start()
+
+	go sweep(store, time.Duration(retentionDays)*24*time.Hour)
+
 	if err := app.Listen(":" + os.Getenv("PORT")); err != nil {
 		log.Fatal(err)
 	}
 
 }
+
+// deletes what outlived the retention, every sweepInterval
+func sweep(store *jobstore.Store, retention time.Duration) {
+	for {
+		before := time.Now().Add(-retention)
+
+		jobs.GlobalManager.Sweep(before)
+		tokenstore.GlobalTokenStore.DeleteExpired(before)
+
+		// uploads are kept while a conversion of them is unfinished
+		err := upload.GlobalStore.Sweep(before, func(id string) bool {
+			return jobs.GlobalManager.IsConverting(upload.ProviderName, id)
+		})
+
+		if err != nil {
+			log.Println("Error deleting old uploads - " + err.Error())
+		}
+
+		if err := store.DeleteExpiredSessions(); err != nil {
+			log.Println("Error deleting expired sessions - " + err.Error())
+		}
+
+		time.Sleep(sweepInterval)
+	}
+}
@@ -17,4 +17,28 @@ func SetupAuthRoutes(router fiber.Router) {
 	authRouter.Get("/youtube", handlers.InitiateOAuthFlow)
 
 	authRouter.Get("/youtube_callback", handlers.HandleOAuthCallback)
+
+	authRouter.Get("/deezer", handlers.InitiateOAuthFlow)
+
+	authRouter.Get("/deezer_callback", handlers.HandleOAuthCallback)
+
+	authRouter.Get("/soundcloud", handlers.InitiateOAuthFlow)
+
+	authRouter.Get("/soundcloud_callback", handlers.HandleOAuthCallback)
+
+	authRouter.Post("/subsonic", handlers.SubsonicLogin)
+
+	authRouter.Post("/jellyfin", handlers.ConnectMediaServer)
+
+	authRouter.Post("/plex", handlers.ConnectMediaServer)
+
+	authRouter.Post("/local", handlers.ConnectMediaServer)
+
+	authRouter.Get("/tidal", handlers.InitiateTidalAuth)
+
+	authRouter.Get("/tidal_callback", handlers.TidalCallback)
+
+	authRouter.Get("/apple-music", handlers.InitiateAppleMusicAuth)
+
+	authRouter.Post("/apple-music_callback", handlers.AppleMusicCallback)
 }
@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/handlers"
+)
+
+func SetupJobRoutes(router fiber.Router) {
+
+	jobRouter := router.Group("/jobs")
+
+	jobRouter.Get("/events/schema", handlers.GetEventsSchema)
+
+	jobRouter.Get("/:id", handlers.GetJob)
+
+	jobRouter.Get("/:id/stream", handlers.StreamJob)
+
+	jobRouter.Get("/:id/missing", handlers.GetJobMissingTracks)
+
+	jobRouter.Get("/:id/report", handlers.GetJobReport)
+
+	jobRouter.Get("/:id/export", handlers.ExportJobPlaylist)
+
+	jobRouter.Post("/:id/cancel", handlers.CancelJob)
+
+	jobRouter.Post("/:id/pause", handlers.PauseJob)
+
+	jobRouter.Post("/:id/resume", handlers.ResumeJob)
+}
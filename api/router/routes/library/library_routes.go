@@ -0,0 +1,20 @@
+package library
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/handlers"
+)
+
+func SetupLibraryRoutes(router fiber.Router) {
+
+	libraryRouter := router.Group("/library")
+
+	libraryRouter.Post("/", handlers.UploadLibrary)
+
+	libraryRouter.Get("/:id", handlers.GetLibrary)
+
+	libraryRouter.Post("/:id/playlists/:playlistId", handlers.SelectLibraryPlaylist)
+
+	libraryRouter.Post("/:id/convert", handlers.ConvertLibraryPlaylists)
+}
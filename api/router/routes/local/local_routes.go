@@ -0,0 +1,14 @@
+package local
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/handlers"
+)
+
+func SetupLocalRoutes(router fiber.Router) {
+
+	localRouter := router.Group("/local")
+
+	localRouter.Post("/scan", handlers.ScanLocalMusic)
+}
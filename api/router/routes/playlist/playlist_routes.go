@@ -17,4 +17,12 @@ func SetupPlaylistRoutes(router fiber.Router) {
 	playlistRouter.Post("/convert/start", handlers.ConvertPlaylist)
 
 	playlistRouter.Get("/convert/start/stream", handlers.StreamConvertPlaylist)
+
+	playlistRouter.Get("/export", handlers.ExportPlaylist)
+
+	playlistRouter.Post("/csv/columns", handlers.ReadCSVColumns)
+
+	playlistRouter.Post("/tracklist/parse", handlers.ParseTracklist)
+
+	playlistRouter.Post("/tracklist", handlers.ConfirmTracklist)
 }
@@ -4,6 +4,9 @@ import (
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/to-dy/music-playlist-converter/api/router/routes/auth"
+	"github.com/to-dy/music-playlist-converter/api/router/routes/jobs"
+	"github.com/to-dy/music-playlist-converter/api/router/routes/library"
+	"github.com/to-dy/music-playlist-converter/api/router/routes/local"
 	"github.com/to-dy/music-playlist-converter/api/router/routes/playlist"
 )
 
@@ -12,4 +15,7 @@ func SetupRoutes(app *fiber.App) {
 
 	auth.SetupAuthRoutes(apiRoutes)
 	playlist.SetupPlaylistRoutes(apiRoutes)
+	jobs.SetupJobRoutes(apiRoutes)
+	local.SetupLocalRoutes(apiRoutes)
+	library.SetupLibraryRoutes(apiRoutes)
 }
@@ -0,0 +1,421 @@
+package applemusic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/initializers"
+)
+
+const defaultBaseURL = "https://api.music.apple.com"
+
+// apple music returns at most 25 search results per request
+const maxSearchLimit = 25
+
+var (
+	// overridden with APPLE_MUSIC_BASE_URL e.g to run against a local fake of the api
+	appleMusicBaseURL = defaultBaseURL
+	// storefront used for catalog searches
+	defaultStorefront = "us"
+
+	teamId         string
+	keyId          string
+	privateKeyPath string
+)
+
+func init() {
+	initializers.LoadEnv()
+
+	if baseURL := os.Getenv("APPLE_MUSIC_BASE_URL"); baseURL != "" {
+		appleMusicBaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+
+	if storefront := os.Getenv("APPLE_MUSIC_STOREFRONT"); storefront != "" {
+		defaultStorefront = storefront
+	}
+
+	teamId = os.Getenv("APPLE_MUSIC_TEAM_ID")
+	keyId = os.Getenv("APPLE_MUSIC_KEY_ID")
+	privateKeyPath = os.Getenv("APPLE_MUSIC_PRIVATE_KEY_PATH")
+}
+
+// catalog or library song
+type Song struct {
+	Id         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Name             string `json:"name"`
+		ArtistName       string `json:"artistName"`
+		AlbumName        string `json:"albumName"`
+		DurationInMillis int64  `json:"durationInMillis"`
+		// only set on catalog songs
+		ISRC       string `json:"isrc"`
+		PlayParams struct {
+			// set on library songs added from the catalog
+			CatalogId string `json:"catalogId"`
+		} `json:"playParams"`
+	} `json:"attributes"`
+	Relationships struct {
+		// library songs include their catalog song with include=catalog
+		Catalog struct {
+			Data []*Song `json:"data"`
+		} `json:"catalog"`
+	} `json:"relationships"`
+}
+
+type SongsResponse struct {
+	Data []*Song `json:"data"`
+	// path of the next page e.g /v1/catalog/us/playlists/{id}/tracks?offset=100
+	Next string `json:"next"`
+	Meta struct {
+		Total int `json:"total"`
+	} `json:"meta"`
+}
+
+type Playlist struct {
+	Id         string `json:"id"`
+	Attributes struct {
+		Name string `json:"name"`
+	} `json:"attributes"`
+	Relationships struct {
+		Tracks SongsResponse `json:"tracks"`
+	} `json:"relationships"`
+}
+
+type PlaylistsResponse struct {
+	Data []*Playlist `json:"data"`
+}
+
+type SearchResponse struct {
+	Results struct {
+		Songs SongsResponse `json:"songs"`
+	} `json:"results"`
+}
+
+/*
+sends a request to the apple music api and decodes the JSON response into out
+
+the developer token is always sent, the Music User Token of the session only when sessionId is not empty
+*/
+func request(method string, path string, sessionId string, body interface{}, out interface{}) (int, error) {
+	developerToken, err := DeveloperToken()
+
+	if err != nil {
+		return 0, err
+	}
+
+	cli := fiber.Client{}
+
+	var agent *fiber.Agent
+
+	switch method {
+	case fiber.MethodPost:
+		agent = cli.Post(appleMusicBaseURL + path)
+	default:
+		agent = cli.Get(appleMusicBaseURL + path)
+	}
+
+	agent.Set("Authorization", "Bearer "+developerToken)
+
+	if sessionId != "" {
+		userToken, err := getUserToken(sessionId)
+
+		if err != nil {
+			return 0, err
+		}
+
+		agent.Set("Music-User-Token", userToken)
+	}
+
+	if body != nil {
+		agent.JSON(body)
+	}
+
+	status, b, errs := agent.Bytes()
+
+	if len(errs) > 0 {
+		return 0, errs[0]
+	}
+
+	// created playlist tracks respond with 204 and no body
+	if out != nil && len(b) > 0 && status < http.StatusBadRequest {
+		if err := json.Unmarshal(b, out); err != nil {
+			return status, err
+		}
+	}
+
+	return status, nil
+}
+
+/*
+library playlist ids start with "p.", catalog playlist ids are prefixed with their storefront e.g "us/pl.u-xxx"
+*/
+func parsePlaylistId(id string) (storefront string, playlistId string, library bool) {
+	if strings.HasPrefix(id, "p.") {
+		return "", id, true
+	}
+
+	if storefront, playlistId, found := strings.Cut(id, "/"); found {
+		return storefront, playlistId, false
+	}
+
+	return defaultStorefront, id, false
+}
+
+func playlistPath(id string) (path string, library bool) {
+	storefront, playlistId, library := parsePlaylistId(id)
+
+	if library {
+		return "/v1/me/library/playlists/" + url.PathEscape(playlistId), true
+	}
+
+	return "/v1/catalog/" + url.PathEscape(storefront) + "/playlists/" + url.PathEscape(playlistId), false
+}
+
+// returns nil if the playlist does not exist, library playlists need the Music User Token of the session
+func FindPlaylist(id string, sessionId string) (*Playlist, error) {
+	path, library := playlistPath(id)
+
+	if !library {
+		sessionId = ""
+	}
+
+	var bodyData PlaylistsResponse
+
+	status, err := request(fiber.MethodGet, path+"?include=tracks", sessionId, nil, &bodyData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.New("error verifying playlist | status code: " + fmt.Sprint(status))
+	}
+
+	if len(bodyData.Data) == 0 {
+		return nil, nil
+	}
+
+	return bodyData.Data[0], nil
+}
+
+// returns the number of tracks of a playlist returned by FindPlaylist
+func PlaylistTrackCount(playlist *Playlist) int {
+	tracks := playlist.Relationships.Tracks
+
+	if tracks.Meta.Total > 0 {
+		return tracks.Meta.Total
+	}
+
+	return len(tracks.Data)
+}
+
+/*
+fetches the playlist tracks page by page until all tracks or the allowed number of conversions are fetched
+
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string, sessionId string) (tracks []*Song, truncated bool, err error) {
+	path, library := playlistPath(id)
+
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	next := path + "/tracks?limit=100"
+
+	if library {
+		// the isrc is only available on the catalog song
+		next += "&include=catalog"
+	} else {
+		sessionId = ""
+	}
+
+	tracks = []*Song{}
+
+	for next != "" {
+		var bodyData SongsResponse
+
+		status, err := request(fiber.MethodGet, next, sessionId, nil, &bodyData)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		if status != http.StatusOK {
+			return nil, false, errors.New("error getting playlist tracks | status code: " + fmt.Sprint(status))
+		}
+
+		tracks = append(tracks, bodyData.Data...)
+		next = bodyData.Next
+
+		if library && next != "" && !strings.Contains(next, "include=") {
+			next += "&include=catalog"
+		}
+
+		// allowedNumberOfConversions = 0 means convert all tracks
+		if allowedNumberOfConversions != 0 && len(tracks) >= allowedNumberOfConversions {
+			truncated = len(tracks) > allowedNumberOfConversions || next != ""
+			tracks = tracks[0:allowedNumberOfConversions]
+
+			break
+		}
+	}
+
+	return tracks, truncated, nil
+}
+
+func ToSearchTrackList(songs []*Song) services.SearchTrackList {
+	searchTrackList := make(services.SearchTrackList, 0, len(songs))
+
+	for _, song := range songs {
+		searchTrackList = append(searchTrackList, toSearchTrack(song))
+	}
+
+	return searchTrackList
+}
+
+// the id of the returned track is the catalog song id when known, library playlists only accept catalog songs
+func toSearchTrack(song *Song) *services.SearchTrack {
+	attributes := song.Attributes
+
+	track := &services.SearchTrack{
+		Id:       song.Id,
+		Title:    attributes.Name,
+		Duration: attributes.DurationInMillis,
+		Album:    shared_types.Album{Name: attributes.AlbumName},
+		ISRC:     attributes.ISRC,
+	}
+
+	if attributes.ArtistName != "" {
+		track.Artists = shared_types.Artists{{Name: attributes.ArtistName}}
+	}
+
+	if catalogId := attributes.PlayParams.CatalogId; catalogId != "" {
+		track.Id = catalogId
+	}
+
+	if catalog := song.Relationships.Catalog.Data; len(catalog) > 0 {
+		track.Id = catalog[0].Id
+
+		if track.ISRC == "" {
+			track.ISRC = catalog[0].Attributes.ISRC
+		}
+	}
+
+	return track
+}
+
+// searches the catalog of the default storefront and returns at most limit songs in the order apple ranks them
+func SearchTracks(query string, artist string, limit int) ([]*Song, error) {
+	term := query
+	if artist != "" {
+		term += " " + artist
+	}
+
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	path := "/v1/catalog/" + url.PathEscape(defaultStorefront) + "/search?types=songs&limit=" + fmt.Sprint(limit) + "&term=" + url.QueryEscape(term)
+
+	var bodyData SearchResponse
+
+	status, err := request(fiber.MethodGet, path, "", nil, &bodyData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusOK {
+		return bodyData.Results.Songs.Data, nil
+	}
+
+	return nil, errors.New("error searching track | status code: " + fmt.Sprint(status))
+}
+
+// looks up a catalog song by its isrc code
+func SearchISRC(isrc string) (*Song, bool, error) {
+	path := "/v1/catalog/" + url.PathEscape(defaultStorefront) + "/songs?filter[isrc]=" + url.QueryEscape(isrc)
+
+	var bodyData SongsResponse
+
+	status, err := request(fiber.MethodGet, path, "", nil, &bodyData)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if status == http.StatusOK {
+		if len(bodyData.Data) > 0 {
+			return bodyData.Data[0], true, nil
+		}
+
+		return nil, false, nil
+	}
+
+	return nil, false, errors.New("error searching isrc | status code: " + fmt.Sprint(status))
+}
+
+// creates a playlist in the library of the session user and returns its id
+func CreatePlaylist(name string, sessionId string) (string, error) {
+	body := map[string]interface{}{
+		"attributes": map[string]string{
+			"name": name,
+		},
+	}
+
+	var bodyData PlaylistsResponse
+
+	status, err := request(fiber.MethodPost, "/v1/me/library/playlists", sessionId, body, &bodyData)
+
+	if err != nil {
+		return "", err
+	}
+
+	if (status == http.StatusCreated || status == http.StatusOK) && len(bodyData.Data) > 0 {
+		return bodyData.Data[0].Id, nil
+	}
+
+	return "", errors.New("error creating playlist | status code: " + fmt.Sprint(status))
+}
+
+// adds catalog songs to a library playlist
+func AddTracksToPlaylist(playlistId string, songIds []string, sessionId string) error {
+	data := make([]map[string]string, 0, len(songIds))
+
+	for _, id := range songIds {
+		data = append(data, map[string]string{"id": id, "type": "songs"})
+	}
+
+	path := "/v1/me/library/playlists/" + url.PathEscape(playlistId) + "/tracks"
+
+	status, err := request(fiber.MethodPost, path, sessionId, map[string]interface{}{"data": data}, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusNoContent || status == http.StatusOK || status == http.StatusCreated {
+		return nil
+	}
+
+	return errors.New("error adding tracks to playlist | status code: " + fmt.Sprint(status))
+}
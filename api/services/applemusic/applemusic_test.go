@@ -0,0 +1,276 @@
+package applemusic
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+)
+
+// fake of the apple music api with a catalog playlist and a library playlist of 250 songs each
+type fakeAPI struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// request uris in order
+	requests []string
+}
+
+func catalogSong(i int) *Song {
+	song := &Song{Id: strconv.Itoa(i), Type: "songs"}
+	song.Attributes.Name = "Song " + strconv.Itoa(i)
+	song.Attributes.ArtistName = "Artist"
+	song.Attributes.DurationInMillis = 200000
+	song.Attributes.ISRC = fmt.Sprintf("USABC%07d", i)
+
+	return song
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	f := &fakeAPI{}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.URL.RequestURI())
+		f.mu.Unlock()
+
+		// the developer token is a signed jwt
+		if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); strings.Count(token, ".") != 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		library := strings.HasPrefix(r.URL.Path, "/v1/me/")
+
+		if library && r.Header.Get("Music-User-Token") != "user-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		query := r.URL.Query()
+
+		switch {
+		case r.URL.Path == "/v1/catalog/us/search":
+			limit, _ := strconv.Atoi(query.Get("limit"))
+			songs := SongsResponse{}
+
+			for i := 0; i < limit; i++ {
+				songs.Data = append(songs.Data, catalogSong(i))
+			}
+
+			writeJSON(w, map[string]any{"results": map[string]any{"songs": songs}})
+
+		case r.URL.Path == "/v1/catalog/us/songs":
+			songs := SongsResponse{Data: []*Song{}}
+
+			if query.Get("filter[isrc]") == "USABC0000007" {
+				songs.Data = append(songs.Data, catalogSong(7))
+			}
+
+			writeJSON(w, songs)
+
+		case r.URL.Path == "/v1/catalog/us/playlists/pl.mix/tracks" || r.URL.Path == "/v1/me/library/playlists/p.mine/tracks":
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			page := SongsResponse{Data: []*Song{}}
+			page.Meta.Total = 250
+
+			for i := offset; i < offset+100 && i < 250; i++ {
+				song := catalogSong(i)
+
+				if library {
+					// library songs hold their catalog song when asked to include it
+					song = &Song{Id: "i." + strconv.Itoa(i), Type: "library-songs"}
+					song.Attributes.Name = "Song " + strconv.Itoa(i)
+
+					if query.Get("include") == "catalog" {
+						song.Relationships.Catalog.Data = []*Song{catalogSong(i)}
+					}
+				}
+
+				page.Data = append(page.Data, song)
+			}
+
+			if offset+100 < 250 {
+				page.Next = r.URL.Path + "?offset=" + strconv.Itoa(offset+100)
+			}
+
+			writeJSON(w, page)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(f.Close)
+
+	prevBaseURL := appleMusicBaseURL
+	appleMusicBaseURL = f.URL
+	t.Cleanup(func() { appleMusicBaseURL = prevBaseURL })
+
+	useTestDeveloperKey(t)
+
+	return f
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// signs developer tokens with a new key, the cached token of other tests is dropped
+func useTestDeveloperKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "AuthKey.p8")
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	prevTeamId, prevKeyId, prevPath := teamId, keyId, privateKeyPath
+	teamId, keyId, privateKeyPath = "TEAM", "KEY", path
+
+	expireDeveloperToken()
+
+	t.Cleanup(func() {
+		teamId, keyId, privateKeyPath = prevTeamId, prevKeyId, prevPath
+		expireDeveloperToken()
+	})
+}
+
+func expireDeveloperToken() {
+	tokenstore.GlobalTokenStore.SetToken(string(tokenstore.APPLE_MUSIC_DT), tokenstore.TokenEntry{Token: &oauth2.Token{}})
+}
+
+func TestSearchTracks(t *testing.T) {
+	f := newFakeAPI(t)
+
+	tests := []struct {
+		query  string
+		artist string
+		limit  int
+		want   int
+		term   string
+	}{
+		{query: "Song", artist: "Artist", limit: 5, want: 5, term: "Song+Artist"},
+		{query: "Song & Dance", limit: 3, want: 3, term: "Song+%26+Dance"},
+		// apple returns at most 25 songs per search
+		{query: "Song", limit: 50, want: maxSearchLimit, term: "Song"},
+	}
+
+	for _, tt := range tests {
+		songs, err := SearchTracks(tt.query, tt.artist, tt.limit)
+
+		if err != nil || len(songs) != tt.want {
+			t.Errorf("SearchTracks(%q, %q, %d) = %d songs, %v, want %d", tt.query, tt.artist, tt.limit, len(songs), err, tt.want)
+			continue
+		}
+
+		if last := f.requests[len(f.requests)-1]; !strings.HasSuffix(last, "&term="+tt.term) {
+			t.Errorf("SearchTracks() requested %s, want the term %s", last, tt.term)
+		}
+	}
+}
+
+func TestSearchISRC(t *testing.T) {
+	newFakeAPI(t)
+
+	tests := []struct {
+		isrc  string
+		found bool
+	}{
+		{isrc: "USABC0000007", found: true},
+		{isrc: "USABC9999999", found: false},
+	}
+
+	for _, tt := range tests {
+		song, found, err := SearchISRC(tt.isrc)
+
+		if err != nil || found != tt.found {
+			t.Errorf("SearchISRC(%s) = %v, %v, want found %v", tt.isrc, found, err, tt.found)
+			continue
+		}
+
+		if found && toSearchTrack(song).ISRC != tt.isrc {
+			t.Errorf("SearchISRC(%s) = %+v", tt.isrc, song.Attributes)
+		}
+	}
+}
+
+func TestGetPlaylistTracks(t *testing.T) {
+	f := newFakeAPI(t)
+	StoreUserToken("user-token", "apple-session")
+
+	tests := []struct {
+		name      string
+		id        string
+		allowed   string
+		want      int
+		truncated bool
+		requests  int
+	}{
+		{name: "catalog playlist", id: "us/pl.mix", want: 250, requests: 3},
+		{name: "catalog playlist of the default storefront", id: "pl.mix", want: 250, requests: 3},
+		{name: "library playlist", id: "p.mine", want: 250, requests: 3},
+		{name: "stops at the allowed number of conversions", id: "pl.mix", allowed: "150", want: 150, truncated: true, requests: 2},
+		{name: "allowed number of conversions on a page boundary", id: "pl.mix", allowed: "100", want: 100, truncated: true, requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALLOWED_NUMBER_OF_CONVERSIONS", tt.allowed)
+			f.requests = nil
+
+			songs, truncated, err := GetPlaylistTracks(tt.id, "apple-session")
+
+			if err != nil || len(songs) != tt.want || truncated != tt.truncated {
+				t.Fatalf("GetPlaylistTracks() = %d songs, %v, %v, want %d, %v", len(songs), truncated, err, tt.want, tt.truncated)
+			}
+
+			if len(f.requests) != tt.requests {
+				t.Errorf("GetPlaylistTracks() sent %d requests, want %d: %v", len(f.requests), tt.requests, f.requests)
+			}
+
+			tracks := ToSearchTrackList(songs)
+
+			for i, track := range tracks {
+				// library songs are converted with their catalog id and isrc
+				if track.Id != strconv.Itoa(i) || track.ISRC != fmt.Sprintf("USABC%07d", i) {
+					t.Fatalf("track %d = %+v, want the catalog song", i, track)
+				}
+			}
+		})
+	}
+}
+
+func TestLibraryPlaylistNeedsTheUserToken(t *testing.T) {
+	newFakeAPI(t)
+
+	if _, _, err := GetPlaylistTracks("p.mine", "no-user-token"); err != ErrUserTokenNotFound {
+		t.Errorf("GetPlaylistTracks() error = %v, want ErrUserTokenNotFound", err)
+	}
+}
@@ -0,0 +1,134 @@
+package applemusic
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "apple-music"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "Apple Music"
+}
+
+func (p *provider) Hosts() []string {
+	return []string{"music.apple.com"}
+}
+
+/*
+expects urls in the format https://music.apple.com/{storefront}/playlist/{name}/{id}
+or https://music.apple.com/library/playlist/{id} for library playlists
+*/
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	if len(pathParts) < 3 || pathParts[1] != "playlist" {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	id := pathParts[len(pathParts)-1]
+
+	if pathParts[0] == "library" {
+		if !strings.HasPrefix(id, "p.") {
+			return "", services.ErrInvalidPlaylistURL
+		}
+
+		return id, nil
+	}
+
+	if !strings.HasPrefix(id, "pl.") {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	return pathParts[0] + "/" + id, nil
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := FindPlaylist(id, sessionId)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         id,
+		Title:      playlist.Attributes.Name,
+		Url:        p.PlaylistURL(id),
+		TrackCount: PlaylistTrackCount(playlist),
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	songs, truncated, err := GetPlaylistTracks(id, sessionId)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(songs), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	songs, err := SearchTracks(track.Title, track.MainArtist(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSearchTrackList(songs), nil
+}
+
+func (p *provider) SearchISRC(isrc string) (*services.SearchTrack, bool, error) {
+	song, found, err := SearchISRC(isrc)
+
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	return toSearchTrack(song), true, nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name, sessionId)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	ids := make([]string, 0, len(tracks))
+
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+
+	return AddTracksToPlaylist(playlistId, ids, sessionId)
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	storefront, playlistId, library := parsePlaylistId(id)
+
+	if library {
+		return "https://music.apple.com/library/playlist/" + playlistId
+	}
+
+	return "https://music.apple.com/" + storefront + "/playlist/" + playlistId
+}
+
+// library songs have no public url
+func (p *provider) TrackURL(id string) string {
+	if strings.HasPrefix(id, "i.") {
+		return ""
+	}
+
+	return "https://music.apple.com/song/" + id
+}
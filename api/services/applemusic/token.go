@@ -0,0 +1,158 @@
+package applemusic
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+)
+
+// apple accepts developer tokens valid for up to 6 months, keep them short lived and sign new ones when needed
+const developerTokenLifetime = 12 * time.Hour
+
+// music user tokens are valid for about 6 months, apple doesn't tell when they expire
+const userTokenLifetime = 180 * 24 * time.Hour
+
+var ErrUserTokenNotFound = errors.New("apple music user token not found, authorize Apple Music first")
+
+// returns the cached developer token, a new one is signed when it expired
+func DeveloperToken() (string, error) {
+	token, tokenValid := tokenstore.GlobalTokenStore.GetToken(string(tokenstore.APPLE_MUSIC_DT))
+
+	if tokenValid {
+		return token.AccessToken, nil
+	}
+
+	signed, expiry, err := signDeveloperToken(time.Now())
+
+	if err != nil {
+		return "", err
+	}
+
+	tokenstore.GlobalTokenStore.SetToken(string(tokenstore.APPLE_MUSIC_DT), tokenstore.TokenEntry{
+		Token: &oauth2.Token{
+			AccessToken: signed,
+			Expiry:      expiry,
+		},
+		// renew a bit before apple rejects it
+		Expiration: expiry.Add(-5 * time.Minute),
+	})
+
+	return signed, nil
+}
+
+/*
+signs an ES256 JWT with the MusicKit private key (.p8) at APPLE_MUSIC_PRIVATE_KEY_PATH
+
+https://developer.apple.com/documentation/applemusicapi/generating_developer_tokens
+*/
+func signDeveloperToken(now time.Time) (string, time.Time, error) {
+	if teamId == "" || keyId == "" || privateKeyPath == "" {
+		return "", time.Time{}, errors.New("apple music developer token is not configured, set APPLE_MUSIC_TEAM_ID, APPLE_MUSIC_KEY_ID and APPLE_MUSIC_PRIVATE_KEY_PATH")
+	}
+
+	key, err := loadPrivateKey(privateKeyPath)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiry := now.Add(developerTokenLifetime)
+
+	header, err := json.Marshal(map[string]string{
+		"alg": "ES256",
+		"kid": keyId,
+	})
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": teamId,
+		"iat": now.Unix(),
+		"exp": expiry.Unix(),
+	})
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// JWS expects the raw r || s pair, each left padded to the curve size
+	size := (key.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), expiry, nil
+}
+
+func loadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, errors.New("apple music private key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+
+	if !ok {
+		return nil, errors.New("apple music private key is not an ECDSA key")
+	}
+
+	return ecKey, nil
+}
+
+// keeps the Music User Token MusicKit JS returned for the session
+func StoreUserToken(userToken string, sessionId string) {
+	prefix := sessionId + "_"
+
+	tokenstore.GlobalTokenStore.SetToken(prefix+string(tokenstore.APPLE_MUSIC_UT), tokenstore.TokenEntry{
+		Token: &oauth2.Token{
+			AccessToken: userToken,
+		},
+		Expiration: time.Now().Add(userTokenLifetime),
+	})
+}
+
+// music user tokens can't be refreshed, the user has to authorize again once it expired
+func getUserToken(sessionId string) (string, error) {
+	token, tokenValid := tokenstore.GlobalTokenStore.GetToken(sessionId + "_" + string(tokenstore.APPLE_MUSIC_UT))
+
+	if !tokenValid {
+		return "", ErrUserTokenNotFound
+	}
+
+	return token.AccessToken, nil
+}
@@ -0,0 +1,490 @@
+package converter
+
+// provider agnostic playlist conversion pipeline
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/match"
+)
+
+// found tracks are added to the target playlist in batches of this size
+const addBatchSize = 50
+
+// returned by Run when the conversion stopped because its gate got paused
+var ErrPaused = errors.New("conversion paused")
+
+// receives the JSON encoded conversion events e.g to stream them to the client, see Emit
+type Emitter func(event string, data string)
+
+type Conversion struct {
+	Source services.Provider
+	Target services.Provider
+	// id of the playlist on the source provider
+	PlaylistId string
+	// title of the playlist created on the target provider
+	Title     string
+	SessionId string
+	// defaults to match.DefaultConfig
+	Match *match.Config
+	// progress of a previous run to resume from, nil starts a new conversion
+	State *State
+	// stops the conversion between tracks so it can be resumed later, optional
+	Gate *Gate
+	/*
+		called every time the state changes so it can be persisted,
+		changed holds the indexes of the results created or updated since the last call
+	*/
+	Checkpoint func(state *State, changed []int)
+}
+
+// resumable progress of a conversion
+type State struct {
+	// source playlist tracks, nil until fetched
+	Tracks    services.SearchTrackList
+	Truncated bool
+	// id of the playlist created on the target provider, empty until created
+	PlaylistId string
+	// one result per searched track in source playlist order
+	Results []*TrackResult
+}
+
+type TrackStatus string
+
+const (
+	TrackFound    TrackStatus = "found"
+	TrackNotFound TrackStatus = "not_found"
+	TrackError    TrackStatus = "error"
+)
+
+type TrackResult struct {
+	Source     *services.SearchTrack `json:"source"`
+	Match      *services.SearchTrack `json:"match,omitempty"`
+	Confidence float64               `json:"confidence"`
+	Strategy   match.Strategy        `json:"strategy,omitempty"`
+	Status     TrackStatus           `json:"status"`
+	// true once the match has been written to the target playlist
+	Added bool `json:"added"`
+	/*
+		true while the batch of the match is being added, a run interrupted meanwhile
+		doesn't know if the target got the batch so its playlist is checked before adding it again
+	*/
+	Adding bool `json:"adding,omitempty"`
+}
+
+type Result struct {
+	PlaylistId     string
+	PlaylistUrl    string
+	TracksFound    int
+	TracksNotFound int
+	// true when the source playlist had more tracks than the allowed number of conversions
+	Truncated  bool
+	Successful bool
+	Tracks     []*TrackResult
+}
+
+/*
+converts the source playlist to a new playlist on the target provider
+
+when conv.State is set the conversion resumes from it, tracks already added are not added again and
+a batch interrupted while being added is checked against the target playlist first.
+cancelling ctx stops the conversion before the next track, the partial result is returned with the context error.
+pausing conv.Gate stops it the same way with ErrPaused, the progress is kept by Checkpoint so it is run again from it.
+progress is reported to emit, a `done` event is emitted last unless the conversion got paused
+*/
+func Run(ctx context.Context, conv *Conversion, emit Emitter) (*Result, error) {
+	if emit == nil {
+		emit = func(string, string) {}
+	}
+
+	checkpoint := conv.Checkpoint
+	if checkpoint == nil {
+		checkpoint = func(*State, []int) {}
+	}
+
+	source := conv.Source
+	target := conv.Target
+
+	matchConfig := conv.Match
+	if matchConfig == nil {
+		matchConfig = match.DefaultConfig()
+	}
+
+	state := conv.State
+	if state == nil {
+		state = &State{}
+	}
+
+	tracker := newProgressTracker(state)
+
+	if err := proceed(ctx, conv, state, tracker, emit); err != nil {
+		return interrupt(emit, conv, state, tracker, err)
+	}
+
+	if state.Tracks == nil {
+		Emit(emit, &PhaseEvent{
+			Phase:   PhaseFetchingTracks,
+			Message: "Getting playlist tracks from " + source.DisplayName(),
+		})
+
+		tracks, truncated, getTracksErr := source.GetPlaylistTracks(conv.PlaylistId, conv.SessionId)
+
+		if getTracksErr != nil {
+			log.Println(source.Name()+" GetPlaylistTracks error", getTracksErr)
+
+			return abort(emit, "error getting playlist on "+source.DisplayName(), getTracksErr)
+		}
+
+		state.Tracks = tracks
+		state.Truncated = truncated
+		checkpoint(state, nil)
+	} else {
+		Emit(emit, &PhaseEvent{
+			Phase:    PhaseResuming,
+			Message:  "Resuming conversion from track " + strconv.Itoa(len(state.Results)+1) + " of " + strconv.Itoa(len(state.Tracks)),
+			Progress: tracker.progress(state),
+		})
+	}
+
+	if state.PlaylistId == "" {
+		if err := proceed(ctx, conv, state, tracker, emit); err != nil {
+			return interrupt(emit, conv, state, tracker, err)
+		}
+
+		Emit(emit, &PhaseEvent{
+			Phase:   PhaseCreatingPlaylist,
+			Message: "Creating playlist on " + target.DisplayName(),
+		})
+
+		playlistId, createErr := target.CreatePlaylist(conv.Title, conv.SessionId)
+
+		if createErr != nil {
+			log.Println(target.Name()+" CreatePlaylist error", createErr)
+
+			return abort(emit, "error creating playlist on "+target.DisplayName(), createErr)
+		}
+
+		state.PlaylistId = playlistId
+		checkpoint(state, nil)
+	}
+
+	Emit(emit, &PhaseEvent{
+		Phase:    PhaseSearching,
+		Message:  "Searching tracks on " + target.DisplayName(),
+		Progress: tracker.progress(state),
+	})
+
+	reconcile(conv, state, checkpoint)
+
+	// found tracks not added yet, including the ones left by an interrupted run
+	pending := []int{}
+	for i, result := range state.Results {
+		if result.Status == TrackFound && !result.Added && !result.Adding {
+			pending = append(pending, i)
+		}
+	}
+
+	var addErr error
+
+	for i := len(state.Results); i < len(state.Tracks); i++ {
+		if err := proceed(ctx, conv, state, tracker, emit); err != nil {
+			return interrupt(emit, conv, state, tracker, err)
+		}
+
+		result := searchTrack(target, matchConfig, state, i, tracker, emit)
+		checkpoint(state, []int{i})
+
+		if result.Status == TrackFound {
+			pending = append(pending, i)
+		}
+
+		if len(pending) >= addBatchSize {
+			if err := proceed(ctx, conv, state, tracker, emit); err != nil {
+				return interrupt(emit, conv, state, tracker, err)
+			}
+
+			if err := addTracks(conv, state, pending, tracker, emit, checkpoint); err != nil {
+				addErr = err
+			}
+
+			pending = pending[:0]
+		}
+	}
+
+	if len(pending) > 0 {
+		if err := proceed(ctx, conv, state, tracker, emit); err != nil {
+			return interrupt(emit, conv, state, tracker, err)
+		}
+
+		if err := addTracks(conv, state, pending, tracker, emit, checkpoint); err != nil {
+			addErr = err
+		}
+	}
+
+	result := summarize(conv, state)
+
+	done := &DoneEvent{
+		Status:      DoneCompleted,
+		Message:     "Conversion process complete",
+		PlaylistUrl: result.PlaylistUrl,
+		Truncated:   result.Truncated,
+		Successful:  result.Successful,
+		Progress:    tracker.progress(state),
+	}
+
+	// the job is stored as failed, clients are told the same
+	if addErr != nil {
+		done.Status = DoneFailed
+		done.Message = "Conversion process failed adding tracks to playlist on " + target.DisplayName()
+		done.Successful = false
+	}
+
+	Emit(emit, done)
+
+	return result, addErr
+}
+
+func summarize(conv *Conversion, state *State) *Result {
+	result := &Result{
+		PlaylistId: state.PlaylistId,
+		Truncated:  state.Truncated,
+		Tracks:     state.Results,
+	}
+
+	if state.PlaylistId != "" {
+		result.PlaylistUrl = conv.Target.PlaylistURL(state.PlaylistId)
+	}
+
+	added := 0
+	for _, track := range state.Results {
+		if track.Status == TrackFound {
+			result.TracksFound++
+		}
+
+		if track.Added {
+			added++
+		}
+	}
+
+	result.TracksNotFound = len(state.Results) - result.TracksFound
+	result.Successful = result.TracksFound == len(state.Tracks) && added == result.TracksFound
+
+	return result
+}
+
+// returns the context error if the conversion got cancelled, ErrPaused if it got paused
+func proceed(ctx context.Context, conv *Conversion, state *State, tracker *progressTracker, emit Emitter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if conv.Gate == nil || !conv.Gate.Paused() {
+		return nil
+	}
+
+	Emit(emit, &PhaseEvent{
+		Phase:    PhasePaused,
+		Message:  "Conversion paused",
+		Progress: tracker.progress(state),
+	})
+
+	return ErrPaused
+}
+
+// a paused conversion isn't done, it is run again from its state once resumed
+func interrupt(emit Emitter, conv *Conversion, state *State, tracker *progressTracker, err error) (*Result, error) {
+	if errors.Is(err, ErrPaused) {
+		return summarize(conv, state), err
+	}
+
+	return cancel(emit, conv, state, tracker, err)
+}
+
+// stops the conversion reporting the partial results
+func cancel(emit Emitter, conv *Conversion, state *State, tracker *progressTracker, err error) (*Result, error) {
+	result := summarize(conv, state)
+	progress := tracker.progress(state)
+
+	Emit(emit, &PhaseEvent{
+		Phase:    PhaseCancelled,
+		Message:  "Conversion cancelled",
+		Progress: progress,
+	})
+
+	Emit(emit, &DoneEvent{
+		Status:      DoneCancelled,
+		Message:     "Conversion process cancelled",
+		PlaylistUrl: result.PlaylistUrl,
+		Truncated:   result.Truncated,
+		Successful:  false,
+		Progress:    progress,
+	})
+
+	return result, err
+}
+
+/*
+adds the matches of the results at indexes to the target playlist,
+the batch is checkpointed as adding first so a run interrupted before the next checkpoint doesn't add it twice
+*/
+func addTracks(conv *Conversion, state *State, indexes []int, tracker *progressTracker, emit Emitter, checkpoint func(*State, []int)) error {
+	target := conv.Target
+
+	tracks := make(services.SearchTrackList, 0, len(indexes))
+	changed := make([]int, 0, len(indexes))
+
+	for _, i := range indexes {
+		tracks = append(tracks, state.Results[i].Match)
+		state.Results[i].Adding = true
+		changed = append(changed, i)
+	}
+
+	checkpoint(state, changed)
+
+	err := target.AddTracks(state.PlaylistId, tracks, conv.SessionId)
+
+	for _, i := range indexes {
+		state.Results[i].Adding = false
+		state.Results[i].Added = err == nil
+	}
+
+	checkpoint(state, changed)
+
+	if err != nil {
+		log.Println(target.Name()+" AddTracks error", err)
+
+		Emit(emit, &ErrorEvent{Message: "error adding tracks to playlist on " + target.DisplayName()})
+
+		return err
+	}
+
+	Emit(emit, &TrackAddedEvent{
+		Message:  "Added " + strconv.Itoa(len(tracks)) + " tracks to playlist on " + target.DisplayName(),
+		Indexes:  changed,
+		Progress: tracker.progress(state),
+	})
+
+	return nil
+}
+
+/*
+settles the batches a previous run was adding when it got interrupted,
+matches found on the target playlist are added already and the others are added again.
+when the target playlist can't be read the batches stay unsettled and aren't added again, duplicates are worse than gaps
+*/
+func reconcile(conv *Conversion, state *State, checkpoint func(*State, []int)) {
+	adding := []int{}
+
+	for i, result := range state.Results {
+		if result.Adding {
+			adding = append(adding, i)
+		}
+	}
+
+	if len(adding) == 0 {
+		return
+	}
+
+	tracks, _, err := conv.Target.GetPlaylistTracks(state.PlaylistId, conv.SessionId)
+
+	if err != nil {
+		log.Println(conv.Target.Name()+" GetPlaylistTracks error reconciling added tracks", err)
+		return
+	}
+
+	// the same track can be on the playlist more than once, each copy settles one result
+	onTarget := map[string]int{}
+	for _, track := range tracks {
+		onTarget[track.Id]++
+	}
+
+	for _, result := range state.Results {
+		if result.Added && result.Match != nil {
+			onTarget[result.Match.Id]--
+		}
+	}
+
+	for _, i := range adding {
+		result := state.Results[i]
+		result.Adding = false
+
+		if onTarget[result.Match.Id] > 0 {
+			onTarget[result.Match.Id]--
+			result.Added = true
+		}
+	}
+
+	checkpoint(state, adding)
+}
+
+// searches the track at index of the source playlist and appends its result to the state
+func searchTrack(target services.Provider, matchConfig *match.Config, state *State, index int, tracker *progressTracker, emit Emitter) *TrackResult {
+	track := state.Tracks[index]
+
+	Emit(emit, &TrackSearchEvent{
+		Index:    index,
+		Status:   SearchSearching,
+		Message:  "Searching track on " + target.DisplayName() + ": " + track.Title + " by " + track.MainArtist(),
+		Track:    track,
+		Progress: tracker.progress(state),
+	})
+
+	result := &TrackResult{Source: track}
+	event := &TrackSearchEvent{Index: index, Track: track}
+
+	best, ok, err := match.FindBest(target, track, matchConfig)
+
+	switch {
+	case err != nil:
+		log.Println("error searching track: ", err)
+
+		result.Status = TrackError
+		event.Status = SearchError
+		event.Message = "error searching for track"
+	case best == nil:
+		result.Status = TrackNotFound
+		event.Status = SearchNotFound
+		event.Message = "track not found"
+	default:
+		result.Match = best.Track
+		result.Confidence = best.Confidence
+		result.Strategy = best.Strategy
+		result.Status = TrackFound
+
+		event.Match = best.Track
+		event.Confidence = best.Confidence
+		event.Strategy = best.Strategy
+		event.Status = SearchFound
+		event.Message = "Track found"
+
+		if !ok {
+			result.Status = TrackNotFound
+			event.Status = SearchNotFound
+			event.Message = "no confident match found"
+		}
+	}
+
+	state.Results = append(state.Results, result)
+	event.Progress = tracker.progress(state)
+
+	Emit(emit, event)
+
+	return result
+}
+
+// stops the conversion on an unrecoverable error
+func abort(emit Emitter, message string, err error) (*Result, error) {
+	Emit(emit, &ErrorEvent{Message: message, Fatal: true})
+
+	Emit(emit, &DoneEvent{
+		Status:   DoneFailed,
+		Message:  "Conversion process aborted",
+		Progress: &Progress{},
+	})
+
+	return &Result{}, err
+}
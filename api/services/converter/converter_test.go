@@ -0,0 +1,221 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+)
+
+// target finding every searched track, its playlist holds the added tracks
+type fakeTarget struct {
+	playlist services.SearchTrackList
+	// returned by AddTracks and GetPlaylistTracks when set
+	addErr  error
+	readErr error
+	// results of the state being added when AddTracks was called
+	addingOnAdd []bool
+	state       *State
+}
+
+func (p *fakeTarget) Name() string                                  { return "fake" }
+func (p *fakeTarget) DisplayName() string                           { return "Fake" }
+func (p *fakeTarget) Hosts() []string                               { return []string{} }
+func (p *fakeTarget) ResolvePlaylistURL(u *url.URL) (string, error) { return "", nil }
+func (p *fakeTarget) PlaylistURL(id string) string                  { return "fake:" + id }
+
+func (p *fakeTarget) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	return nil, nil
+}
+
+func (p *fakeTarget) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	return p.playlist, false, p.readErr
+}
+
+func (p *fakeTarget) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	return services.SearchTrackList{track}, nil
+}
+
+func (p *fakeTarget) CreatePlaylist(name string, sessionId string) (string, error) {
+	return "created", nil
+}
+
+func (p *fakeTarget) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	if p.state != nil {
+		for _, result := range p.state.Results {
+			if result.Status == TrackFound && !result.Added {
+				p.addingOnAdd = append(p.addingOnAdd, result.Adding)
+			}
+		}
+	}
+
+	if p.addErr != nil {
+		return p.addErr
+	}
+
+	p.playlist = append(p.playlist, tracks...)
+
+	return nil
+}
+
+func testTrack(id string) *services.SearchTrack {
+	return &services.SearchTrack{Id: id, Title: "Track " + id, Artists: shared_types.Artists{{Name: "Artist"}}}
+}
+
+// state of a run interrupted while adding the batch of the first results
+func interruptedState(ids ...string) *State {
+	state := &State{PlaylistId: "created"}
+
+	for _, id := range ids {
+		state.Tracks = append(state.Tracks, testTrack(id))
+	}
+
+	for _, track := range state.Tracks[:2] {
+		state.Results = append(state.Results, &TrackResult{Source: track, Match: track, Status: TrackFound, Confidence: 1, Adding: true})
+	}
+
+	return state
+}
+
+func playlistIds(tracks services.SearchTrackList) []string {
+	ids := []string{}
+
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+
+	return ids
+}
+
+func TestResumeReconcilesTheBatchBeingAdded(t *testing.T) {
+	tests := []struct {
+		name string
+		// target playlist when the run resumes
+		playlist services.SearchTrackList
+		readErr  error
+		want     []string
+		added    []bool
+		// unsettled results of the interrupted batch
+		adding bool
+	}{
+		{
+			name:     "batch reached the target",
+			playlist: services.SearchTrackList{testTrack("a"), testTrack("b")},
+			want:     []string{"a", "b", "c"},
+			added:    []bool{true, true, true},
+		},
+		{
+			name:     "batch didn't reach the target",
+			playlist: services.SearchTrackList{},
+			want:     []string{"a", "b", "c"},
+			added:    []bool{true, true, true},
+		},
+		{
+			name:     "one copy on the target settles one result",
+			playlist: services.SearchTrackList{testTrack("a")},
+			want:     []string{"a", "b", "c"},
+			added:    []bool{true, true, true},
+		},
+		{
+			name:    "target playlist can't be read",
+			readErr: errors.New("unavailable"),
+			want:    []string{"c"},
+			added:   []bool{false, false, true},
+			adding:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &fakeTarget{playlist: tt.playlist, readErr: tt.readErr}
+			state := interruptedState("a", "b", "c")
+
+			result, err := Run(context.Background(), &Conversion{Source: target, Target: target, State: state}, nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := playlistIds(target.playlist); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("target playlist = %v, want %v", got, tt.want)
+			}
+
+			for i, added := range tt.added {
+				adding := tt.adding && i < 2
+
+				if result.Tracks[i].Added != added || result.Tracks[i].Adding != adding {
+					t.Errorf("track %d added = %v adding = %v, want %v %v", i, result.Tracks[i].Added, result.Tracks[i].Adding, added, adding)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchIsCheckpointedBeforeItIsAdded(t *testing.T) {
+	target := &fakeTarget{}
+	state := &State{}
+	target.state = state
+
+	checkpointedAdding := false
+
+	_, err := Run(context.Background(), &Conversion{
+		Source: &fakeSource{tracks: services.SearchTrackList{testTrack("a"), testTrack("b")}},
+		Target: target,
+		State:  state,
+		Checkpoint: func(state *State, changed []int) {
+			for _, i := range changed {
+				if state.Results[i].Adding {
+					checkpointedAdding = true
+				}
+			}
+		},
+	}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !checkpointedAdding || len(target.addingOnAdd) != 2 || !target.addingOnAdd[0] || !target.addingOnAdd[1] {
+		t.Errorf("batch adding checkpointed = %v, adding when added = %v, want the batch checkpointed first", checkpointedAdding, target.addingOnAdd)
+	}
+}
+
+func TestAddErrorEndsWithAFailedDoneEvent(t *testing.T) {
+	target := &fakeTarget{addErr: errors.New("quota exceeded")}
+
+	events := []string{}
+	var done DoneEvent
+
+	result, err := Run(context.Background(), &Conversion{
+		Source: &fakeSource{tracks: services.SearchTrackList{testTrack("a")}},
+		Target: target,
+	}, func(event string, data string) {
+		events = append(events, event)
+
+		if event == EventDone {
+			json.Unmarshal([]byte(data), &done)
+		}
+	})
+
+	if err == nil || result.Tracks[0].Added || result.Tracks[0].Adding {
+		t.Fatalf("Run() = %+v, %v, want the add error", result.Tracks[0], err)
+	}
+
+	if events[len(events)-1] != EventDone || done.Status != DoneFailed || done.Successful {
+		t.Errorf("last event = %s %+v, want a failed done event", events[len(events)-1], done)
+	}
+}
+
+type fakeSource struct {
+	fakeTarget
+	tracks services.SearchTrackList
+}
+
+func (p *fakeSource) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	return p.tracks, false, nil
+}
@@ -0,0 +1,203 @@
+package converter
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"math"
+	"time"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/match"
+)
+
+/*
+version of the conversion event payloads, bumped on breaking changes.
+
+EventsSchema describes every payload, keep it in sync with the structs below
+*/
+const EventsSchemaVersion = 1
+
+//go:embed events.schema.json
+var EventsSchema []byte
+
+// SSE event names
+const (
+	EventPhase       = "phase"
+	EventTrackSearch = "track_search"
+	EventTrackAdded  = "track_added"
+	EventError       = "error"
+	EventDone        = "done"
+)
+
+type Phase string
+
+const (
+	PhaseFetchingTracks   Phase = "fetching_tracks"
+	PhaseCreatingPlaylist Phase = "creating_playlist"
+	PhaseSearching        Phase = "searching"
+	PhaseResuming         Phase = "resuming"
+	PhasePaused           Phase = "paused"
+	PhaseResumed          Phase = "resumed"
+	PhaseCancelled        Phase = "cancelled"
+)
+
+type TrackSearchStatus string
+
+const (
+	SearchSearching TrackSearchStatus = "searching"
+	SearchFound     TrackSearchStatus = "found"
+	SearchNotFound  TrackSearchStatus = "not_found"
+	SearchError     TrackSearchStatus = "error"
+)
+
+type DoneStatus string
+
+const (
+	DoneCompleted DoneStatus = "completed"
+	DoneCancelled DoneStatus = "cancelled"
+	DoneFailed    DoneStatus = "failed"
+)
+
+// fields shared by every event, set by Emit
+type EventHeader struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+}
+
+func (h *EventHeader) header() *EventHeader {
+	return h
+}
+
+// payload of a conversion event
+type Event interface {
+	header() *EventHeader
+	eventType() string
+}
+
+type Progress struct {
+	Total    int `json:"total"`
+	Searched int `json:"searched"`
+	Found    int `json:"found"`
+	NotFound int `json:"notFound"`
+	Added    int `json:"added"`
+	// searched tracks out of the total, 0 to 100
+	Percentage float64 `json:"percentage"`
+	// estimated seconds left, nil until a track was searched
+	ETASeconds *float64 `json:"etaSeconds"`
+}
+
+type PhaseEvent struct {
+	EventHeader
+	Phase    Phase     `json:"phase"`
+	Message  string    `json:"message"`
+	Progress *Progress `json:"progress,omitempty"`
+}
+
+func (*PhaseEvent) eventType() string { return EventPhase }
+
+type TrackSearchEvent struct {
+	EventHeader
+	// position of the track in the source playlist
+	Index      int                   `json:"index"`
+	Status     TrackSearchStatus     `json:"status"`
+	Message    string                `json:"message"`
+	Track      *services.SearchTrack `json:"track"`
+	Match      *services.SearchTrack `json:"match,omitempty"`
+	Confidence float64               `json:"confidence,omitempty"`
+	Strategy   match.Strategy        `json:"strategy,omitempty"`
+	Progress   *Progress             `json:"progress"`
+}
+
+func (*TrackSearchEvent) eventType() string { return EventTrackSearch }
+
+type TrackAddedEvent struct {
+	EventHeader
+	Message string `json:"message"`
+	// source playlist positions of the tracks added
+	Indexes  []int     `json:"indexes"`
+	Progress *Progress `json:"progress"`
+}
+
+func (*TrackAddedEvent) eventType() string { return EventTrackAdded }
+
+type ErrorEvent struct {
+	EventHeader
+	Message string `json:"message"`
+	// true when the conversion stops because of the error
+	Fatal bool `json:"fatal"`
+}
+
+func (*ErrorEvent) eventType() string { return EventError }
+
+// always the last event of a conversion, tells client to close connection
+type DoneEvent struct {
+	EventHeader
+	Status      DoneStatus `json:"status"`
+	Message     string     `json:"message"`
+	PlaylistUrl string     `json:"playlistUrl,omitempty"`
+	Truncated   bool       `json:"truncated"`
+	Successful  bool       `json:"successful"`
+	Progress    *Progress  `json:"progress"`
+}
+
+func (*DoneEvent) eventType() string { return EventDone }
+
+// sends the JSON encoded event to emit
+func Emit(emit Emitter, event Event) {
+	header := event.header()
+	header.Version = EventsSchemaVersion
+	header.Type = event.eventType()
+
+	data, err := json.Marshal(event)
+
+	if err != nil {
+		log.Println("error marshaling "+header.Type+" event: ", err)
+		return
+	}
+
+	emit(header.Type, string(data))
+}
+
+// computes the conversion progress, the ETA is based on the tracks searched since the conversion (re)started
+type progressTracker struct {
+	startedAt  time.Time
+	startIndex int
+}
+
+func newProgressTracker(state *State) *progressTracker {
+	return &progressTracker{startedAt: time.Now(), startIndex: len(state.Results)}
+}
+
+func (p *progressTracker) progress(state *State) *Progress {
+	progress := &Progress{
+		Total:    len(state.Tracks),
+		Searched: len(state.Results),
+	}
+
+	for _, result := range state.Results {
+		if result.Status == TrackFound {
+			progress.Found++
+		} else {
+			progress.NotFound++
+		}
+
+		if result.Added {
+			progress.Added++
+		}
+	}
+
+	if progress.Total == 0 {
+		progress.Percentage = 100
+	} else {
+		progress.Percentage = math.Round(float64(progress.Searched)/float64(progress.Total)*10000) / 100
+	}
+
+	if searched := progress.Searched - p.startIndex; searched > 0 {
+		perTrack := time.Since(p.startedAt).Seconds() / float64(searched)
+		eta := math.Round(perTrack * float64(progress.Total-progress.Searched))
+		progress.ETASeconds = &eta
+	}
+
+	return progress
+}
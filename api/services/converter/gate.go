@@ -0,0 +1,53 @@
+package converter
+
+import (
+	"sync"
+)
+
+/*
+asks a running conversion to stop between tracks, Run returns ErrPaused and the conversion
+is resumed by running it again from its state once the gate is resumed
+*/
+type Gate struct {
+	mu     sync.Mutex
+	paused bool
+}
+
+func NewGate() *Gate {
+	return &Gate{}
+}
+
+// returns false if the gate was already paused
+func (g *Gate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		return false
+	}
+
+	g.paused = true
+
+	return true
+}
+
+// returns false if the gate was not paused
+func (g *Gate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		return false
+	}
+
+	g.paused = false
+
+	return true
+}
+
+func (g *Gate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.paused
+}
@@ -0,0 +1,433 @@
+package deezer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+	"github.com/to-dy/music-playlist-converter/initializers"
+)
+
+var deezerBaseURL = "https://api.deezer.com"
+
+const (
+	authURL  = "https://connect.deezer.com/oauth/auth.php"
+	tokenURL = "https://connect.deezer.com/oauth/access_token.php"
+)
+
+// deezer answers errors with a 200 status and this body, code 800 means the resource does not exist
+type ApiError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+const errorCodeNoData = 800
+
+func (e *ApiError) Error() string {
+	return "deezer " + e.Type + ": " + e.Message + " | code: " + fmt.Sprint(e.Code)
+}
+
+type Track struct {
+	Id       int64  `json:"id"`
+	Title    string `json:"title"`
+	Duration int64  `json:"duration"`
+	// only returned by the track endpoints, not in playlist track lists
+	ISRC   string `json:"isrc"`
+	Artist struct {
+		Name string `json:"name"`
+	} `json:"artist"`
+	Album struct {
+		Title string `json:"title"`
+		Type  string `json:"record_type"`
+	} `json:"album"`
+	Error *ApiError `json:"error"`
+}
+
+type TracksResponse struct {
+	Data  []*Track  `json:"data"`
+	Total int       `json:"total"`
+	Next  string    `json:"next"`
+	Error *ApiError `json:"error"`
+}
+
+type PlaylistResponse struct {
+	Id       int64     `json:"id"`
+	Title    string    `json:"title"`
+	NbTracks int       `json:"nb_tracks"`
+	Link     string    `json:"link"`
+	Error    *ApiError `json:"error"`
+}
+
+var (
+	appId       string
+	secret      string
+	redirectURI string
+)
+
+func init() {
+	initializers.LoadEnv()
+
+	appId = os.Getenv("DEEZER_APP_ID")
+	secret = os.Getenv("DEEZER_SECRET")
+	redirectURI = os.Getenv("DEEZER_REDIRECT_URI")
+}
+
+/*
+returns the deezer authorization page url
+
+deezer doesn't echo a state parameter, it is sent back through the redirect uri query instead
+*/
+func AuthCodeURL(state string) string {
+	redirect := redirectURI + "?state=" + url.QueryEscape(state)
+
+	return authURL + "?app_id=" + url.QueryEscape(appId) +
+		"&redirect_uri=" + url.QueryEscape(redirect) +
+		"&perms=basic_access,manage_library,offline_access"
+}
+
+// exchanges the authorization code, deezer's token endpoint doesn't follow the oauth2 spec so golang.org/x/oauth2 can't be used
+func Exchange(code string) (*oauth2.Token, error) {
+	cli := fiber.Client{}
+
+	res := cli.Get(tokenURL + "?app_id=" + url.QueryEscape(appId) +
+		"&secret=" + url.QueryEscape(secret) +
+		"&code=" + url.QueryEscape(code) +
+		"&output=json")
+
+	var bodyData struct {
+		AccessToken string `json:"access_token"`
+		// seconds, 0 when the token doesn't expire (offline_access)
+		Expires int64 `json:"expires"`
+	}
+
+	status, _, errs := res.Struct(&bodyData)
+
+	// the response holds the access token, only the status is logged
+	if errs != nil {
+		log.Println("deezer token | status code: " + fmt.Sprint(status))
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK || bodyData.AccessToken == "" {
+		return nil, errors.New("error exchanging deezer code | status code: " + fmt.Sprint(status))
+	}
+
+	token := &oauth2.Token{AccessToken: bodyData.AccessToken}
+
+	if bodyData.Expires > 0 {
+		token.Expiry = time.Now().Add(time.Duration(bodyData.Expires) * time.Second)
+	}
+
+	return token, nil
+}
+
+func StoreAuthCodeToken(token *oauth2.Token, sessionId string) {
+	prefix := sessionId + "_"
+
+	expiration := token.Expiry
+	if expiration.IsZero() {
+		// offline_access tokens are valid until the user revokes them
+		expiration = time.Now().AddDate(10, 0, 0)
+	}
+
+	tokenstore.GlobalTokenStore.SetToken(prefix+string(tokenstore.DEEZER_AC), tokenstore.TokenEntry{
+		Token:      token,
+		Expiration: expiration,
+	})
+}
+
+// deezer tokens can't be refreshed, the user has to authorize again once it expired
+func getAuthCodeToken(sessionId string) (string, error) {
+	token, tokenValid := tokenstore.GlobalTokenStore.GetToken(sessionId + "_" + string(tokenstore.DEEZER_AC))
+
+	if !tokenValid {
+		return "", errors.New("deezer token not found or expired, authorize Deezer again")
+	}
+
+	return token.AccessToken, nil
+}
+
+/*
+returns the long url of a deezer.page.link short link
+
+the short link redirects to the playlist page, only the Location header is read
+*/
+func ResolveShortLink(u *url.URL) (*url.URL, error) {
+	cli := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := cli.Get(u.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	location := res.Header.Get("Location")
+
+	if res.StatusCode < http.StatusMultipleChoices || res.StatusCode >= http.StatusBadRequest || location == "" {
+		return nil, errors.New("error resolving deezer short link | status code: " + fmt.Sprint(res.StatusCode))
+	}
+
+	return u.Parse(location)
+}
+
+// verify if deezer playlist exists
+func FindPlaylist(id string) (*PlaylistResponse, error) {
+	cli := fiber.Client{}
+
+	res := cli.Get(deezerBaseURL + "/playlist/" + url.PathEscape(id)).Debug()
+
+	var bodyData PlaylistResponse
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.New("error verifying playlist | status code: " + fmt.Sprint(status))
+	}
+
+	if bodyData.Error != nil {
+		if bodyData.Error.Code == errorCodeNoData {
+			return nil, nil
+		}
+
+		return nil, bodyData.Error
+	}
+
+	return &bodyData, nil
+}
+
+/*
+fetches the playlist tracks page by page until all tracks or the allowed number of conversions are fetched
+
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string) (tracks []*Track, truncated bool, err error) {
+	cli := fiber.Client{}
+
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	tracks = []*Track{}
+	next := deezerBaseURL + "/playlist/" + url.PathEscape(id) + "/tracks?limit=100"
+
+	for next != "" {
+		var bodyData TracksResponse
+
+		status, _, errs := cli.Get(next).Debug().Struct(&bodyData)
+
+		if errs != nil {
+			return nil, false, errs[0]
+		}
+
+		if status != http.StatusOK {
+			return nil, false, errors.New("error getting playlist tracks | status code: " + fmt.Sprint(status))
+		}
+
+		if bodyData.Error != nil {
+			return nil, false, bodyData.Error
+		}
+
+		tracks = append(tracks, bodyData.Data...)
+		next = bodyData.Next
+
+		// allowedNumberOfConversions = 0 means convert all tracks
+		if allowedNumberOfConversions != 0 && len(tracks) >= allowedNumberOfConversions {
+			truncated = len(tracks) > allowedNumberOfConversions || next != ""
+			tracks = tracks[0:allowedNumberOfConversions]
+
+			break
+		}
+	}
+
+	return tracks, truncated, nil
+}
+
+func ToSearchTrackList(tracks []*Track) services.SearchTrackList {
+	searchTrackList := make(services.SearchTrackList, 0, len(tracks))
+
+	for _, track := range tracks {
+		searchTrackList = append(searchTrackList, toSearchTrack(track))
+	}
+
+	return searchTrackList
+}
+
+func toSearchTrack(track *Track) *services.SearchTrack {
+	searchTrack := &services.SearchTrack{
+		Id:       fmt.Sprint(track.Id),
+		Title:    track.Title,
+		Duration: track.Duration * 1000,
+		Album:    shared_types.Album{AlbumType: track.Album.Type, Name: track.Album.Title},
+		ISRC:     track.ISRC,
+	}
+
+	if track.Artist.Name != "" {
+		searchTrack.Artists = shared_types.Artists{{Name: track.Artist.Name}}
+	}
+
+	return searchTrack
+}
+
+// searches deezer tracks with the advanced search syntax and returns at most limit results in the order deezer ranks them
+func SearchTracks(query string, artist string, limit int) ([]*Track, error) {
+	cli := fiber.Client{}
+
+	q := `track:"` + strings.ReplaceAll(query, `"`, "") + `"`
+	if artist != "" {
+		q += ` artist:"` + strings.ReplaceAll(artist, `"`, "") + `"`
+	}
+
+	res := cli.Get(deezerBaseURL + "/search/track?q=" + url.QueryEscape(q) + "&limit=" + fmt.Sprint(limit)).Debug()
+
+	var bodyData TracksResponse
+
+	status, _, errs := res.Struct(&bodyData)
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.New("error searching track | status code: " + fmt.Sprint(status))
+	}
+
+	if bodyData.Error != nil {
+		return nil, bodyData.Error
+	}
+
+	return bodyData.Data, nil
+}
+
+// looks up a track by its isrc code
+func SearchISRC(isrc string) (*Track, bool, error) {
+	cli := fiber.Client{}
+
+	res := cli.Get(deezerBaseURL + "/track/isrc:" + url.PathEscape(isrc)).Debug()
+
+	var bodyData Track
+
+	status, _, errs := res.Struct(&bodyData)
+	if errs != nil {
+		return nil, false, errs[0]
+	}
+
+	if status != http.StatusOK {
+		return nil, false, errors.New("error searching isrc | status code: " + fmt.Sprint(status))
+	}
+
+	if bodyData.Error != nil {
+		if bodyData.Error.Code == errorCodeNoData {
+			return nil, false, nil
+		}
+
+		return nil, false, bodyData.Error
+	}
+
+	return &bodyData, true, nil
+}
+
+func CreatePlaylist(name string, sessionId string) (string, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getAuthCodeToken(sessionId)
+
+	if tokenErr != nil {
+		return "", tokenErr
+	}
+
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	args.Set("title", name)
+	args.Set("access_token", token)
+
+	res := cli.Post(deezerBaseURL + "/user/me/playlists").Form(args)
+
+	var bodyData struct {
+		Id    int64     `json:"id"`
+		Error *ApiError `json:"error"`
+	}
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return "", errs[0]
+	}
+
+	if bodyData.Error != nil {
+		return "", bodyData.Error
+	}
+
+	if status == http.StatusOK && bodyData.Id != 0 {
+		return fmt.Sprint(bodyData.Id), nil
+	}
+
+	return "", errors.New("error creating playlist | status code: " + fmt.Sprint(status))
+}
+
+// deezer ids are sent in the query string, keep requests reasonably short
+const maxTracksPerRequest = 100
+
+func AddTracksToPlaylist(playlistId string, trackIds []string, sessionId string) error {
+	cli := fiber.Client{}
+
+	token, tokenErr := getAuthCodeToken(sessionId)
+
+	if tokenErr != nil {
+		return tokenErr
+	}
+
+	for start := 0; start < len(trackIds); start += maxTracksPerRequest {
+		end := start + maxTracksPerRequest
+		if end > len(trackIds) {
+			end = len(trackIds)
+		}
+
+		args := fiber.AcquireArgs()
+		args.Set("songs", strings.Join(trackIds[start:end], ","))
+		args.Set("access_token", token)
+
+		res := cli.Post(deezerBaseURL + "/playlist/" + url.PathEscape(playlistId) + "/tracks").Form(args)
+
+		status, b, errs := res.Bytes()
+		fiber.ReleaseArgs(args)
+
+		if errs != nil {
+			return errs[0]
+		}
+
+		// deezer answers `true` on success and an error object otherwise
+		if status != http.StatusOK || strings.TrimSpace(string(b)) != "true" {
+			log.Println("deezer add tracks : ", string(b))
+
+			return errors.New("error adding tracks to playlist | status code: " + fmt.Sprint(status))
+		}
+	}
+
+	return nil
+}
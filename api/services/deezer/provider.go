@@ -0,0 +1,144 @@
+package deezer
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "deezer"
+
+const shortLinkHost = "deezer.page.link"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "Deezer"
+}
+
+func (p *provider) Hosts() []string {
+	return []string{"www.deezer.com", "deezer.com", shortLinkHost}
+}
+
+/*
+expects urls in the format https://www.deezer.com/{lang}/playlist/{id}, the language is optional.
+https://deezer.page.link/{code} short links are followed to the playlist url
+*/
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	if u.Host == shortLinkHost {
+		longURL, err := ResolveShortLink(u)
+
+		if err != nil {
+			log.Println("deezer short link error", err)
+			return "", services.ErrInvalidPlaylistURL
+		}
+
+		u = longURL
+	}
+
+	if u.Host != "www.deezer.com" && u.Host != "deezer.com" {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	for i, part := range pathParts {
+		if part == "playlist" && i+1 < len(pathParts) && isNumeric(pathParts[i+1]) {
+			return pathParts[i+1], nil
+		}
+	}
+
+	return "", services.ErrInvalidPlaylistURL
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := FindPlaylist(id)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         fmt.Sprint(playlist.Id),
+		Title:      playlist.Title,
+		Url:        p.PlaylistURL(id),
+		TrackCount: playlist.NbTracks,
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	tracks, truncated, err := GetPlaylistTracks(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(tracks), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	tracks, err := SearchTracks(track.Title, track.MainArtist(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSearchTrackList(tracks), nil
+}
+
+func (p *provider) SearchISRC(isrc string) (*services.SearchTrack, bool, error) {
+	track, found, err := SearchISRC(isrc)
+
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	return toSearchTrack(track), true, nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name, sessionId)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	ids := make([]string, 0, len(tracks))
+
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+
+	return AddTracksToPlaylist(playlistId, ids, sessionId)
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	return "https://www.deezer.com/playlist/" + id
+}
+
+func (p *provider) TrackURL(id string) string {
+	return "https://www.deezer.com/track/" + id
+}
@@ -0,0 +1,347 @@
+package formats
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrCSVTitleColumn = errors.New("csv has no title column")
+
+// header names of the columns holding the fields of an entry, empty when the csv has no such column
+type CSVColumns struct {
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	Duration string `json:"duration"`
+	ISRC     string `json:"isrc"`
+	Location string `json:"location"`
+}
+
+// the columns of a csv file and the first rows, used to confirm the detected columns before converting
+type CSVHeader struct {
+	Headers  []string    `json:"headers"`
+	Detected *CSVColumns `json:"detected"`
+	Rows     [][]string  `json:"rows"`
+}
+
+/*
+header names of common layouts in order of preference, compared lowercased.
+the first ones are written by Exportify: "Track Name, Artist Name(s), Album Name, Duration (ms), ISRC"
+*/
+var csvHeaderNames = struct {
+	title, artist, album, duration, isrc, location []string
+}{
+	title:    []string{"track name", "title", "track title", "song name", "song", "track", "name"},
+	artist:   []string{"artist name(s)", "artist", "artists", "artist name", "artist names", "creator", "performer"},
+	album:    []string{"album name", "album", "album title", "release"},
+	duration: []string{"track duration (ms)", "duration (ms)", "duration_ms", "duration", "length", "time"},
+	isrc:     []string{"isrc"},
+	location: []string{"track url", "track uri", "url", "uri", "location", "link", "spotify uri", "path"},
+}
+
+// columns written by EncodeCSV, they are detected when the file is read back
+var csvExportHeaders = []string{"Track Name", "Artist Name(s)", "Album Name", "Duration (ms)", "ISRC", "Track URL"}
+
+type csvFormat struct{}
+
+func init() {
+	RegisterFormat(&csvFormat{})
+}
+
+func (f *csvFormat) Name() string {
+	return "csv"
+}
+
+func (f *csvFormat) Extensions() []string {
+	return []string{".csv"}
+}
+
+func (f *csvFormat) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// reads the csv with the detected columns
+func (f *csvFormat) Decode(r io.Reader) (*Playlist, error) {
+	return DecodeCSV(r, nil)
+}
+
+func (f *csvFormat) Encode(w io.Writer, playlist *Playlist) error {
+	return EncodeCSV(w, playlist)
+}
+
+// reads the header row and up to sampleRows rows of a csv file
+func ReadCSVHeader(r io.Reader, sampleRows int) (*CSVHeader, error) {
+	reader := newCSVReader(r)
+
+	headers, err := readCSVHeaders(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	header := &CSVHeader{Headers: headers, Detected: DetectCSVColumns(headers), Rows: [][]string{}}
+
+	for len(header.Rows) < sampleRows {
+		row, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		header.Rows = append(header.Rows, row)
+	}
+
+	return header, nil
+}
+
+// picks the columns of common csv layouts
+func DetectCSVColumns(headers []string) *CSVColumns {
+	normalized := map[string]string{}
+
+	for _, header := range headers {
+		key := strings.ToLower(strings.TrimSpace(header))
+
+		if _, exists := normalized[key]; !exists {
+			normalized[key] = header
+		}
+	}
+
+	find := func(names []string) string {
+		for _, name := range names {
+			if header, ok := normalized[name]; ok {
+				return header
+			}
+		}
+
+		return ""
+	}
+
+	return &CSVColumns{
+		Title:    find(csvHeaderNames.title),
+		Artist:   find(csvHeaderNames.artist),
+		Album:    find(csvHeaderNames.album),
+		Duration: find(csvHeaderNames.duration),
+		ISRC:     find(csvHeaderNames.isrc),
+		Location: find(csvHeaderNames.location),
+	}
+}
+
+/*
+reads a csv file with a header row, columns are the header names of the entry fields.
+nil columns are detected from the headers, rows without a title are skipped
+*/
+func DecodeCSV(r io.Reader, columns *CSVColumns) (*Playlist, error) {
+	reader := newCSVReader(r)
+
+	headers, err := readCSVHeaders(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if columns == nil {
+		columns = DetectCSVColumns(headers)
+	}
+
+	index := func(name string) int {
+		if name == "" {
+			return -1
+		}
+
+		for i, header := range headers {
+			if strings.EqualFold(strings.TrimSpace(header), strings.TrimSpace(name)) {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	titleIndex := index(columns.Title)
+
+	if titleIndex < 0 {
+		return nil, ErrCSVTitleColumn
+	}
+
+	artistIndex := index(columns.Artist)
+	albumIndex := index(columns.Album)
+	durationIndex := index(columns.Duration)
+	isrcIndex := index(columns.ISRC)
+	locationIndex := index(columns.Location)
+
+	field := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+
+		return strings.TrimSpace(row[i])
+	}
+
+	durationInMs := strings.Contains(strings.ToLower(columns.Duration), "ms")
+	playlist := &Playlist{Entries: []*Entry{}}
+
+	for {
+		row, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &Entry{
+			Title:    field(row, titleIndex),
+			Artist:   csvArtists(field(row, artistIndex)),
+			Album:    field(row, albumIndex),
+			Duration: parseCSVDuration(field(row, durationIndex), durationInMs),
+			Location: field(row, locationIndex),
+		}
+
+		if isrc, ok := parseISRCIdentifier(field(row, isrcIndex)); ok {
+			entry.ISRC = isrc
+		}
+
+		if entry.Title == "" {
+			continue
+		}
+
+		playlist.Entries = append(playlist.Entries, entry)
+	}
+
+	return playlist, nil
+}
+
+// writes the entries with the columns of an Exportify export
+func EncodeCSV(w io.Writer, playlist *Playlist) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(csvExportHeaders); err != nil {
+		return err
+	}
+
+	for _, entry := range playlist.Entries {
+		duration := ""
+		if entry.Duration > 0 {
+			duration = strconv.FormatInt(entry.Duration, 10)
+		}
+
+		if err := writer.Write([]string{entry.Title, entry.Artist, entry.Album, duration, entry.ISRC, entry.Location}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// the delimiter is the most frequent of , ; and tab in the first line, spreadsheets of some locales use ;
+func newCSVReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReader(r)
+	firstLine, _ := br.Peek(4096)
+
+	if i := strings.IndexByte(string(firstLine), '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	comma := ','
+	count := strings.Count(string(firstLine), ",")
+
+	for _, delimiter := range []rune{';', '\t'} {
+		if n := strings.Count(string(firstLine), string(delimiter)); n > count {
+			comma = delimiter
+			count = n
+		}
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	return reader
+}
+
+func readCSVHeaders(reader *csv.Reader) ([]string, error) {
+	headers, err := reader.Read()
+
+	if err == io.EOF {
+		return nil, ErrCSVTitleColumn
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], byteOrderMark)
+	}
+
+	return headers, nil
+}
+
+// Exportify separates artists with commas only e.g "Daft Punk,Pharrell Williams"
+func csvArtists(value string) string {
+	if !strings.Contains(value, ",") {
+		return value
+	}
+
+	artists := []string{}
+
+	for _, artist := range strings.Split(value, ",") {
+		if artist = strings.TrimSpace(artist); artist != "" {
+			artists = append(artists, artist)
+		}
+	}
+
+	return strings.Join(artists, ", ")
+}
+
+/*
+parses "{minutes}:{seconds}", "{hours}:{minutes}:{seconds}" and numbers,
+numbers are milliseconds when the column name says so or when they are too large to be seconds
+*/
+func parseCSVDuration(value string, inMs bool) int64 {
+	if value == "" {
+		return 0
+	}
+
+	if strings.Contains(value, ":") {
+		seconds := 0.0
+
+		for _, part := range strings.Split(value, ":") {
+			n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+
+			if err != nil || n < 0 {
+				return 0
+			}
+
+			seconds = seconds*60 + n
+		}
+
+		return int64(seconds * 1000)
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+
+	if err != nil || n <= 0 {
+		return 0
+	}
+
+	// no track is 10 hours long
+	if inMs || n >= 36000 {
+		return int64(n)
+	}
+
+	return int64(n * 1000)
+}
@@ -0,0 +1,122 @@
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		columns *CSVColumns
+		want    []*Entry
+		wantErr error
+	}{
+		{
+			name: "exportify export",
+			input: byteOrderMark + "Track URI,Track Name,Artist Name(s),Album Name,Track Duration (ms),ISRC\n" +
+				"spotify:track:1,Get Lucky,\"Daft Punk,Pharrell Williams\",Random Access Memories,369626,usqx91300108\n",
+			want: []*Entry{
+				{Location: "spotify:track:1", Title: "Get Lucky", Artist: "Daft Punk, Pharrell Williams", Album: "Random Access Memories", Duration: 369626, ISRC: "USQX91300108"},
+			},
+		},
+		{
+			name:  "semicolon delimited spreadsheet with minutes",
+			input: "Title;Artist;Length\nOne;U2;4:36\nTwo;\"Artist; Other\";1:02:03\n",
+			want: []*Entry{
+				{Title: "One", Artist: "U2", Duration: 276000},
+				{Title: "Two", Artist: "Artist; Other", Duration: 3723000},
+			},
+		},
+		{
+			name:  "tab delimited with short rows and rows without a title",
+			input: "Song\tArtist\tISRC\nOne\tU2\tnot an isrc\n\tNobody\n Two \n",
+			want: []*Entry{
+				{Title: "One", Artist: "U2"},
+				{Title: "Two"},
+			},
+		},
+		{
+			name:    "chosen columns",
+			input:   "Name,Performer,Extra\nIgnored,Artist,Real Title\n",
+			columns: &CSVColumns{Title: "extra", Artist: "Performer"},
+			want: []*Entry{
+				{Title: "Real Title", Artist: "Artist"},
+			},
+		},
+		{
+			name:    "no title column",
+			input:   "Artist,Album\nU2,Achtung Baby\n",
+			wantErr: ErrCSVTitleColumn,
+		},
+		{
+			name:    "empty file",
+			input:   "",
+			wantErr: ErrCSVTitleColumn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCSV(strings.NewReader(tt.input), tt.columns)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeCSV() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil {
+				assertPlaylist(t, got, "", tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSVDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		inMs  bool
+		want  int64
+	}{
+		{"", false, 0},
+		{"215", false, 215000},
+		{"215.5", false, 215500},
+		{"215000", true, 215000},
+		// too long to be seconds
+		{"215000", false, 215000},
+		{"3:35", false, 215000},
+		{"03:35", true, 215000},
+		{"1:02:03", false, 3723000},
+		{"3:35.5", false, 215500},
+		{"-1", false, 0},
+		{"0", true, 0},
+		{"3:-5", false, 0},
+		{"unknown", false, 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseCSVDuration(tt.value, tt.inMs); got != tt.want {
+			t.Errorf("parseCSVDuration(%q, %v) = %d, want %d", tt.value, tt.inMs, got, tt.want)
+		}
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	entry := &Entry{Location: "https://open.spotify.com/track/1", Title: "Title, with \"quotes\"", Artist: "A, B", Album: "Album", Duration: 200500, ISRC: "GBAYE0601498"}
+
+	buf := &bytes.Buffer{}
+
+	if err := EncodeCSV(buf, testPlaylist(entry)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeCSV(buf, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertPlaylist(t, got, "", []*Entry{entry})
+}
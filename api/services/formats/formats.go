@@ -0,0 +1,221 @@
+package formats
+
+// playlist file formats read from uploads and local folders, and written by exports
+
+import (
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type Playlist struct {
+	// id of the playlist inside a library file, empty for single playlist files
+	Id      string
+	Title   string
+	Entries []*Entry
+}
+
+type Entry struct {
+	// file path or url of the track, relative paths are relative to the playlist file
+	Location string
+	Title    string
+	Artist   string
+	Album    string
+	// milliseconds, 0 when unknown
+	Duration int64
+	ISRC     string
+	// uri identifying the recording independently of its location e.g a musicbrainz recording url
+	Identifier string
+}
+
+// a playlist file format uploads can be read from and playlists exported to
+type Format interface {
+	// unique name used in the export format parameter e.g "m3u8"
+	Name() string
+	// file extensions including the dot, the first one is used for exported files
+	Extensions() []string
+	ContentType() string
+
+	Decode(r io.Reader) (*Playlist, error)
+	Encode(w io.Writer, playlist *Playlist) error
+}
+
+var (
+	registeredFormats = []Format{}
+	formatsMu         sync.RWMutex
+)
+
+func RegisterFormat(f Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	for _, registered := range registeredFormats {
+		if registered.Name() == f.Name() {
+			panic("format already registered: " + f.Name())
+		}
+	}
+
+	registeredFormats = append(registeredFormats, f)
+}
+
+func GetFormat(name string) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for _, f := range registeredFormats {
+		if f.Name() == strings.ToLower(name) {
+			return f, true
+		}
+	}
+
+	return nil, false
+}
+
+// finds the format of a file by its extension
+func FormatOfFile(fileName string) (Format, bool) {
+	ext := strings.ToLower(path.Ext(strings.ReplaceAll(fileName, "\\", "/")))
+
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for _, f := range registeredFormats {
+		for _, e := range f.Extensions() {
+			if e == ext {
+				return f, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// returns names of the registered formats in registration order
+func FormatNames() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	names := make([]string, 0, len(registeredFormats))
+
+	for _, f := range registeredFormats {
+		names = append(names, f.Name())
+	}
+
+	return names
+}
+
+// a file holding several playlists e.g an iTunes library
+type LibraryFormat interface {
+	Name() string
+	// reports whether the file is in the format, head holds the first bytes of the file
+	Match(fileName string, head []byte) bool
+	// the file name is used by formats naming playlists after their file
+	Decode(fileName string, r io.Reader) ([]*Playlist, error)
+}
+
+// bytes of a file passed to LibraryFormat.Match
+const LibraryHeadSize = 1024
+
+var registeredLibraryFormats = []LibraryFormat{}
+
+func RegisterLibraryFormat(f LibraryFormat) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	registeredLibraryFormats = append(registeredLibraryFormats, f)
+}
+
+func DetectLibraryFormat(fileName string, head []byte) (LibraryFormat, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for _, f := range registeredLibraryFormats {
+		if f.Match(fileName, head) {
+			return f, true
+		}
+	}
+
+	return nil, false
+}
+
+// leading track numbers of file names e.g "01 - ", "02. "
+var trackNumberPattern = regexp.MustCompile(`^\d{1,3}[\s.\-_]+`)
+
+/*
+splits the file name of a location like "01 - Artist - Title.mp3" into the artist and title,
+locations can be windows paths or urls
+*/
+func FileNameTags(location string) (artist string, title string) {
+	location = strings.ReplaceAll(location, "\\", "/")
+
+	// query strings and fragments of urls
+	if i := strings.IndexAny(location, "?#"); i > 0 && strings.Contains(location, "://") {
+		location = location[:i]
+	}
+
+	name := strings.TrimSuffix(path.Base(location), path.Ext(location))
+	name = trackNumberPattern.ReplaceAllString(name, "")
+
+	if artist, title, found := strings.Cut(name, " - "); found {
+		return strings.TrimSpace(artist), strings.TrimSpace(title)
+	}
+
+	return "", strings.TrimSpace(name)
+}
+
+// characters not allowed in file names on common file systems
+var unsafeFileNamePattern = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+
+// makes a playlist title usable as a file name, names left empty or made of dots become "playlist"
+func SafeFileName(name string) string {
+	fileName := strings.TrimSpace(unsafeFileNamePattern.ReplaceAllString(name, "_"))
+
+	if strings.Trim(fileName, ".") == "" {
+		return "playlist"
+	}
+
+	return fileName
+}
+
+// e.g "C:/Music", windows paths in file urls start with a slash "/C:/Music"
+var windowsPathPattern = regexp.MustCompile(`^/?[A-Za-z]:[/\\]`)
+
+/*
+returns the slash separated path of a location that is a file url or an absolute path,
+windows paths always start with their drive e.g C:/Music/track.mp3. ok is false for relative paths and other urls
+*/
+func localFilePath(location string) (filePath string, ok bool) {
+	if strings.HasPrefix(strings.ToLower(location), "file:") {
+		u, err := url.Parse(location)
+
+		if err != nil || u.Path == "" {
+			return "", false
+		}
+
+		location = u.Path
+	}
+
+	location = strings.ReplaceAll(location, "\\", "/")
+
+	// file urls and some players write the drive after a slash e.g /C:/Music/track.mp3
+	if windowsPathPattern.MatchString(location) {
+		return strings.TrimPrefix(location, "/"), true
+	}
+
+	if strings.HasPrefix(location, "/") {
+		return location, true
+	}
+
+	return "", false
+}
+
+// file url of an absolute path in the form DJ software expects e.g file://localhost/C:/Music/track.mp3
+func fileURL(filePath string) string {
+	if !strings.HasPrefix(filePath, "/") {
+		filePath = "/" + filePath
+	}
+
+	return (&url.URL{Scheme: "file", Host: "localhost", Path: filePath}).String()
+}
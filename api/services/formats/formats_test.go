@@ -0,0 +1,287 @@
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func roundTrip(t *testing.T, formatName string, playlist *Playlist) *Playlist {
+	t.Helper()
+
+	format, found := GetFormat(formatName)
+
+	if !found {
+		t.Fatalf("format %s isn't registered", formatName)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := format.Encode(buf, playlist); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	decoded, err := format.Decode(buf)
+
+	if err != nil {
+		t.Fatalf("Decode() error = %v\n%s", err, buf.String())
+	}
+
+	return decoded
+}
+
+func assertPlaylist(t *testing.T, got *Playlist, title string, entries []*Entry) {
+	t.Helper()
+
+	if got.Title != title {
+		t.Errorf("title = %q, want %q", got.Title, title)
+	}
+
+	if len(got.Entries) != len(entries) {
+		t.Fatalf("got %d entries, want %d", len(got.Entries), len(entries))
+	}
+
+	for i, entry := range entries {
+		if !reflect.DeepEqual(got.Entries[i], entry) {
+			t.Errorf("entry %d = %+v, want %+v", i, got.Entries[i], entry)
+		}
+	}
+}
+
+func testPlaylist(entries ...*Entry) *Playlist {
+	return &Playlist{Title: "Friday Set", Entries: entries}
+}
+
+func TestM3URoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *Entry
+		want  *Entry
+	}{
+		{
+			name:  "album and isrc aren't kept, the duration is rounded to seconds",
+			entry: &Entry{Location: "/music/one.mp3", Title: "One", Artist: "U2", Album: "Achtung Baby", Duration: 276500, ISRC: "GBAAN9100015"},
+			want:  &Entry{Location: "/music/one.mp3", Title: "One", Artist: "U2", Duration: 277000},
+		},
+		{
+			name:  "entry without artist",
+			entry: &Entry{Location: "https://example.com/stream.mp3", Title: "Radio"},
+			want:  &Entry{Location: "https://example.com/stream.mp3", Title: "Radio"},
+		},
+		{
+			name:  "entry without location is named after the track",
+			entry: &Entry{Title: "Song", Artist: "Artist", Duration: 180000},
+			want:  &Entry{Location: "Artist - Song", Title: "Song", Artist: "Artist", Duration: 180000},
+		},
+		{
+			name:  "line breaks are collapsed",
+			entry: &Entry{Location: "/music/a.mp3", Title: "Two\nLines", Artist: "Artist"},
+			want:  &Entry{Location: "/music/a.mp3", Title: "Two Lines", Artist: "Artist"},
+		},
+	}
+
+	for _, formatName := range []string{"m3u", "m3u8"} {
+		for _, tt := range tests {
+			t.Run(formatName+"/"+tt.name, func(t *testing.T) {
+				got := roundTrip(t, formatName, testPlaylist(tt.entry))
+				assertPlaylist(t, got, "Friday Set", []*Entry{tt.want})
+			})
+		}
+	}
+}
+
+func TestDecodeM3U(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		title string
+		want  []*Entry
+	}{
+		{
+			name:  "plain m3u with byte order mark",
+			input: "\ufeff/music/a.mp3\r\n\r\nrelative/b.flac\r\n",
+			want:  []*Entry{{Location: "/music/a.mp3"}, {Location: "relative/b.flac"}},
+		},
+		{
+			name:  "extinf attributes and unknown durations",
+			input: "#EXTM3U\n#PLAYLIST: Mix \n#EXTINF:-1 tvg-id=\"x\",Artist - Title - Edit\n/a.mp3\n#EXTGRP:ignored\n#EXTINF:12.5,No Artist\n/b.mp3\n",
+			title: "Mix",
+			want: []*Entry{
+				{Location: "/a.mp3", Title: "Title - Edit", Artist: "Artist"},
+				{Location: "/b.mp3", Title: "No Artist", Duration: 12500},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeM3U(bytes.NewBufferString(tt.input))
+
+			if err != nil {
+				t.Fatalf("DecodeM3U() error = %v", err)
+			}
+
+			assertPlaylist(t, got, tt.title, tt.want)
+		})
+	}
+}
+
+func TestXSPFRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *Entry
+	}{
+		{
+			name:  "every field is kept",
+			entry: &Entry{Location: "file:///music/one.mp3", Title: "One", Artist: "U2", Album: "Achtung Baby", Duration: 276500, ISRC: "GBAAN9100015", Identifier: "https://musicbrainz.org/recording/1"},
+		},
+		{
+			name:  "entry without location",
+			entry: &Entry{Title: "Song", Artist: "Artist <&>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := roundTrip(t, "xspf", testPlaylist(tt.entry))
+			assertPlaylist(t, got, "Friday Set", []*Entry{tt.entry})
+		})
+	}
+}
+
+func TestParseISRCIdentifier(t *testing.T) {
+	tests := []struct {
+		identifier string
+		want       string
+		ok         bool
+	}{
+		{"urn:isrc:GBAAN9100015", "GBAAN9100015", true},
+		{"isrc:gb-aan-91-00015", "GBAAN9100015", true},
+		{"GBAAN9100015", "GBAAN9100015", true},
+		{"https://musicbrainz.org/recording/1", "", false},
+		{"urn:isrc:GBAAN91", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseISRCIdentifier(tt.identifier)
+
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseISRCIdentifier(%q) = %q, %v, want %q, %v", tt.identifier, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRekordboxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *Entry
+		want  *Entry
+	}{
+		{
+			name:  "posix path, the duration is truncated to seconds",
+			entry: &Entry{Location: "/Users/dj/Music/One Two.mp3", Title: "One", Artist: "U2", Album: "Achtung Baby", Duration: 276500, ISRC: "GBAAN9100015"},
+			want:  &Entry{Location: "/Users/dj/Music/One Two.mp3", Title: "One", Artist: "U2", Album: "Achtung Baby", Duration: 276000},
+		},
+		{
+			name:  "windows path",
+			entry: &Entry{Location: `C:\Music\track #1.mp3`, Title: "Track"},
+			want:  &Entry{Location: "C:/Music/track #1.mp3", Title: "Track"},
+		},
+		{
+			name:  "file url",
+			entry: &Entry{Location: "file:///D:/Music/track.mp3", Title: "Track"},
+			want:  &Entry{Location: "D:/Music/track.mp3", Title: "Track"},
+		},
+		{
+			name:  "remote entries are kept as they are",
+			entry: &Entry{Location: "https://example.com/track.mp3", Title: "Track"},
+			want:  &Entry{Location: "https://example.com/track.mp3", Title: "Track"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := roundTrip(t, "rekordbox", testPlaylist(tt.entry))
+			assertPlaylist(t, got, "Friday Set", []*Entry{tt.want})
+		})
+	}
+}
+
+func TestTraktorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *Entry
+		want  *Entry
+	}{
+		{
+			name:  "posix path, the duration is truncated to seconds",
+			entry: &Entry{Location: "/home/dj/Music/one.mp3", Title: "One", Artist: "U2", Album: "Achtung Baby", Duration: 276500, ISRC: "GBAAN9100015"},
+			want:  &Entry{Location: "/home/dj/Music/one.mp3", Title: "One", Artist: "U2", Album: "Achtung Baby", Duration: 276000},
+		},
+		{
+			name:  "macOS external disk",
+			entry: &Entry{Location: "/Volumes/USB Stick/Sets/track.mp3", Title: "Track"},
+			want:  &Entry{Location: "/Volumes/USB Stick/Sets/track.mp3", Title: "Track"},
+		},
+		{
+			name:  "windows path with a lowercase drive",
+			entry: &Entry{Location: `c:\Music\Sets\track.mp3`, Title: "Track"},
+			want:  &Entry{Location: "C:/Music/Sets/track.mp3", Title: "Track"},
+		},
+		{
+			name:  "windows file url",
+			entry: &Entry{Location: "file:///D:/track.mp3", Title: "Track"},
+			want:  &Entry{Location: "D:/track.mp3", Title: "Track"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := roundTrip(t, "traktor", testPlaylist(tt.entry))
+			assertPlaylist(t, got, "Friday Set", []*Entry{tt.want})
+		})
+	}
+}
+
+func TestTraktorLeavesOutRemoteEntries(t *testing.T) {
+	playlist := testPlaylist(
+		&Entry{Location: "https://example.com/track.mp3", Title: "Remote"},
+		&Entry{Location: "/music/local.mp3", Title: "Local"},
+		&Entry{Location: "relative/track.mp3", Title: "Relative"},
+	)
+
+	got := roundTrip(t, "traktor", playlist)
+	assertPlaylist(t, got, "Friday Set", []*Entry{{Location: "/music/local.mp3", Title: "Local"}})
+
+	format, _ := GetFormat("traktor")
+	err := format.Encode(&bytes.Buffer{}, testPlaylist(&Entry{Location: "https://example.com/track.mp3", Title: "Remote"}))
+
+	if !errors.Is(err, ErrNoLocalFiles) {
+		t.Errorf("Encode() error = %v, want ErrNoLocalFiles", err)
+	}
+}
+
+func TestLocalFilePath(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+		ok       bool
+	}{
+		{"/music/track.mp3", "/music/track.mp3", true},
+		{`C:\Music\track.mp3`, "C:/Music/track.mp3", true},
+		{"/C:/Music/track.mp3", "C:/Music/track.mp3", true},
+		{"file://localhost/C:/Music/track%20one.mp3", "C:/Music/track one.mp3", true},
+		{"file:///home/dj/track.mp3", "/home/dj/track.mp3", true},
+		{"relative/track.mp3", "", false},
+		{"https://example.com/track.mp3", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := localFilePath(tt.location)
+
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("localFilePath(%q) = %q, %v, want %q, %v", tt.location, got, ok, tt.want, tt.ok)
+		}
+	}
+}
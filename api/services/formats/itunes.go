@@ -0,0 +1,119 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// the Library.xml exported by iTunes and the macOS Music app (File > Library > Export Library)
+
+type itunesLibraryFormat struct{}
+
+func init() {
+	RegisterLibraryFormat(&itunesLibraryFormat{})
+}
+
+func (f *itunesLibraryFormat) Name() string {
+	return "itunes"
+}
+
+func (f *itunesLibraryFormat) Match(fileName string, head []byte) bool {
+	return strings.EqualFold(path.Ext(fileName), ".xml") && bytes.Contains(head, []byte("<plist"))
+}
+
+/*
+returns the playlists made by the user, the library itself, folders, hidden and
+built in playlists (e.g Music, Podcasts) are left out.
+entries are read from the Name, Artist, Album, Total Time and Location of the tracks,
+videos and podcast episodes are skipped
+*/
+func (f *itunesLibraryFormat) Decode(fileName string, r io.Reader) ([]*Playlist, error) {
+	value, err := decodePlist(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	library, ok := value.(map[string]interface{})
+
+	if !ok {
+		return nil, ErrInvalidPlist
+	}
+
+	tracks, _ := library["Tracks"].(map[string]interface{})
+	items, _ := library["Playlists"].([]interface{})
+
+	playlists := []*Playlist{}
+
+	for _, item := range items {
+		dict, ok := item.(map[string]interface{})
+
+		if !ok || plistBool(dict, "Master") || plistBool(dict, "Folder") || dict["Distinguished Kind"] != nil {
+			continue
+		}
+
+		if visible, set := dict["Visible"].(bool); set && !visible {
+			continue
+		}
+
+		playlist := &Playlist{
+			Id:      plistString(dict, "Playlist Persistent ID"),
+			Title:   plistString(dict, "Name"),
+			Entries: []*Entry{},
+		}
+
+		if playlist.Id == "" {
+			playlist.Id = plistString(dict, "Playlist ID")
+		}
+
+		playlistItems, _ := dict["Playlist Items"].([]interface{})
+
+		for _, playlistItem := range playlistItems {
+			itemDict, _ := playlistItem.(map[string]interface{})
+			track, ok := tracks[plistString(itemDict, "Track ID")].(map[string]interface{})
+
+			if !ok || plistBool(track, "Podcast") || plistBool(track, "Movie") || plistBool(track, "TV Show") || plistBool(track, "Has Video") {
+				continue
+			}
+
+			entry := &Entry{
+				Location: plistString(track, "Location"),
+				Title:    plistString(track, "Name"),
+				Artist:   plistString(track, "Artist"),
+				Album:    plistString(track, "Album"),
+			}
+
+			// milliseconds
+			if duration, ok := track["Total Time"].(int64); ok && duration > 0 {
+				entry.Duration = duration
+			}
+
+			playlist.Entries = append(playlist.Entries, entry)
+		}
+
+		playlists = append(playlists, playlist)
+	}
+
+	return playlists, nil
+}
+
+// strings and integers of a dict as a string, empty when missing
+func plistString(dict map[string]interface{}, key string) string {
+	switch value := dict[key].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case int64:
+		return fmt.Sprint(value)
+	}
+
+	return ""
+}
+
+func plistBool(dict map[string]interface{}, key string) bool {
+	value, _ := dict[key].(bool)
+
+	return value
+}
@@ -0,0 +1,115 @@
+package formats
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// the JSON form of XSPF used by ListenBrainz https://musicbrainz.org/doc/jspf
+
+type jspfFormat struct{}
+
+func init() {
+	RegisterFormat(&jspfFormat{})
+}
+
+type jspfDocument struct {
+	Playlist *jspfPlaylist `json:"playlist"`
+}
+
+type jspfPlaylist struct {
+	Title  string       `json:"title,omitempty"`
+	Tracks []*jspfTrack `json:"track"`
+}
+
+type jspfTrack struct {
+	Location   stringList `json:"location,omitempty"`
+	Identifier stringList `json:"identifier,omitempty"`
+	Title      string     `json:"title,omitempty"`
+	Creator    string     `json:"creator,omitempty"`
+	Album      string     `json:"album,omitempty"`
+	// milliseconds
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// the spec uses arrays for location and identifier, some writers use a single string
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var single string
+
+	if err := json.Unmarshal(b, &single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+
+	var list []string
+
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+
+	*l = list
+
+	return nil
+}
+
+func (f *jspfFormat) Name() string {
+	return "jspf"
+}
+
+func (f *jspfFormat) Extensions() []string {
+	return []string{".jspf"}
+}
+
+func (f *jspfFormat) ContentType() string {
+	return "application/jspf+json"
+}
+
+func (f *jspfFormat) Decode(r io.Reader) (*Playlist, error) {
+	doc := &jspfDocument{}
+
+	if err := json.NewDecoder(r).Decode(doc); err != nil {
+		return nil, err
+	}
+
+	playlist := &Playlist{Entries: []*Entry{}}
+
+	if doc.Playlist == nil {
+		return playlist, nil
+	}
+
+	playlist.Title = doc.Playlist.Title
+
+	for _, track := range doc.Playlist.Tracks {
+		playlist.Entries = append(playlist.Entries, xspfEntry(track.Location, track.Identifier, track.Title, track.Creator, track.Album, track.Duration))
+	}
+
+	return playlist, nil
+}
+
+func (f *jspfFormat) Encode(w io.Writer, playlist *Playlist) error {
+	doc := &jspfDocument{Playlist: &jspfPlaylist{Title: playlist.Title, Tracks: []*jspfTrack{}}}
+
+	for _, entry := range playlist.Entries {
+		track := &jspfTrack{
+			Identifier: xspfIdentifiers(entry),
+			Title:      entry.Title,
+			Creator:    entry.Artist,
+			Album:      entry.Album,
+			Duration:   float64(entry.Duration),
+		}
+
+		if entry.Location != "" {
+			track.Location = stringList{entry.Location}
+		}
+
+		doc.Playlist.Tracks = append(doc.Playlist.Tracks, track)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(doc)
+}
@@ -0,0 +1,182 @@
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// the utf-8 byte order mark some editors write at the start of m3u8 files
+const byteOrderMark = "\ufeff"
+
+// m3u8 is the utf-8 variant of m3u, both are read and written as utf-8
+type m3uFormat struct {
+	name        string
+	extension   string
+	contentType string
+}
+
+func init() {
+	RegisterFormat(&m3uFormat{name: "m3u8", extension: ".m3u8", contentType: "audio/x-mpegurl; charset=utf-8"})
+	RegisterFormat(&m3uFormat{name: "m3u", extension: ".m3u", contentType: "audio/x-mpegurl"})
+}
+
+func (f *m3uFormat) Name() string {
+	return f.name
+}
+
+func (f *m3uFormat) Extensions() []string {
+	return []string{f.extension}
+}
+
+func (f *m3uFormat) ContentType() string {
+	return f.contentType
+}
+
+func (f *m3uFormat) Decode(r io.Reader) (*Playlist, error) {
+	return DecodeM3U(r)
+}
+
+func (f *m3uFormat) Encode(w io.Writer, playlist *Playlist) error {
+	return EncodeM3U(w, playlist)
+}
+
+/*
+reads an extended m3u playlist, plain m3u files without #EXTINF lines are read too
+
+the title and artist of an entry come from its "#EXTINF:{seconds},{artist} - {title}" line,
+the #PLAYLIST directive sets the playlist title
+*/
+func DecodeM3U(r io.Reader) (*Playlist, error) {
+	playlist := &Playlist{Entries: []*Entry{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var info *Entry
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, byteOrderMark)
+		}
+
+		switch {
+		case line == "" || line == "#EXTM3U":
+
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			playlist.Title = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info = parseExtInf(strings.TrimPrefix(line, "#EXTINF:"))
+
+		case strings.HasPrefix(line, "#"):
+			// other directives and comments
+
+		default:
+			entry := info
+			if entry == nil {
+				entry = &Entry{}
+			}
+
+			entry.Location = line
+			playlist.Entries = append(playlist.Entries, entry)
+			info = nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return playlist, nil
+}
+
+// parses "{seconds} {attributes},{artist} - {title}", the attributes are ignored
+func parseExtInf(value string) *Entry {
+	entry := &Entry{}
+
+	duration, display, found := strings.Cut(value, ",")
+
+	if !found {
+		display = ""
+	}
+
+	if fields := strings.Fields(duration); len(fields) > 0 {
+		if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil && seconds > 0 {
+			entry.Duration = int64(seconds * 1000)
+		}
+	}
+
+	display = strings.TrimSpace(display)
+
+	if artist, title, found := strings.Cut(display, " - "); found {
+		entry.Artist = strings.TrimSpace(artist)
+		entry.Title = strings.TrimSpace(title)
+	} else {
+		entry.Title = display
+	}
+
+	return entry
+}
+
+// writes an extended m3u playlist, the output is utf-8 so it is a valid m3u8 file
+func EncodeM3U(w io.Writer, playlist *Playlist) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("#EXTM3U\n")
+
+	if playlist.Title != "" {
+		bw.WriteString("#PLAYLIST:" + singleLine(playlist.Title) + "\n")
+	}
+
+	for _, entry := range playlist.Entries {
+		if err := writeM3UEntry(bw, entry); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// appends entries to an existing m3u playlist
+func AppendM3U(w io.Writer, entries []*Entry) error {
+	bw := bufio.NewWriter(w)
+
+	for _, entry := range entries {
+		if err := writeM3UEntry(bw, entry); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeM3UEntry(w *bufio.Writer, entry *Entry) error {
+	display := singleLine(entry.Title)
+	if entry.Artist != "" {
+		display = singleLine(entry.Artist) + " - " + display
+	}
+
+	// -1 is the m3u duration of unknown length entries
+	seconds := int64(-1)
+	if entry.Duration > 0 {
+		seconds = (entry.Duration + 500) / 1000
+	}
+
+	// every entry needs a location line, entries without a location are named after the track
+	location := singleLine(entry.Location)
+	if location == "" {
+		location = display
+	}
+
+	_, err := w.WriteString("#EXTINF:" + fmt.Sprint(seconds) + "," + display + "\n" + location + "\n")
+
+	return err
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
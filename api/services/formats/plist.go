@@ -0,0 +1,141 @@
+package formats
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPlist = errors.New("invalid property list")
+
+// deepest nesting of dicts and arrays read, libraries nest a few levels so deeper files are rejected before they exhaust the stack
+const maxPlistDepth = 64
+
+/*
+decodes an XML property list into maps, slices, strings, int64, float64 and bool values.
+dates are kept as strings and data as its base64 text
+*/
+func decodePlist(r io.Reader) (interface{}, error) {
+	decoder := xml.NewDecoder(r)
+
+	// the value is the first element inside the plist element
+	for {
+		token, err := decoder.Token()
+
+		if err == io.EOF {
+			return nil, ErrInvalidPlist
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local != "plist" {
+			return decodePlistValue(decoder, start, 0)
+		}
+	}
+}
+
+func decodePlistValue(decoder *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict", "array":
+		if depth >= maxPlistDepth {
+			return nil, ErrInvalidPlist
+		}
+
+		if start.Name.Local == "dict" {
+			return decodePlistDict(decoder, depth+1)
+		}
+
+		return decodePlistArray(decoder, depth+1)
+
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+
+		return start.Name.Local == "true", nil
+	}
+
+	var text string
+
+	if err := decoder.DecodeElement(&text, &start); err != nil {
+		return nil, err
+	}
+
+	text = strings.TrimSpace(text)
+
+	switch start.Name.Local {
+	case "integer":
+		return strconv.ParseInt(text, 10, 64)
+
+	case "real":
+		return strconv.ParseFloat(text, 64)
+	}
+
+	// string, date and data
+	return text, nil
+}
+
+func decodePlistDict(decoder *xml.Decoder, depth int) (map[string]interface{}, error) {
+	dict := map[string]interface{}{}
+	key := ""
+
+	for {
+		token, err := decoder.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			return dict, nil
+
+		case xml.StartElement:
+			if t.Name.Local == "key" {
+				if err := decoder.DecodeElement(&key, &t); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			value, err := decodePlistValue(decoder, t, depth)
+
+			if err != nil {
+				return nil, err
+			}
+
+			dict[key] = value
+		}
+	}
+}
+
+func decodePlistArray(decoder *xml.Decoder, depth int) ([]interface{}, error) {
+	array := []interface{}{}
+
+	for {
+		token, err := decoder.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			return array, nil
+
+		case xml.StartElement:
+			value, err := decodePlistValue(decoder, t, depth)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+	}
+}
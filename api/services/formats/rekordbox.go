@@ -0,0 +1,254 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// the rekordbox xml collection (File > Export Collection in xml format), imported in rekordbox from the xml view
+
+const (
+	rekordboxNodeFolder   = "0"
+	rekordboxNodePlaylist = "1"
+	// playlist tracks are keyed by the TrackID of the collection track, or by its Location
+	rekordboxKeyTrackId  = "0"
+	rekordboxKeyLocation = "1"
+)
+
+var ErrInvalidRekordbox = errors.New("invalid rekordbox xml")
+
+type rekordboxFormat struct{}
+
+type rekordboxLibraryFormat struct{}
+
+func init() {
+	RegisterFormat(&rekordboxFormat{})
+	RegisterLibraryFormat(&rekordboxLibraryFormat{})
+}
+
+type rekordboxDocument struct {
+	XMLName xml.Name `xml:"DJ_PLAYLISTS"`
+	Version string   `xml:"Version,attr"`
+	Product struct {
+		Name    string `xml:"Name,attr"`
+		Version string `xml:"Version,attr"`
+		Company string `xml:"Company,attr"`
+	} `xml:"PRODUCT"`
+	Collection struct {
+		Entries int               `xml:"Entries,attr"`
+		Tracks  []*rekordboxTrack `xml:"TRACK"`
+	} `xml:"COLLECTION"`
+	Playlists struct {
+		Root *rekordboxNode `xml:"NODE"`
+	} `xml:"PLAYLISTS"`
+}
+
+type rekordboxTrack struct {
+	TrackID string `xml:"TrackID,attr"`
+	Name    string `xml:"Name,attr"`
+	Artist  string `xml:"Artist,attr"`
+	Album   string `xml:"Album,attr,omitempty"`
+	// seconds
+	TotalTime string `xml:"TotalTime,attr,omitempty"`
+	Location  string `xml:"Location,attr"`
+}
+
+// folders (Type 0) hold nodes, playlists (Type 1) hold tracks
+type rekordboxNode struct {
+	Type    string `xml:"Type,attr"`
+	Name    string `xml:"Name,attr"`
+	Count   string `xml:"Count,attr,omitempty"`
+	KeyType string `xml:"KeyType,attr,omitempty"`
+	Entries string `xml:"Entries,attr,omitempty"`
+
+	Nodes  []*rekordboxNode `xml:"NODE"`
+	Tracks []*rekordboxKey  `xml:"TRACK"`
+}
+
+type rekordboxKey struct {
+	Key string `xml:"Key,attr"`
+}
+
+func (f *rekordboxFormat) Name() string {
+	return "rekordbox"
+}
+
+func (f *rekordboxFormat) Extensions() []string {
+	return []string{".xml"}
+}
+
+func (f *rekordboxFormat) ContentType() string {
+	return "application/xml"
+}
+
+// reads the first playlist of the collection, collections with several playlists are uploaded as a library
+func (f *rekordboxFormat) Decode(r io.Reader) (*Playlist, error) {
+	playlists, err := decodeRekordbox(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return playlists[0], nil
+}
+
+// writes a collection of the entries holding the playlist, entries without a location are kept so they are listed as missing
+func (f *rekordboxFormat) Encode(w io.Writer, playlist *Playlist) error {
+	doc := &rekordboxDocument{Version: "1.0.0"}
+	doc.Product.Name = "music-playlist-converter"
+	doc.Product.Version = "1.0.0"
+	doc.Product.Company = "music-playlist-converter"
+	doc.Collection.Entries = len(playlist.Entries)
+	doc.Collection.Tracks = []*rekordboxTrack{}
+
+	node := &rekordboxNode{
+		Type:    rekordboxNodePlaylist,
+		Name:    playlist.Title,
+		KeyType: rekordboxKeyTrackId,
+		Entries: strconv.Itoa(len(playlist.Entries)),
+	}
+
+	for i, entry := range playlist.Entries {
+		track := &rekordboxTrack{
+			TrackID:  strconv.Itoa(i + 1),
+			Name:     entry.Title,
+			Artist:   entry.Artist,
+			Album:    entry.Album,
+			Location: entry.Location,
+		}
+
+		if filePath, ok := localFilePath(entry.Location); ok {
+			track.Location = fileURL(filePath)
+		}
+
+		if entry.Duration > 0 {
+			track.TotalTime = strconv.FormatInt(entry.Duration/1000, 10)
+		}
+
+		doc.Collection.Tracks = append(doc.Collection.Tracks, track)
+		node.Tracks = append(node.Tracks, &rekordboxKey{Key: track.TrackID})
+	}
+
+	doc.Playlists.Root = &rekordboxNode{Type: rekordboxNodeFolder, Name: "ROOT", Count: "1", Nodes: []*rekordboxNode{node}}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+func (f *rekordboxLibraryFormat) Name() string {
+	return "rekordbox"
+}
+
+func (f *rekordboxLibraryFormat) Match(fileName string, head []byte) bool {
+	return strings.EqualFold(path.Ext(fileName), ".xml") && bytes.Contains(head, []byte("<DJ_PLAYLISTS"))
+}
+
+func (f *rekordboxLibraryFormat) Decode(fileName string, r io.Reader) ([]*Playlist, error) {
+	return decodeRekordbox(r)
+}
+
+/*
+returns the playlists of the collection, ids are the folder path of the playlists e.g "Sets/Friday",
+a collection without playlists is returned as a single "Collection" playlist
+*/
+func decodeRekordbox(r io.Reader) ([]*Playlist, error) {
+	doc := &rekordboxDocument{}
+
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, err
+	}
+
+	tracksById := map[string]*rekordboxTrack{}
+	tracksByLocation := map[string]*rekordboxTrack{}
+
+	for _, track := range doc.Collection.Tracks {
+		tracksById[track.TrackID] = track
+		tracksByLocation[track.Location] = track
+	}
+
+	playlists := []*Playlist{}
+	seen := map[string]bool{}
+
+	var walk func(node *rekordboxNode, folder string)
+	walk = func(node *rekordboxNode, folder string) {
+		if node.Type != rekordboxNodePlaylist {
+			for _, child := range node.Nodes {
+				walk(child, path.Join(folder, child.Name))
+			}
+
+			return
+		}
+
+		playlist := &Playlist{Id: uniqueId(folder, node.Name, seen), Title: strings.TrimSpace(node.Name), Entries: []*Entry{}}
+
+		for _, key := range node.Tracks {
+			track := tracksById[key.Key]
+			if node.KeyType == rekordboxKeyLocation {
+				track = tracksByLocation[key.Key]
+			}
+
+			if track != nil {
+				playlist.Entries = append(playlist.Entries, track.entry())
+			}
+		}
+
+		playlists = append(playlists, playlist)
+	}
+
+	if doc.Playlists.Root != nil {
+		walk(doc.Playlists.Root, "")
+	}
+
+	if len(playlists) > 0 {
+		return playlists, nil
+	}
+
+	if len(doc.Collection.Tracks) == 0 {
+		return nil, ErrInvalidRekordbox
+	}
+
+	collection := &Playlist{Id: "collection", Title: "Collection", Entries: []*Entry{}}
+
+	for _, track := range doc.Collection.Tracks {
+		collection.Entries = append(collection.Entries, track.entry())
+	}
+
+	return []*Playlist{collection}, nil
+}
+
+// locations are file urls e.g file://localhost/C:/Music/track.mp3
+func (t *rekordboxTrack) entry() *Entry {
+	entry := &Entry{
+		Location: t.Location,
+		Title:    strings.TrimSpace(t.Name),
+		Artist:   strings.TrimSpace(t.Artist),
+		Album:    strings.TrimSpace(t.Album),
+	}
+
+	if filePath, ok := localFilePath(t.Location); ok {
+		entry.Location = filePath
+	}
+
+	if seconds, err := strconv.ParseFloat(t.TotalTime, 64); err == nil && seconds > 0 {
+		entry.Duration = int64(seconds * 1000)
+	}
+
+	return entry
+}
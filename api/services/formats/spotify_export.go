@@ -0,0 +1,144 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/*
+the Playlist1.json and YourLibrary.json files of the Spotify account data export
+(Privacy settings > Download your data), the saved tracks of YourLibrary.json are read as the "Liked Songs" playlist
+*/
+
+type spotifyExportFormat struct{}
+
+func init() {
+	RegisterLibraryFormat(&spotifyExportFormat{})
+}
+
+type spotifyExport struct {
+	Playlists []*struct {
+		Name  string `json:"name"`
+		Items []*struct {
+			Track *struct {
+				TrackName  string `json:"trackName"`
+				ArtistName string `json:"artistName"`
+				AlbumName  string `json:"albumName"`
+				TrackUri   string `json:"trackUri"`
+			} `json:"track"`
+			LocalTrack *struct {
+				Uri string `json:"uri"`
+			} `json:"localTrack"`
+		} `json:"items"`
+	} `json:"playlists"`
+
+	// YourLibrary.json
+	Tracks []*struct {
+		Artist string `json:"artist"`
+		Album  string `json:"album"`
+		Track  string `json:"track"`
+		Uri    string `json:"uri"`
+	} `json:"tracks"`
+}
+
+func (f *spotifyExportFormat) Name() string {
+	return "spotify-export"
+}
+
+func (f *spotifyExportFormat) Match(fileName string, head []byte) bool {
+	if !strings.EqualFold(path.Ext(fileName), ".json") {
+		return false
+	}
+
+	name := path.Base(fileName)
+
+	return strings.HasPrefix(name, "Playlist") || strings.HasPrefix(name, "YourLibrary") || bytes.Contains(head, []byte(`"playlists"`))
+}
+
+// episodes and audiobooks of playlists are skipped
+func (f *spotifyExportFormat) Decode(fileName string, r io.Reader) ([]*Playlist, error) {
+	export := &spotifyExport{}
+
+	if err := json.NewDecoder(r).Decode(export); err != nil {
+		return nil, err
+	}
+
+	playlists := []*Playlist{}
+
+	for i, item := range export.Playlists {
+		if item == nil {
+			continue
+		}
+
+		playlist := &Playlist{Id: strconv.Itoa(i + 1), Title: item.Name, Entries: []*Entry{}}
+
+		for _, playlistItem := range item.Items {
+			var entry *Entry
+
+			switch {
+			case playlistItem == nil:
+			case playlistItem.Track != nil:
+				entry = &Entry{
+					Location: playlistItem.Track.TrackUri,
+					Title:    playlistItem.Track.TrackName,
+					Artist:   playlistItem.Track.ArtistName,
+					Album:    playlistItem.Track.AlbumName,
+				}
+			case playlistItem.LocalTrack != nil:
+				entry = spotifyLocalTrack(playlistItem.LocalTrack.Uri)
+			}
+
+			if entry != nil {
+				playlist.Entries = append(playlist.Entries, entry)
+			}
+		}
+
+		playlists = append(playlists, playlist)
+	}
+
+	if len(export.Tracks) > 0 {
+		liked := &Playlist{Id: "liked", Title: "Liked Songs", Entries: []*Entry{}}
+
+		for _, track := range export.Tracks {
+			if track != nil {
+				liked.Entries = append(liked.Entries, &Entry{Location: track.Uri, Title: track.Track, Artist: track.Artist, Album: track.Album})
+			}
+		}
+
+		playlists = append(playlists, liked)
+	}
+
+	return playlists, nil
+}
+
+// local files are exported as "spotify:local:{artist}:{album}:{title}:{seconds}" with query escaped fields
+func spotifyLocalTrack(uri string) *Entry {
+	parts := strings.Split(uri, ":")
+
+	if len(parts) != 6 || parts[1] != "local" {
+		return nil
+	}
+
+	field := func(i int) string {
+		value, err := url.QueryUnescape(parts[i])
+
+		if err != nil {
+			return parts[i]
+		}
+
+		return strings.TrimSpace(value)
+	}
+
+	entry := &Entry{Location: uri, Artist: field(2), Album: field(3), Title: field(4)}
+
+	if seconds, err := strconv.Atoi(parts[5]); err == nil && seconds > 0 {
+		entry.Duration = int64(seconds) * 1000
+	}
+
+	return entry
+}
@@ -0,0 +1,152 @@
+package formats
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"strings"
+)
+
+/*
+the playlist csv files of the YouTube and YouTube Music data in Google Takeout.
+
+older exports write the playlist details before the videos:
+
+	Playlist Id,Channel Id,Time Created,Time Updated,Title,Description,Visibility
+	{details}
+
+	Video Id,Time Added
+
+newer exports write "{title}-videos.csv" files starting with "Video ID,Playlist Video Creation Timestamp",
+the "music library songs.csv" file also names the songs
+*/
+
+type takeoutFormat struct{}
+
+func init() {
+	RegisterLibraryFormat(&takeoutFormat{})
+}
+
+// videos are identified by their YouTube Music url, the tracks of videos without a song title are named from it
+const youtubeMusicWatchURL = "https://music.youtube.com/watch?v="
+
+func (f *takeoutFormat) Name() string {
+	return "google-takeout"
+}
+
+func (f *takeoutFormat) Match(fileName string, head []byte) bool {
+	if !strings.EqualFold(path.Ext(fileName), ".csv") {
+		return false
+	}
+
+	firstLine := strings.ToLower(string(bytes.TrimPrefix(head, []byte(byteOrderMark))))
+
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	return strings.HasPrefix(firstLine, "playlist id,channel id") || strings.HasPrefix(firstLine, "video id,")
+}
+
+// a file holds a single playlist, entries without a song title are named by looking up their video
+func (f *takeoutFormat) Decode(fileName string, r io.Reader) ([]*Playlist, error) {
+	reader := newCSVReader(r)
+
+	headers, err := readCSVHeaders(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(path.Base(fileName), path.Ext(fileName))
+	playlist := &Playlist{Id: name, Title: strings.TrimSuffix(name, "-videos"), Entries: []*Entry{}}
+
+	if strings.EqualFold(headers[0], "Playlist Id") {
+		details, err := reader.Read()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i, header := range headers {
+			if i >= len(details) {
+				break
+			}
+
+			switch strings.ToLower(header) {
+			case "playlist id":
+				playlist.Id = details[i]
+			case "title":
+				playlist.Title = details[i]
+			}
+		}
+
+		// the blank line is skipped by the reader
+		if headers, err = reader.Read(); err != nil {
+			if err == io.EOF {
+				return []*Playlist{playlist}, nil
+			}
+
+			return nil, err
+		}
+	}
+
+	videoIndex, titleIndex, albumIndex := -1, -1, -1
+	artistIndexes := []int{}
+
+	for i, header := range headers {
+		switch header = strings.ToLower(strings.TrimSpace(header)); {
+		case header == "video id":
+			videoIndex = i
+		case header == "song title":
+			titleIndex = i
+		case header == "album title":
+			albumIndex = i
+		case strings.HasPrefix(header, "artist name"):
+			artistIndexes = append(artistIndexes, i)
+		}
+	}
+
+	field := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+
+		return strings.TrimSpace(row[i])
+	}
+
+	for {
+		row, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		videoId := field(row, videoIndex)
+
+		if videoId == "" {
+			continue
+		}
+
+		artists := []string{}
+
+		for _, i := range artistIndexes {
+			if artist := field(row, i); artist != "" {
+				artists = append(artists, artist)
+			}
+		}
+
+		playlist.Entries = append(playlist.Entries, &Entry{
+			Identifier: youtubeMusicWatchURL + videoId,
+			Title:      field(row, titleIndex),
+			Artist:     strings.Join(artists, ", "),
+			Album:      field(row, albumIndex),
+		})
+	}
+
+	return []*Playlist{playlist}, nil
+}
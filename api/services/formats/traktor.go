@@ -0,0 +1,360 @@
+package formats
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// the Traktor collection.nml, playlists exported from Traktor and imported with Import Playlist are nml files too
+
+const (
+	traktorNodeFolder   = "FOLDER"
+	traktorNodePlaylist = "PLAYLIST"
+	// folders of a location are separated by "/:" e.g "/:Users/:dj/:Music/:"
+	traktorDirSeparator = "/:"
+)
+
+var (
+	ErrInvalidTraktor = errors.New("invalid traktor nml")
+	// traktor playlists only reference files, a playlist without any local file can't be written
+	ErrNoLocalFiles = errors.New("playlist has no local files")
+)
+
+type traktorFormat struct{}
+
+type traktorLibraryFormat struct{}
+
+func init() {
+	RegisterFormat(&traktorFormat{})
+	RegisterLibraryFormat(&traktorLibraryFormat{})
+}
+
+type traktorDocument struct {
+	XMLName xml.Name `xml:"NML"`
+	Version string   `xml:"VERSION,attr"`
+	Head    struct {
+		Company string `xml:"COMPANY,attr"`
+		Program string `xml:"PROGRAM,attr"`
+	} `xml:"HEAD"`
+	Collection struct {
+		Count   int             `xml:"ENTRIES,attr"`
+		Entries []*traktorEntry `xml:"ENTRY"`
+	} `xml:"COLLECTION"`
+	Playlists struct {
+		Root *traktorNode `xml:"NODE"`
+	} `xml:"PLAYLISTS"`
+}
+
+type traktorEntry struct {
+	Title    string           `xml:"TITLE,attr"`
+	Artist   string           `xml:"ARTIST,attr,omitempty"`
+	Location *traktorLocation `xml:"LOCATION"`
+	Album    *traktorAlbum    `xml:"ALBUM"`
+	Info     *traktorInfo     `xml:"INFO"`
+}
+
+type traktorAlbum struct {
+	Title string `xml:"TITLE,attr"`
+}
+
+type traktorInfo struct {
+	// seconds
+	Playtime string `xml:"PLAYTIME,attr,omitempty"`
+}
+
+type traktorLocation struct {
+	Dir    string `xml:"DIR,attr"`
+	File   string `xml:"FILE,attr"`
+	Volume string `xml:"VOLUME,attr"`
+}
+
+// folders hold subnodes, playlists hold the keys of their collection entries
+type traktorNode struct {
+	Type     string           `xml:"TYPE,attr"`
+	Name     string           `xml:"NAME,attr"`
+	Subnodes *traktorSubnodes `xml:"SUBNODES"`
+	Playlist *traktorPlaylist `xml:"PLAYLIST"`
+}
+
+type traktorSubnodes struct {
+	Count int            `xml:"COUNT,attr"`
+	Nodes []*traktorNode `xml:"NODE"`
+}
+
+type traktorPlaylist struct {
+	Count   int                     `xml:"ENTRIES,attr"`
+	Type    string                  `xml:"TYPE,attr"`
+	UUID    string                  `xml:"UUID,attr"`
+	Entries []*traktorPlaylistEntry `xml:"ENTRY"`
+}
+
+type traktorPlaylistEntry struct {
+	PrimaryKey traktorPrimaryKey `xml:"PRIMARYKEY"`
+}
+
+type traktorPrimaryKey struct {
+	Type string `xml:"TYPE,attr"`
+	Key  string `xml:"KEY,attr"`
+}
+
+func (f *traktorFormat) Name() string {
+	return "traktor"
+}
+
+func (f *traktorFormat) Extensions() []string {
+	return []string{".nml"}
+}
+
+func (f *traktorFormat) ContentType() string {
+	return "application/xml"
+}
+
+// reads the first playlist of the collection, collections with several playlists are uploaded as a library
+func (f *traktorFormat) Decode(r io.Reader) (*Playlist, error) {
+	playlists, err := decodeTraktor(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return playlists[0], nil
+}
+
+// playlist entries reference collection entries by their file, entries that aren't local files are left out.
+// ErrNoLocalFiles is returned when none of the entries is a local file
+func (f *traktorFormat) Encode(w io.Writer, playlist *Playlist) error {
+	doc := &traktorDocument{Version: "19"}
+	doc.Head.Company = "www.native-instruments.com"
+	doc.Head.Program = "Traktor"
+	doc.Collection.Entries = []*traktorEntry{}
+
+	node := &traktorNode{Type: traktorNodePlaylist, Name: playlist.Title}
+	node.Playlist = &traktorPlaylist{Type: "LIST", UUID: traktorUUID(), Entries: []*traktorPlaylistEntry{}}
+
+	for _, entry := range playlist.Entries {
+		filePath, ok := localFilePath(entry.Location)
+
+		if !ok {
+			continue
+		}
+
+		location := traktorFileLocation(filePath)
+		collectionEntry := &traktorEntry{Title: entry.Title, Artist: entry.Artist, Location: location}
+
+		if entry.Album != "" {
+			collectionEntry.Album = &traktorAlbum{Title: entry.Album}
+		}
+
+		if entry.Duration > 0 {
+			collectionEntry.Info = &traktorInfo{Playtime: strconv.FormatInt(entry.Duration/1000, 10)}
+		}
+
+		doc.Collection.Entries = append(doc.Collection.Entries, collectionEntry)
+
+		node.Playlist.Entries = append(node.Playlist.Entries, &traktorPlaylistEntry{
+			PrimaryKey: traktorPrimaryKey{Type: "TRACK", Key: location.key()},
+		})
+	}
+
+	if len(node.Playlist.Entries) == 0 {
+		return ErrNoLocalFiles
+	}
+
+	doc.Collection.Count = len(doc.Collection.Entries)
+	node.Playlist.Count = len(node.Playlist.Entries)
+
+	doc.Playlists.Root = &traktorNode{Type: traktorNodeFolder, Name: "$ROOT"}
+	doc.Playlists.Root.Subnodes = &traktorSubnodes{Count: 1, Nodes: []*traktorNode{node}}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+func (f *traktorLibraryFormat) Name() string {
+	return "traktor"
+}
+
+func (f *traktorLibraryFormat) Match(fileName string, head []byte) bool {
+	return strings.EqualFold(path.Ext(fileName), ".nml") && bytes.Contains(head, []byte("<NML"))
+}
+
+func (f *traktorLibraryFormat) Decode(fileName string, r io.Reader) ([]*Playlist, error) {
+	return decodeTraktor(r)
+}
+
+/*
+returns the playlists of the collection, ids are the folder path of the playlists e.g "Sets/Friday",
+smart lists are left out and a collection without playlists is returned as a single "Collection" playlist
+*/
+func decodeTraktor(r io.Reader) ([]*Playlist, error) {
+	doc := &traktorDocument{}
+
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, err
+	}
+
+	entriesByKey := map[string]*traktorEntry{}
+
+	for _, entry := range doc.Collection.Entries {
+		if entry.Location != nil {
+			entriesByKey[entry.Location.key()] = entry
+		}
+	}
+
+	playlists := []*Playlist{}
+	seen := map[string]bool{}
+
+	var walk func(node *traktorNode, folder string)
+	walk = func(node *traktorNode, folder string) {
+		if node.Type == traktorNodeFolder && node.Subnodes != nil {
+			for _, child := range node.Subnodes.Nodes {
+				walk(child, path.Join(folder, child.Name))
+			}
+
+			return
+		}
+
+		if node.Type != traktorNodePlaylist || node.Playlist == nil {
+			return
+		}
+
+		playlist := &Playlist{Id: uniqueId(folder, node.Name, seen), Title: strings.TrimSpace(node.Name), Entries: []*Entry{}}
+
+		for _, key := range node.Playlist.Entries {
+			if entry, found := entriesByKey[key.PrimaryKey.Key]; found {
+				playlist.Entries = append(playlist.Entries, entry.entry())
+			}
+		}
+
+		playlists = append(playlists, playlist)
+	}
+
+	if doc.Playlists.Root != nil {
+		walk(doc.Playlists.Root, "")
+	}
+
+	if len(playlists) > 0 {
+		return playlists, nil
+	}
+
+	if len(doc.Collection.Entries) == 0 {
+		return nil, ErrInvalidTraktor
+	}
+
+	collection := &Playlist{Id: "collection", Title: "Collection", Entries: []*Entry{}}
+
+	for _, entry := range doc.Collection.Entries {
+		collection.Entries = append(collection.Entries, entry.entry())
+	}
+
+	return []*Playlist{collection}, nil
+}
+
+func (e *traktorEntry) entry() *Entry {
+	entry := &Entry{
+		Title:  strings.TrimSpace(e.Title),
+		Artist: strings.TrimSpace(e.Artist),
+	}
+
+	if e.Location != nil {
+		entry.Location = e.Location.path()
+	}
+
+	if e.Album != nil {
+		entry.Album = strings.TrimSpace(e.Album.Title)
+	}
+
+	if e.Info != nil {
+		if seconds, err := strconv.ParseFloat(e.Info.Playtime, 64); err == nil && seconds > 0 {
+			entry.Duration = int64(seconds * 1000)
+		}
+	}
+
+	return entry
+}
+
+// playlists reference collection entries by the volume, folders and file name of the location
+func (l *traktorLocation) key() string {
+	return l.Volume + l.Dir + l.File
+}
+
+/*
+windows volumes are drive letters e.g "C:", the location is then C:/folder/file,
+on macOS the volume is the disk name, mounted at /Volumes/{disk}
+*/
+func (l *traktorLocation) path() string {
+	dir := strings.Join(strings.Split(strings.Trim(l.Dir, traktorDirSeparator), traktorDirSeparator), "/")
+
+	if dir != "" {
+		dir += "/"
+	}
+
+	if strings.HasSuffix(l.Volume, ":") {
+		return l.Volume + "/" + dir + l.File
+	}
+
+	if l.Volume != "" {
+		return "/Volumes/" + l.Volume + "/" + dir + l.File
+	}
+
+	return "/" + dir + l.File
+}
+
+/*
+splits an absolute path into a traktor location, paths on external macOS disks (/Volumes/{disk}/...)
+get the disk as volume, other posix paths are left without a volume
+*/
+func traktorFileLocation(filePath string) *traktorLocation {
+	location := &traktorLocation{}
+	dir, file := path.Split(filePath)
+
+	if drive := windowsPathPattern.FindString(dir); drive != "" {
+		location.Volume = strings.ToUpper(strings.Trim(drive, "/\\"))
+		dir = dir[len(drive)-1:]
+	} else if strings.HasPrefix(dir, "/Volumes/") {
+		volume, rest, _ := strings.Cut(strings.TrimPrefix(dir, "/Volumes/"), "/")
+		location.Volume = volume
+		dir = "/" + rest
+	}
+
+	location.File = file
+	location.Dir = traktorDirSeparator
+
+	for _, folder := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if folder != "" {
+			location.Dir += folder + traktorDirSeparator
+		}
+	}
+
+	return location
+}
+
+// traktor playlist uuids are 32 hex characters
+func traktorUUID() string {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,179 @@
+package formats
+
+import (
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// XML Shareable Playlist Format, read and written by VLC among others https://xspf.org/spec
+
+const xspfNamespace = "http://xspf.org/ns/0/"
+
+// an ISRC is written as a "urn:isrc:{ISRC}" identifier
+const isrcURNPrefix = "urn:isrc:"
+
+var isrcPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$`)
+
+type xspfFormat struct{}
+
+func init() {
+	RegisterFormat(&xspfFormat{})
+}
+
+// the namespace is not required so files written without it are read too
+type xspfPlaylist struct {
+	XMLName   xml.Name `xml:"playlist"`
+	Namespace string   `xml:"xmlns,attr,omitempty"`
+	Version   string   `xml:"version,attr"`
+	Title     string   `xml:"title,omitempty"`
+	TrackList struct {
+		Tracks []*xspfTrack `xml:"track"`
+	} `xml:"trackList"`
+}
+
+type xspfTrack struct {
+	Locations   []string `xml:"location"`
+	Identifiers []string `xml:"identifier"`
+	Title       string   `xml:"title,omitempty"`
+	Creator     string   `xml:"creator,omitempty"`
+	Album       string   `xml:"album,omitempty"`
+	// milliseconds
+	Duration string `xml:"duration,omitempty"`
+}
+
+func (f *xspfFormat) Name() string {
+	return "xspf"
+}
+
+func (f *xspfFormat) Extensions() []string {
+	return []string{".xspf"}
+}
+
+func (f *xspfFormat) ContentType() string {
+	return "application/xspf+xml"
+}
+
+func (f *xspfFormat) Decode(r io.Reader) (*Playlist, error) {
+	doc := &xspfPlaylist{}
+
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, err
+	}
+
+	playlist := &Playlist{Title: strings.TrimSpace(doc.Title), Entries: []*Entry{}}
+
+	for _, track := range doc.TrackList.Tracks {
+		duration, _ := strconv.ParseFloat(strings.TrimSpace(track.Duration), 64)
+
+		playlist.Entries = append(playlist.Entries, xspfEntry(track.Locations, track.Identifiers, track.Title, track.Creator, track.Album, duration))
+	}
+
+	return playlist, nil
+}
+
+func (f *xspfFormat) Encode(w io.Writer, playlist *Playlist) error {
+	doc := &xspfPlaylist{Namespace: xspfNamespace, Version: "1", Title: playlist.Title}
+	doc.TrackList.Tracks = []*xspfTrack{}
+
+	for _, entry := range playlist.Entries {
+		track := &xspfTrack{
+			Identifiers: xspfIdentifiers(entry),
+			Title:       entry.Title,
+			Creator:     entry.Artist,
+			Album:       entry.Album,
+		}
+
+		if entry.Location != "" {
+			track.Locations = []string{entry.Location}
+		}
+
+		if entry.Duration > 0 {
+			track.Duration = strconv.FormatInt(entry.Duration, 10)
+		}
+
+		doc.TrackList.Tracks = append(doc.TrackList.Tracks, track)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+/*
+maps the fields of an xspf or jspf track onto an entry,
+the first location is kept and ISRC identifiers set the ISRC of the entry
+*/
+func xspfEntry(locations []string, identifiers []string, title string, creator string, album string, duration float64) *Entry {
+	entry := &Entry{
+		Title:  strings.TrimSpace(title),
+		Artist: strings.TrimSpace(creator),
+		Album:  strings.TrimSpace(album),
+	}
+
+	if duration > 0 {
+		entry.Duration = int64(duration)
+	}
+
+	for _, location := range locations {
+		if location = strings.TrimSpace(location); location != "" {
+			entry.Location = location
+			break
+		}
+	}
+
+	for _, identifier := range identifiers {
+		identifier = strings.TrimSpace(identifier)
+
+		if isrc, ok := parseISRCIdentifier(identifier); ok {
+			if entry.ISRC == "" {
+				entry.ISRC = isrc
+			}
+		} else if identifier != "" && entry.Identifier == "" {
+			entry.Identifier = identifier
+		}
+	}
+
+	return entry
+}
+
+func xspfIdentifiers(entry *Entry) []string {
+	identifiers := []string{}
+
+	if entry.Identifier != "" {
+		identifiers = append(identifiers, entry.Identifier)
+	}
+
+	if entry.ISRC != "" {
+		identifiers = append(identifiers, isrcURNPrefix+entry.ISRC)
+	}
+
+	return identifiers
+}
+
+// accepts "urn:isrc:{ISRC}", "isrc:{ISRC}" and bare ISRCs, with or without hyphens
+func parseISRCIdentifier(identifier string) (string, bool) {
+	value := strings.ToUpper(identifier)
+	value = strings.TrimPrefix(value, "URN:")
+	value = strings.TrimPrefix(value, "ISRC:")
+	value = strings.ReplaceAll(value, "-", "")
+
+	if !isrcPattern.MatchString(value) {
+		return "", false
+	}
+
+	return value, true
+}
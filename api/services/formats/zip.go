@@ -0,0 +1,171 @@
+package formats
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+)
+
+// zip archives of data exports e.g the Spotify export or Google Takeout, the library files inside are read
+
+type zipLibraryFormat struct{}
+
+// files of an archive read at most, data exports hold a few hundred files
+const maxZipFiles = 1000
+
+var (
+	// largest uncompressed size of a file of an archive, set from MAX_ZIP_FILE_SIZE_MB by the router
+	MaxZipFileSize int64 = 64 * 1024 * 1024
+
+	ErrZipTooManyFiles = errors.New("zip archive has too many files")
+	ErrZipFileTooLarge = errors.New("zip archive file is too large")
+)
+
+func init() {
+	RegisterLibraryFormat(&zipLibraryFormat{})
+}
+
+func (f *zipLibraryFormat) Name() string {
+	return "zip"
+}
+
+func (f *zipLibraryFormat) Match(fileName string, head []byte) bool {
+	return strings.EqualFold(path.Ext(fileName), ".zip") && bytes.HasPrefix(head, []byte("PK"))
+}
+
+/*
+files that fail to decode or are larger than MaxZipFileSize are skipped, the ids of playlists are made unique across files.
+uploads that can be read at any offset aren't copied into memory
+*/
+func (f *zipLibraryFormat) Decode(fileName string, r io.Reader) ([]*Playlist, error) {
+	archive, err := openZip(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(archive.File) > maxZipFiles {
+		return nil, ErrZipTooManyFiles
+	}
+
+	playlists := []*Playlist{}
+	seen := map[string]bool{}
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		decoded, err := decodeZipFile(file)
+
+		if err != nil {
+			log.Println("error decoding " + file.Name + " - " + err.Error())
+			continue
+		}
+
+		for i, playlist := range decoded {
+			playlist.Id = uniqueId(playlist.Id, file.Name+"#"+fmt.Sprint(i+1), seen)
+			playlists = append(playlists, playlist)
+		}
+	}
+
+	return playlists, nil
+}
+
+// zip archives are read from their end
+func openZip(r io.Reader) (*zip.Reader, error) {
+	if ra, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		size, err := ra.Seek(0, io.SeekEnd)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return zip.NewReader(ra, size)
+	}
+
+	b, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return zip.NewReader(bytes.NewReader(b), int64(len(b)))
+}
+
+// returns nil for files that aren't library files, nested archives are not read
+func decodeZipFile(file *zip.File) ([]*Playlist, error) {
+	if file.UncompressedSize64 > uint64(MaxZipFileSize) {
+		return nil, ErrZipFileTooLarge
+	}
+
+	rc, err := file.Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rc.Close()
+
+	// the size in the archive header can't be trusted
+	br := bufio.NewReaderSize(&sizeLimitedReader{r: rc, remaining: MaxZipFileSize}, LibraryHeadSize)
+	head, _ := br.Peek(LibraryHeadSize)
+	format, ok := DetectLibraryFormat(file.Name, head)
+
+	if !ok || format.Name() == "zip" {
+		return nil, nil
+	}
+
+	return format.Decode(file.Name, br)
+}
+
+// fails with ErrZipFileTooLarge instead of stopping at the limit, so truncated files aren't decoded
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrZipFileTooLarge
+	}
+
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+
+	if l.remaining < 0 {
+		return n, ErrZipFileTooLarge
+	}
+
+	return n, err
+}
+
+// appends "#{n}" to ids already used by other files of the archive
+func uniqueId(id string, fallback string, seen map[string]bool) string {
+	if id == "" {
+		id = fallback
+	}
+
+	unique := id
+
+	for n := 2; seen[unique]; n++ {
+		unique = id + "#" + fmt.Sprint(n)
+	}
+
+	seen[unique] = true
+
+	return unique
+}
@@ -0,0 +1,342 @@
+package jellyfin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/initializers"
+)
+
+// jellyfin durations are in ticks of 100 nanoseconds
+const ticksPerMillisecond = 10000
+
+const pageSize = 100
+
+var (
+	// e.g http://jellyfin.local:8096
+	baseURL string
+	apiKey  string
+	// user owning the created playlists, the first user of the server when empty
+	userId   string
+	userOnce sync.Once
+	userErr  error
+)
+
+func init() {
+	initializers.LoadEnv()
+
+	baseURL = strings.TrimSuffix(os.Getenv("JELLYFIN_BASE_URL"), "/")
+	apiKey = os.Getenv("JELLYFIN_API_KEY")
+	userId = os.Getenv("JELLYFIN_USER_ID")
+}
+
+type Item struct {
+	Id          string   `json:"Id"`
+	Name        string   `json:"Name"`
+	Type        string   `json:"Type"`
+	Album       string   `json:"Album"`
+	Artists     []string `json:"Artists"`
+	AlbumArtist string   `json:"AlbumArtist"`
+	// 100 nanoseconds
+	RunTimeTicks int64 `json:"RunTimeTicks"`
+	// number of playlist items
+	ChildCount int `json:"ChildCount"`
+}
+
+type ItemsResponse struct {
+	Items            []*Item `json:"Items"`
+	TotalRecordCount int     `json:"TotalRecordCount"`
+	StartIndex       int     `json:"StartIndex"`
+}
+
+func authorize(agent *fiber.Agent) *fiber.Agent {
+	return agent.Set("Authorization", `MediaBrowser Client="music-playlist-converter", Token="`+apiKey+`"`)
+}
+
+// checks the server is configured and the api key is accepted
+func Ping() error {
+	if baseURL == "" || apiKey == "" {
+		return errors.New("JELLYFIN_BASE_URL and JELLYFIN_API_KEY are required")
+	}
+
+	cli := fiber.Client{}
+
+	status, _, errs := authorize(cli.Get(baseURL + "/System/Info")).Bytes()
+
+	if errs != nil {
+		return errs[0]
+	}
+
+	if status != http.StatusOK {
+		return errors.New("error reaching jellyfin | status code: " + fmt.Sprint(status))
+	}
+
+	return nil
+}
+
+// returns the configured user or the first user of the server, api keys aren't bound to a user
+func getUserId() (string, error) {
+	userOnce.Do(func() {
+		if userId != "" {
+			return
+		}
+
+		cli := fiber.Client{}
+
+		var users []struct {
+			Id string `json:"Id"`
+		}
+
+		status, _, errs := authorize(cli.Get(baseURL + "/Users")).Struct(&users)
+
+		if errs != nil {
+			userErr = errs[0]
+			return
+		}
+
+		if status != http.StatusOK || len(users) == 0 {
+			userErr = errors.New("error getting jellyfin users | status code: " + fmt.Sprint(status))
+			return
+		}
+
+		userId = users[0].Id
+	})
+
+	return userId, userErr
+}
+
+// returns the playlist, nil when it doesn't exist
+func FindPlaylist(id string) (*Item, error) {
+	cli := fiber.Client{}
+
+	user, err := getUserId()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := authorize(cli.Get(baseURL + "/Items?ids=" + url.QueryEscape(id) + "&userId=" + user + "&fields=ChildCount"))
+
+	var bodyData ItemsResponse
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.New("error verifying playlist | status code: " + fmt.Sprint(status))
+	}
+
+	for _, item := range bodyData.Items {
+		if item.Type == "Playlist" {
+			return item, nil
+		}
+	}
+
+	return nil, nil
+}
+
+/*
+fetches the playlist items page by page until all tracks or the allowed number of conversions are fetched
+
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string) (tracks []*Item, truncated bool, err error) {
+	cli := fiber.Client{}
+
+	user, err := getUserId()
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	tracks = []*Item{}
+
+	for startIndex := 0; ; startIndex += pageSize {
+		res := authorize(cli.Get(baseURL + "/Playlists/" + url.PathEscape(id) + "/Items?userId=" + user +
+			"&startIndex=" + fmt.Sprint(startIndex) + "&limit=" + fmt.Sprint(pageSize)))
+
+		var bodyData ItemsResponse
+		status, _, errs := res.Struct(&bodyData)
+
+		if errs != nil {
+			return nil, false, errs[0]
+		}
+
+		if status != http.StatusOK {
+			return nil, false, errors.New("error getting playlist tracks | status code: " + fmt.Sprint(status))
+		}
+
+		for _, item := range bodyData.Items {
+			// playlists can mix videos in
+			if item.Type == "Audio" {
+				tracks = append(tracks, item)
+			}
+		}
+
+		hasMore := len(bodyData.Items) > 0 && startIndex+len(bodyData.Items) < bodyData.TotalRecordCount
+
+		// allowedNumberOfConversions = 0 means convert all tracks
+		if allowedNumberOfConversions != 0 && len(tracks) >= allowedNumberOfConversions {
+			truncated = len(tracks) > allowedNumberOfConversions || hasMore
+			tracks = tracks[0:allowedNumberOfConversions]
+
+			break
+		}
+
+		if !hasMore {
+			break
+		}
+	}
+
+	return tracks, truncated, nil
+}
+
+func ToSearchTrackList(items []*Item) services.SearchTrackList {
+	searchTrackList := make(services.SearchTrackList, 0, len(items))
+
+	for _, item := range items {
+		searchTrackList = append(searchTrackList, toSearchTrack(item))
+	}
+
+	return searchTrackList
+}
+
+func toSearchTrack(item *Item) *services.SearchTrack {
+	artists := make(shared_types.Artists, 0, len(item.Artists))
+	for _, artist := range item.Artists {
+		artists = append(artists, shared_types.Artist{Name: artist})
+	}
+
+	if len(artists) == 0 && item.AlbumArtist != "" {
+		artists = append(artists, shared_types.Artist{Name: item.AlbumArtist})
+	}
+
+	return &services.SearchTrack{
+		Id:       item.Id,
+		Title:    item.Name,
+		Artists:  artists,
+		Duration: item.RunTimeTicks / ticksPerMillisecond,
+		Album:    shared_types.Album{Name: item.Album},
+	}
+}
+
+/*
+searches the audio items of the library
+
+jellyfin only matches the search term against item names so the artist isn't part of the query,
+the matcher compares the artists of the results
+*/
+func SearchTracks(query string, limit int) ([]*Item, error) {
+	cli := fiber.Client{}
+
+	user, err := getUserId()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := authorize(cli.Get(baseURL + "/Items?searchTerm=" + url.QueryEscape(query) + "&includeItemTypes=Audio&recursive=true" +
+		"&limit=" + fmt.Sprint(limit) + "&userId=" + user))
+
+	var bodyData ItemsResponse
+
+	status, _, errs := res.Struct(&bodyData)
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status == http.StatusOK {
+		return bodyData.Items, nil
+	}
+
+	return nil, errors.New("error searching track | status code: " + fmt.Sprint(status))
+}
+
+// creates an empty audio playlist owned by the jellyfin user and returns its id
+func CreatePlaylist(name string) (string, error) {
+	cli := fiber.Client{}
+
+	user, err := getUserId()
+
+	if err != nil {
+		return "", err
+	}
+
+	res := authorize(cli.Post(baseURL + "/Playlists")).
+		JSON(map[string]interface{}{
+			"Name":      name,
+			"Ids":       []string{},
+			"UserId":    user,
+			"MediaType": "Audio",
+		})
+
+	var bodyData struct {
+		Id string `json:"Id"`
+	}
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return "", errs[0]
+	}
+
+	if status == http.StatusOK && bodyData.Id != "" {
+		return bodyData.Id, nil
+	}
+
+	return "", errors.New("error creating playlist | status code: " + fmt.Sprint(status))
+}
+
+func AddTracksToPlaylist(playlistId string, itemIds []string) error {
+	cli := fiber.Client{}
+
+	user, err := getUserId()
+
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(itemIds); start += pageSize {
+		end := start + pageSize
+		if end > len(itemIds) {
+			end = len(itemIds)
+		}
+
+		res := authorize(cli.Post(baseURL + "/Playlists/" + url.PathEscape(playlistId) + "/Items?userId=" + user +
+			"&ids=" + strings.Join(itemIds[start:end], ",")))
+
+		status, b, errs := res.Bytes()
+
+		if errs != nil {
+			return errs[0]
+		}
+
+		if status != http.StatusNoContent && status != http.StatusOK {
+			log.Println("jellyfin add tracks : ", string(b))
+
+			return errors.New("error adding tracks to playlist | status code: " + fmt.Sprint(status))
+		}
+	}
+
+	return nil
+}
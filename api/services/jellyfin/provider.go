@@ -0,0 +1,112 @@
+package jellyfin
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "jellyfin"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "Jellyfin"
+}
+
+// the server configured with JELLYFIN_BASE_URL
+func (p *provider) Hosts() []string {
+	u, err := url.Parse(baseURL)
+
+	if err != nil || u.Host == "" {
+		return []string{}
+	}
+
+	return []string{u.Host}
+}
+
+/*
+expects web client urls in the format {base}/web/#/details?id={id},
+older clients use {base}/web/index.html#!/details?id={id}
+*/
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	route, rawQuery, found := strings.Cut(strings.TrimPrefix(u.Fragment, "!"), "?")
+
+	if !found || !strings.HasPrefix(strings.TrimSuffix(route, ".html"), "/details") {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+
+	if err != nil || query.Get("id") == "" {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	return query.Get("id"), nil
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := FindPlaylist(id)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         playlist.Id,
+		Title:      playlist.Name,
+		Url:        p.PlaylistURL(playlist.Id),
+		TrackCount: playlist.ChildCount,
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	items, truncated, err := GetPlaylistTracks(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(items), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	items, err := SearchTracks(track.Title, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSearchTrackList(items), nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	ids := make([]string, 0, len(tracks))
+
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+
+	return AddTracksToPlaylist(playlistId, ids)
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	return baseURL + "/web/#/details?id=" + url.QueryEscape(id)
+}
+
+func (p *provider) TrackURL(id string) string {
+	return baseURL + "/web/#/details?id=" + url.QueryEscape(id)
+}
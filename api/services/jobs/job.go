@@ -0,0 +1,485 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/converter"
+	"github.com/to-dy/music-playlist-converter/api/stores/jobstore"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusPaused    Status = "paused"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+var (
+	ErrJobFinished  = errors.New("conversion job already finished")
+	ErrJobPaused    = errors.New("conversion job already paused")
+	ErrJobNotPaused = errors.New("conversion job is not paused")
+)
+
+// conversion event as streamed to the client
+type Event struct {
+	// position of the event in the job event log starting at 1, sent as the SSE id
+	Id   int64
+	Name string
+	Data string
+}
+
+// what to convert, the providers are looked up by name when the job runs
+type Request struct {
+	SessionId string
+	Source    string
+	Target    string
+	// id of the playlist on the source provider
+	PlaylistId string
+	// title of the playlist created on the target provider
+	Title string
+}
+
+type Job struct {
+	Id      string
+	Request Request
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	gate   *converter.Gate
+
+	mu sync.Mutex
+	// conversion progress to resume from, set for jobs loaded from the store
+	state  *converter.State
+	status Status
+	// waiting in the manager queue
+	queued bool
+	// held by a worker, a paused job releases its worker and is queued again when resumed
+	running     bool
+	tracks      []*converter.TrackResult
+	truncated   bool
+	playlistId  string
+	err         string
+	createdAt   time.Time
+	updatedAt   time.Time
+	events      []Event
+	subscribers map[chan Event]struct{}
+	// called with every emitted event while j.mu is held, e.g to persist it
+	onEmit func(event Event)
+}
+
+// JSON representation of a job returned by the status api
+type Snapshot struct {
+	Id             string                   `json:"id"`
+	Status         Status                   `json:"status"`
+	Source         string                   `json:"source"`
+	Target         string                   `json:"target"`
+	Title          string                   `json:"title"`
+	PlaylistUrl    string                   `json:"playlistUrl,omitempty"`
+	TracksFound    int                      `json:"tracksFound"`
+	TracksNotFound int                      `json:"tracksNotFound"`
+	Truncated      bool                     `json:"truncated"`
+	Tracks         []*converter.TrackResult `json:"tracks"`
+	Error          string                   `json:"error,omitempty"`
+	CreatedAt      time.Time                `json:"createdAt"`
+	UpdatedAt      time.Time                `json:"updatedAt"`
+}
+
+// source track without a match on the target, e.g not part of a media server library
+type MissingTrack struct {
+	// position of the track in the source playlist
+	Index  int                   `json:"index"`
+	Track  *services.SearchTrack `json:"track"`
+	Status converter.TrackStatus `json:"status"`
+}
+
+func newJob(id string, req Request) *Job {
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Job{
+		Id:          id,
+		Request:     req,
+		ctx:         ctx,
+		cancel:      cancel,
+		gate:        converter.NewGate(),
+		status:      StatusQueued,
+		tracks:      []*converter.TrackResult{},
+		createdAt:   now,
+		updatedAt:   now,
+		subscribers: map[chan Event]struct{}{},
+	}
+}
+
+// rebuilds a job persisted by a previous server run
+func jobFromRecord(record *jobstore.Job) *Job {
+	job := newJob(record.Id, Request{
+		SessionId:  record.SessionId,
+		Source:     record.Source,
+		Target:     record.Target,
+		PlaylistId: record.PlaylistId,
+		Title:      record.Title,
+	})
+
+	job.status = Status(record.Status)
+	job.err = record.Error
+	job.createdAt = record.CreatedAt
+	job.updatedAt = record.UpdatedAt
+	job.state = record.State
+
+	for _, event := range record.Events {
+		job.events = append(job.events, Event{Id: event.Id, Name: event.Name, Data: event.Data})
+	}
+
+	if job.status == StatusPaused {
+		job.gate.Pause()
+	}
+
+	if job.isFinished() {
+		job.cancel()
+	}
+
+	if record.State != nil {
+		job.truncated = record.State.Truncated
+		job.playlistId = record.State.PlaylistId
+
+		for _, result := range record.State.Results {
+			track := *result
+			job.tracks = append(job.tracks, &track)
+		}
+	}
+
+	return job
+}
+
+func (j *Job) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.status
+}
+
+func (j *Job) Snapshot() *Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	snapshot := &Snapshot{
+		Id:        j.Id,
+		Status:    j.status,
+		Source:    j.Request.Source,
+		Target:    j.Request.Target,
+		Title:     j.Request.Title,
+		Tracks:    append([]*converter.TrackResult{}, j.tracks...),
+		Error:     j.err,
+		CreatedAt: j.createdAt,
+		UpdatedAt: j.updatedAt,
+	}
+
+	for _, track := range j.tracks {
+		if track == nil {
+			continue
+		}
+
+		if track.Status == converter.TrackFound {
+			snapshot.TracksFound++
+		} else {
+			snapshot.TracksNotFound++
+		}
+	}
+
+	snapshot.Truncated = j.truncated
+
+	if target, ok := services.GetProvider(j.Request.Target); ok && j.playlistId != "" {
+		snapshot.PlaylistUrl = target.PlaylistURL(j.playlistId)
+	}
+
+	return snapshot
+}
+
+// returns the searched tracks that weren't found on the target or failed to be searched, in source playlist order
+func (j *Job) MissingTracks() []*MissingTrack {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	missing := []*MissingTrack{}
+
+	for index, track := range j.tracks {
+		if track == nil || track.Status == converter.TrackFound {
+			continue
+		}
+
+		missing = append(missing, &MissingTrack{Index: index, Track: track.Source, Status: track.Status})
+	}
+
+	return missing
+}
+
+/*
+returns the events emitted after the event with id lastEventId and a channel receiving the following ones,
+a lastEventId of 0 returns all the events emitted so far
+
+the channel is closed once the job is finished or when the subscriber falls behind,
+call unsubscribe when the client goes away
+*/
+func (j *Job) Subscribe(lastEventId int64) (history []Event, events <-chan Event, unsubscribe func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	history = []Event{}
+	for _, event := range j.events {
+		if event.Id > lastEventId {
+			history = append(history, event)
+		}
+	}
+
+	ch := make(chan Event, 64)
+
+	if j.isFinished() {
+		close(ch)
+
+		return history, ch, func() {}
+	}
+
+	j.subscribers[ch] = struct{}{}
+
+	return history, ch, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		if _, ok := j.subscribers[ch]; ok {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (j *Job) emit(name string, data string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.emitLocked(name, data)
+}
+
+// caller must hold j.mu
+func (j *Job) emitLocked(name string, data string) {
+	event := Event{Id: 1, Name: name, Data: data}
+	if len(j.events) > 0 {
+		event.Id = j.events[len(j.events)-1].Id + 1
+	}
+
+	j.events = append(j.events, event)
+	j.updatedAt = time.Now()
+
+	if j.onEmit != nil {
+		j.onEmit(event)
+	}
+
+	for ch := range j.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// slow subscriber, drop it instead of blocking the conversion, the client reconnects with Last-Event-ID
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+/*
+called when a worker picks the job up, returns false if the job got cancelled or paused while queued,
+a paused job is queued again when resumed
+*/
+func (j *Job) start() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.queued = false
+
+	if j.isFinished() || j.gate.Paused() {
+		return false
+	}
+
+	j.running = true
+	j.status = StatusRunning
+	j.updatedAt = time.Now()
+
+	return true
+}
+
+/*
+releases the worker of a job the converter stopped on pause, returns true if the job got resumed
+in the meantime and has to be queued again
+*/
+func (j *Job) suspend() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.running = false
+
+	if j.gate.Paused() {
+		return false
+	}
+
+	j.queued = true
+	j.status = StatusQueued
+	j.updatedAt = time.Now()
+
+	return true
+}
+
+// copies the changed parts of the conversion state, the converter keeps updating its own
+func (j *Job) checkpoint(state *converter.State, changed []int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// a paused job is run again from it
+	j.state = state
+	j.truncated = state.Truncated
+	j.playlistId = state.PlaylistId
+
+	for _, index := range changed {
+		for len(j.tracks) <= index {
+			j.tracks = append(j.tracks, nil)
+		}
+
+		track := *state.Results[index]
+		j.tracks[index] = &track
+	}
+
+	j.updatedAt = time.Now()
+}
+
+/*
+stops the job before its next track, returns ErrJobFinished if it is already finished
+
+a job no worker holds, queued or paused, is finished right away
+*/
+func (j *Job) Cancel() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.isFinished() {
+		return ErrJobFinished
+	}
+
+	j.cancel()
+
+	if !j.running {
+		converter.Emit(j.emitLocked, &converter.DoneEvent{
+			Status:   converter.DoneCancelled,
+			Message:  "Conversion process cancelled",
+			Progress: &converter.Progress{},
+		})
+		j.finishLocked(context.Canceled)
+	}
+
+	return nil
+}
+
+// pauses the job before its next track, its worker is released once the converter stopped
+func (j *Job) Pause() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.isFinished() {
+		return ErrJobFinished
+	}
+
+	if !j.gate.Pause() {
+		return ErrJobPaused
+	}
+
+	j.status = StatusPaused
+	j.updatedAt = time.Now()
+
+	// a running job reports the pause with its progress once the converter stopped
+	if !j.running {
+		converter.Emit(j.emitLocked, &converter.PhaseEvent{Phase: converter.PhasePaused, Message: "Conversion paused"})
+	}
+
+	return nil
+}
+
+// returns true if the job has to be queued again, i.e no worker holds it and it isn't queued already
+func (j *Job) Resume() (requeue bool, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.isFinished() {
+		return false, ErrJobFinished
+	}
+
+	if !j.gate.Resume() {
+		return false, ErrJobNotPaused
+	}
+
+	// the worker hasn't released the job yet, the converter carries on
+	if j.running {
+		j.status = StatusRunning
+	} else {
+		requeue = !j.queued
+		j.queued = true
+		j.status = StatusQueued
+	}
+
+	j.updatedAt = time.Now()
+
+	converter.Emit(j.emitLocked, &converter.PhaseEvent{Phase: converter.PhaseResumed, Message: "Conversion resumed"})
+
+	return requeue, nil
+}
+
+// marks the job finished and closes the subscriber channels
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finishLocked(err)
+}
+
+// caller must hold j.mu
+func (j *Job) finishLocked(err error) {
+	j.status = StatusCompleted
+
+	if errors.Is(err, context.Canceled) {
+		j.status = StatusCancelled
+	} else if err != nil {
+		j.status = StatusFailed
+		j.err = err.Error()
+	}
+
+	j.cancel()
+
+	j.updatedAt = time.Now()
+
+	for ch := range j.subscribers {
+		delete(j.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (j *Job) IsFinished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.isFinished()
+}
+
+func (j *Job) finishedBefore(before time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.isFinished() && j.updatedAt.Before(before)
+}
+
+// caller must hold j.mu
+func (j *Job) isFinished() bool {
+	return j.status == StatusCompleted || j.status == StatusFailed || j.status == StatusCancelled
+}
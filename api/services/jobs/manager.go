@@ -0,0 +1,408 @@
+package jobs
+
+// runs playlist conversions in the background so they outlive the request that started them
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/converter"
+	"github.com/to-dy/music-playlist-converter/api/stores/jobstore"
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+)
+
+const (
+	defaultWorkers   = 2
+	defaultQueueSize = 100
+)
+
+var ErrQueueFull = errors.New("conversion queue is full")
+
+var GlobalManager *Manager
+var once sync.Once
+
+type Manager struct {
+	workers int
+	queue   chan *Job
+	jobs    map[string]*Job
+	mutex   sync.RWMutex
+	started sync.Once
+	// nil keeps jobs in memory only
+	store *jobstore.Store
+}
+
+func init() {
+	once.Do(func() {
+		GlobalManager = NewManager(workersFromEnv(), defaultQueueSize)
+	})
+}
+
+// number of conversions run at the same time, set with CONVERSION_WORKERS
+func workersFromEnv() int {
+	value := os.Getenv("CONVERSION_WORKERS")
+
+	if value == "" {
+		return defaultWorkers
+	}
+
+	workers, err := strconv.Atoi(value)
+
+	if err != nil || workers < 1 {
+		log.Println("invalid CONVERSION_WORKERS - " + value)
+		return defaultWorkers
+	}
+
+	return workers
+}
+
+func NewManager(workers int, queueSize int) *Manager {
+	return &Manager{
+		workers: workers,
+		queue:   make(chan *Job, queueSize),
+		jobs:    map[string]*Job{},
+	}
+}
+
+// persists jobs to the store, must be called before Start
+func (m *Manager) UseStore(store *jobstore.Store) {
+	m.store = store
+}
+
+// starts the worker pool and resumes the unfinished jobs of the store, calling it more than once has no effect
+func (m *Manager) Start() {
+	m.started.Do(func() {
+		for i := 0; i < m.workers; i++ {
+			go m.work()
+		}
+
+		m.resume()
+	})
+}
+
+func (m *Manager) resume() {
+	if m.store == nil {
+		return
+	}
+
+	records, err := m.store.JobsWithStatus(string(StatusQueued), string(StatusRunning), string(StatusPaused))
+
+	if err != nil {
+		log.Println("error loading unfinished jobs - " + err.Error())
+		return
+	}
+
+	for _, record := range records {
+		job := jobFromRecord(record)
+		job.onEmit = m.persistEvent(job)
+
+		m.mutex.Lock()
+		m.jobs[job.Id] = job
+		m.mutex.Unlock()
+
+		// paused jobs are queued once resumed
+		if job.status == StatusPaused {
+			continue
+		}
+
+		job.status = StatusQueued
+		job.queued = true
+
+		log.Println("resuming conversion job " + job.Id)
+
+		m.requeue(job)
+	}
+}
+
+// the queue may be smaller than the number of jobs to queue again, the job waits for room without blocking the caller
+func (m *Manager) requeue(job *Job) {
+	go func() {
+		m.queue <- job
+	}()
+}
+
+// queues a conversion and returns its job
+func (m *Manager) Enqueue(req Request) (*Job, error) {
+	job := newJob(utils.UUIDv4(), req)
+	job.onEmit = m.persistEvent(job)
+	job.queued = true
+
+	if m.store != nil {
+		err := m.store.CreateJob(&jobstore.Job{
+			Id:         job.Id,
+			SessionId:  req.SessionId,
+			Source:     req.Source,
+			Target:     req.Target,
+			PlaylistId: req.PlaylistId,
+			Title:      req.Title,
+			Status:     string(job.status),
+			CreatedAt:  job.createdAt,
+			UpdatedAt:  job.updatedAt,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	select {
+	case m.queue <- job:
+	default:
+		m.updateStatus(job, StatusFailed, ErrQueueFull)
+
+		return nil, ErrQueueFull
+	}
+
+	m.jobs[job.Id] = job
+
+	// the job needs the tokens of the session to resume after a restart
+	tokenstore.GlobalTokenStore.PersistSession(req.SessionId)
+
+	return job, nil
+}
+
+// returns the job, jobs finished before the last restart are loaded from the store
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mutex.RLock()
+	job, ok := m.jobs[id]
+	m.mutex.RUnlock()
+
+	if ok || m.store == nil {
+		return job, ok
+	}
+
+	record, err := m.store.GetJob(id)
+
+	if err != nil {
+		if !errors.Is(err, jobstore.ErrJobNotFound) {
+			log.Println("error loading job " + id + " - " + err.Error())
+		}
+
+		return nil, false
+	}
+
+	job = jobFromRecord(record)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// loaded concurrently by another request
+	if existing, ok := m.jobs[id]; ok {
+		return existing, true
+	}
+
+	m.jobs[id] = job
+
+	return job, true
+}
+
+// stops the job before its next track, the tracks added so far stay on the target playlist
+func (m *Manager) Cancel(job *Job) error {
+	if err := job.Cancel(); err != nil {
+		return err
+	}
+
+	// a running job persists its status once the converter stopped
+	if job.IsFinished() {
+		m.updateStatus(job, StatusCancelled, nil)
+		m.releaseSession(job.Request.SessionId)
+	}
+
+	return nil
+}
+
+// pauses the job before its next track, the worker is released for the other jobs
+func (m *Manager) Pause(job *Job) error {
+	if err := job.Pause(); err != nil {
+		return err
+	}
+
+	m.updateStatus(job, job.Status(), nil)
+
+	return nil
+}
+
+// a paused job released its worker, it is queued again and resumes from its last checkpoint
+func (m *Manager) Resume(job *Job) error {
+	requeue, err := job.Resume()
+
+	if err != nil {
+		return err
+	}
+
+	m.updateStatus(job, job.Status(), nil)
+
+	if requeue {
+		m.requeue(job)
+	}
+
+	return nil
+}
+
+// deletes the persisted tokens of the session once none of its jobs is unfinished
+func (m *Manager) releaseSession(sessionId string) {
+	// held while releasing so a job enqueued meanwhile persists the tokens again
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, job := range m.jobs {
+		if job.Request.SessionId == sessionId && !job.IsFinished() {
+			return
+		}
+	}
+
+	tokenstore.GlobalTokenStore.ReleaseSession(sessionId)
+}
+
+// true while an unfinished job converts the playlist of the source, e.g so its upload is kept
+func (m *Manager) IsConverting(source string, playlistId string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, job := range m.jobs {
+		if job.Request.Source == source && job.Request.PlaylistId == playlistId && !job.IsFinished() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deletes the jobs finished before the time with their events, unfinished jobs are kept however old
+func (m *Manager) Sweep(before time.Time) {
+	m.mutex.Lock()
+
+	for id, job := range m.jobs {
+		if job.finishedBefore(before) {
+			delete(m.jobs, id)
+		}
+	}
+
+	m.mutex.Unlock()
+
+	if m.store == nil {
+		return
+	}
+
+	err := m.store.DeleteJobsUpdatedBefore(before, string(StatusCompleted), string(StatusFailed), string(StatusCancelled))
+
+	if err != nil {
+		log.Println("error deleting finished jobs - " + err.Error())
+	}
+}
+
+func (m *Manager) updateStatus(job *Job, status Status, err error) {
+	if m.store == nil {
+		return
+	}
+
+	errMessage := ""
+	if err != nil {
+		errMessage = err.Error()
+	}
+
+	if storeErr := m.store.UpdateStatus(job.Id, string(status), errMessage); storeErr != nil {
+		log.Println("error persisting job " + job.Id + " status - " + storeErr.Error())
+	}
+}
+
+// keeps the event log of the job so clients can catch up after a restart
+func (m *Manager) persistEvent(job *Job) func(event Event) {
+	return func(event Event) {
+		if m.store == nil {
+			return
+		}
+
+		err := m.store.AppendEvent(job.Id, &jobstore.Event{Id: event.Id, Name: event.Name, Data: event.Data})
+
+		if err != nil {
+			log.Println("error persisting job " + job.Id + " event - " + err.Error())
+		}
+	}
+}
+
+func (m *Manager) checkpoint(job *Job) func(state *converter.State, changed []int) {
+	return func(state *converter.State, changed []int) {
+		job.checkpoint(state, changed)
+
+		if m.store == nil {
+			return
+		}
+
+		if err := m.store.SaveState(job.Id, state, changed); err != nil {
+			log.Println("error persisting job " + job.Id + " progress - " + err.Error())
+		}
+	}
+}
+
+func (m *Manager) work() {
+	for job := range m.queue {
+		m.run(job)
+	}
+}
+
+func (m *Manager) run(job *Job) {
+	if !job.start() {
+		return
+	}
+
+	m.updateStatus(job, job.Status(), nil)
+
+	var err error
+
+	// provider clients panic on some errors, don't let one job take the worker down
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("conversion job "+job.Id+" panicked - ", r)
+
+			converter.Emit(job.emit, &converter.ErrorEvent{Message: "unexpected error during conversion", Fatal: true})
+			err = fmt.Errorf("conversion panicked: %v", r)
+		}
+
+		// the progress is checkpointed, the job is run again from it once resumed
+		if errors.Is(err, converter.ErrPaused) {
+			if job.suspend() {
+				m.updateStatus(job, job.Status(), nil)
+				m.requeue(job)
+			}
+
+			return
+		}
+
+		job.finish(err)
+
+		m.updateStatus(job, job.Status(), err)
+		m.releaseSession(job.Request.SessionId)
+	}()
+
+	source, sourceOk := services.GetProvider(job.Request.Source)
+	target, targetOk := services.GetProvider(job.Request.Target)
+
+	if !sourceOk || !targetOk {
+		err = errors.New("playlist conversion from " + job.Request.Source + " to " + job.Request.Target + " not supported")
+		converter.Emit(job.emit, &converter.ErrorEvent{Message: err.Error(), Fatal: true})
+
+		return
+	}
+
+	_, err = converter.Run(job.ctx, &converter.Conversion{
+		Source:     source,
+		Target:     target,
+		PlaylistId: job.Request.PlaylistId,
+		Title:      job.Request.Title,
+		SessionId:  job.Request.SessionId,
+		State:      job.state,
+		Gate:       job.gate,
+		Checkpoint: m.checkpoint(job),
+	}, job.emit)
+}
@@ -0,0 +1,224 @@
+package jobs
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+// provider searching one track at a time, each search waits for a step so the test controls the pace
+type steppedProvider struct {
+	name  string
+	steps chan struct{}
+
+	mu    sync.Mutex
+	added int
+}
+
+func (p *steppedProvider) Name() string        { return p.name }
+func (p *steppedProvider) DisplayName() string { return p.name }
+func (p *steppedProvider) Hosts() []string     { return []string{} }
+
+func (p *steppedProvider) ResolvePlaylistURL(u *url.URL) (string, error) { return "", nil }
+
+func (p *steppedProvider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	return nil, nil
+}
+
+func (p *steppedProvider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	tracks := services.SearchTrackList{}
+
+	for i := 0; i < 3; i++ {
+		tracks = append(tracks, &services.SearchTrack{Id: strconv.Itoa(i), Title: "track " + strconv.Itoa(i)})
+	}
+
+	return tracks, false, nil
+}
+
+func (p *steppedProvider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	if p.steps != nil {
+		<-p.steps
+	}
+
+	return services.SearchTrackList{track}, nil
+}
+
+func (p *steppedProvider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return "created", nil
+}
+
+func (p *steppedProvider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.added += len(tracks)
+
+	return nil
+}
+
+func (p *steppedProvider) PlaylistURL(id string) string { return id }
+
+var (
+	fastProvider = &steppedProvider{name: "test-fast"}
+	slowProvider = &steppedProvider{name: "test-slow", steps: make(chan struct{})}
+)
+
+func init() {
+	services.RegisterProvider(fastProvider)
+	services.RegisterProvider(slowProvider)
+}
+
+func waitForStatus(t *testing.T, job *Job, status Status) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for job.Status() != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, expected %s", job.Id, job.Status(), status)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPausedJobReleasesItsWorker(t *testing.T) {
+	slow, fast := slowProvider, fastProvider
+	slow.added = 0
+
+	manager := NewManager(1, 10)
+	manager.Start()
+
+	paused, err := manager.Enqueue(Request{Source: fast.name, Target: slow.name, PlaylistId: "1", Title: "paused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForStatus(t, paused, StatusRunning)
+
+	if err := manager.Pause(paused); err != nil {
+		t.Fatal(err)
+	}
+
+	// the search in progress finishes, the converter stops before the next one
+	slow.steps <- struct{}{}
+
+	other, err := manager.Enqueue(Request{Source: fast.name, Target: fast.name, PlaylistId: "2", Title: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForStatus(t, other, StatusCompleted)
+
+	if status := paused.Status(); status != StatusPaused {
+		t.Fatalf("paused job is %s", status)
+	}
+
+	if err := manager.Resume(paused); err != nil {
+		t.Fatal(err)
+	}
+
+	slow.steps <- struct{}{}
+	slow.steps <- struct{}{}
+
+	waitForStatus(t, paused, StatusCompleted)
+
+	snapshot := paused.Snapshot()
+
+	if snapshot.TracksFound != 3 {
+		t.Errorf("found %d tracks, expected 3", snapshot.TracksFound)
+	}
+
+	if slow.added != 3 {
+		t.Errorf("added %d tracks, expected each of the 3 tracks once", slow.added)
+	}
+}
+
+func TestJobPausedWhileQueuedRunsOnceResumed(t *testing.T) {
+	blocking, fast := slowProvider, fastProvider
+
+	manager := NewManager(1, 10)
+	manager.Start()
+
+	first, err := manager.Enqueue(Request{Source: fast.name, Target: blocking.name, PlaylistId: "1", Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForStatus(t, first, StatusRunning)
+
+	queued, err := manager.Enqueue(Request{Source: fast.name, Target: fast.name, PlaylistId: "2", Title: "queued"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manager.Pause(queued); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		blocking.steps <- struct{}{}
+	}
+
+	waitForStatus(t, first, StatusCompleted)
+
+	// the worker skipped the paused job
+	time.Sleep(20 * time.Millisecond)
+
+	if status := queued.Status(); status != StatusPaused {
+		t.Fatalf("queued job is %s, expected it to stay paused", status)
+	}
+
+	if err := manager.Resume(queued); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForStatus(t, queued, StatusCompleted)
+}
+
+func TestSweepKeepsUnfinishedJobs(t *testing.T) {
+	manager := NewManager(1, 10)
+	manager.Start()
+
+	finished, err := manager.Enqueue(Request{Source: fastProvider.name, Target: fastProvider.name, PlaylistId: "1", Title: "finished"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForStatus(t, finished, StatusCompleted)
+
+	paused, err := manager.Enqueue(Request{Source: fastProvider.name, Target: slowProvider.name, PlaylistId: "2", Title: "paused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForStatus(t, paused, StatusRunning)
+
+	if err := manager.Pause(paused); err != nil {
+		t.Fatal(err)
+	}
+
+	slowProvider.steps <- struct{}{}
+
+	manager.Sweep(time.Now().Add(time.Hour))
+
+	if _, found := manager.Get(finished.Id); found {
+		t.Error("finished job not swept")
+	}
+
+	if _, found := manager.Get(paused.Id); !found {
+		t.Fatal("paused job swept")
+	}
+
+	if !manager.IsConverting(fastProvider.name, "2") {
+		t.Error("paused job no longer converting its playlist")
+	}
+
+	if err := manager.Cancel(paused); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+var ErrInvalidAllowedNumberOfConversions = errors.New("ALLOWED_NUMBER_OF_CONVERSIONS must be 0 or more")
+
+// returns the maximum number of tracks converted per playlist, 0 means all tracks are converted
+func AllowedNumberOfConversions() (int, error) {
+	value := os.Getenv("ALLOWED_NUMBER_OF_CONVERSIONS")
+
+	if value == "" {
+		return 0, nil
+	}
+
+	allowed, err := strconv.Atoi(value)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if allowed < 0 {
+		return 0, ErrInvalidAllowedNumberOfConversions
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,393 @@
+package local
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const id3HeaderSize = 10
+
+var errInvalidID3 = errors.New("invalid id3v2 tag")
+
+/*
+reads the ID3v2.2, 2.3 or 2.4 tag of an mp3 file, the ID3v1 tag is read when there is no ID3v2 tag
+
+the duration comes from the TLEN frame or else from the first mpeg audio frame
+*/
+func readID3(f *os.File, size int64) (*Tags, error) {
+	tags := &Tags{}
+	audioStart := int64(0)
+
+	header := make([]byte, id3HeaderSize)
+
+	if _, err := f.ReadAt(header, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		version := header[3]
+		flags := header[5]
+		tagSize := int64(syncsafe(header[6:10]))
+
+		audioStart = id3HeaderSize + tagSize
+		// v2.4 footer
+		if flags&0x10 != 0 {
+			audioStart += id3HeaderSize
+		}
+
+		if tagSize > size {
+			return nil, errInvalidID3
+		}
+
+		body := make([]byte, tagSize)
+
+		if _, err := f.ReadAt(body, id3HeaderSize); err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if err := readID3Frames(tags, body, version, flags); err != nil {
+			return nil, err
+		}
+	} else if err := readID3v1(f, size, tags); err != nil {
+		return nil, err
+	}
+
+	if tags.Duration == 0 {
+		tags.Duration = mpegDuration(f, audioStart, size)
+	}
+
+	return tags, nil
+}
+
+func readID3Frames(tags *Tags, body []byte, version byte, flags byte) error {
+	// v2.3 unsynchronisation applies to the whole tag
+	if version < 4 && flags&0x80 != 0 {
+		body = removeUnsync(body)
+	}
+
+	// skip the extended header
+	if flags&0x40 != 0 && version >= 3 {
+		if len(body) < 4 {
+			return errInvalidID3
+		}
+
+		extendedSize := int(binary.BigEndian.Uint32(body[0:4]))
+		if version == 4 {
+			extendedSize = syncsafe(body[0:4])
+		} else {
+			// the v2.3 size doesn't count the size bytes
+			extendedSize += 4
+		}
+
+		if extendedSize > len(body) {
+			return errInvalidID3
+		}
+
+		body = body[extendedSize:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	for len(body) >= headerSize && body[0] != 0 {
+		id := string(body[0:idSize])
+
+		var frameSize int
+		var formatFlags byte
+
+		switch version {
+		case 2:
+			frameSize = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+			formatFlags = body[9]
+		default:
+			frameSize = syncsafe(body[4:8])
+			formatFlags = body[9]
+		}
+
+		if frameSize < 0 || headerSize+frameSize > len(body) {
+			break
+		}
+
+		data := body[headerSize : headerSize+frameSize]
+		body = body[headerSize+frameSize:]
+
+		if version == 3 && formatFlags&0xC0 != 0 {
+			// compressed or encrypted
+			continue
+		}
+
+		if version == 4 {
+			if formatFlags&0x0C != 0 {
+				continue
+			}
+
+			// data length indicator
+			if formatFlags&0x01 != 0 {
+				if len(data) < 4 {
+					continue
+				}
+
+				data = data[4:]
+			}
+
+			if formatFlags&0x02 != 0 {
+				data = removeUnsync(data)
+			}
+		}
+
+		setID3Frame(tags, id, data)
+	}
+
+	return nil
+}
+
+func setID3Frame(tags *Tags, id string, data []byte) {
+	switch id {
+	case "TIT2", "TT2":
+		tags.setField("TITLE", firstValue(decodeID3Text(data)))
+
+	case "TPE1", "TP1":
+		for _, artist := range decodeID3Text(data) {
+			tags.setField("ARTIST", artist)
+		}
+
+	case "TALB", "TAL":
+		tags.setField("ALBUM", firstValue(decodeID3Text(data)))
+
+	case "TSRC", "TRC":
+		tags.setField("ISRC", firstValue(decodeID3Text(data)))
+
+	case "TLEN", "TLE":
+		if ms, err := strconv.ParseInt(strings.TrimSpace(firstValue(decodeID3Text(data))), 10, 64); err == nil && ms > 0 {
+			tags.Duration = ms
+		}
+	}
+}
+
+// decodes a text frame, v2.4 frames can hold several null separated values
+func decodeID3Text(data []byte) []string {
+	if len(data) < 1 {
+		return nil
+	}
+
+	encoding, text := data[0], data[1:]
+
+	var decoded string
+
+	switch encoding {
+	case 1:
+		decoded = decodeUTF16(text, nil)
+	case 2:
+		decoded = decodeUTF16(text, binary.BigEndian)
+	case 3:
+		decoded = string(text)
+	default:
+		decoded = decodeLatin1(text)
+	}
+
+	values := []string{}
+
+	for _, value := range strings.Split(decoded, "\x00") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// decodes utf-16 text, the byte order comes from the byte order marks when order is nil
+func decodeUTF16(b []byte, order binary.ByteOrder) string {
+	units := make([]uint16, 0, len(b)/2)
+	byteOrder := order
+
+	for i := 0; i+1 < len(b); i += 2 {
+		if order == nil {
+			if b[i] == 0xFF && b[i+1] == 0xFE {
+				byteOrder = binary.LittleEndian
+				continue
+			}
+
+			if b[i] == 0xFE && b[i+1] == 0xFF {
+				byteOrder = binary.BigEndian
+				continue
+			}
+		}
+
+		if byteOrder == nil {
+			byteOrder = binary.LittleEndian
+		}
+
+		units = append(units, byteOrder.Uint16(b[i:i+2]))
+	}
+
+	return string(utf16.Decode(units))
+}
+
+func decodeLatin1(b []byte) string {
+	runes := make([]rune, len(b))
+
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+
+	return string(runes)
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// reverts the unsynchronisation scheme, 0xFF 0x00 is written for every 0xFF
+func removeUnsync(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// the ID3v1 tag is the last 128 bytes of the file
+func readID3v1(f *os.File, size int64, tags *Tags) error {
+	if size < 128 {
+		return nil
+	}
+
+	b := make([]byte, 128)
+
+	if _, err := f.ReadAt(b, size-128); err != nil && err != io.EOF {
+		return err
+	}
+
+	if !bytes.HasPrefix(b, []byte("TAG")) {
+		return nil
+	}
+
+	field := func(b []byte) string {
+		return strings.TrimSpace(decodeLatin1(bytes.TrimRight(b, "\x00 ")))
+	}
+
+	tags.setField("TITLE", field(b[3:33]))
+	tags.setField("ARTIST", field(b[33:63]))
+	tags.setField("ALBUM", field(b[63:93]))
+
+	return nil
+}
+
+var (
+	// kbps by [mpeg1][layer] and bitrate index
+	mpeg1Bitrates = [3][15]int{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	}
+	mpeg2Bitrates = [3][15]int{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	sampleRates = map[byte][3]int{
+		3: {44100, 48000, 32000},
+		2: {22050, 24000, 16000},
+		0: {11025, 12000, 8000},
+	}
+)
+
+/*
+estimates the duration in milliseconds from the first mpeg audio frame,
+the frame count of a Xing/Info or VBRI header is used for vbr files, the bitrate otherwise
+*/
+func mpegDuration(f *os.File, audioStart int64, size int64) int64 {
+	// the frame sync is searched in the first bytes after the tag
+	b := make([]byte, 64*1024)
+	n, _ := f.ReadAt(b, audioStart)
+	b = b[:n]
+
+	for i := 0; i+4 <= len(b); i++ {
+		if b[i] != 0xFF || b[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+
+		version := (b[i+1] >> 3) & 0x03
+		layer := (b[i+1] >> 1) & 0x03
+		bitrateIndex := b[i+2] >> 4
+		sampleRateIndex := (b[i+2] >> 2) & 0x03
+
+		if version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+			continue
+		}
+
+		// layer I = 0, II = 1, III = 2
+		layerIndex := 3 - int(layer)
+		sampleRate := sampleRates[version][sampleRateIndex]
+
+		bitrate := mpeg1Bitrates[layerIndex][bitrateIndex]
+		if version != 3 {
+			bitrate = mpeg2Bitrates[layerIndex][bitrateIndex]
+		}
+
+		samplesPerFrame := 1152
+		if layerIndex == 0 {
+			samplesPerFrame = 384
+		} else if layerIndex == 2 && version != 3 {
+			samplesPerFrame = 576
+		}
+
+		mono := b[i+3]>>6 == 3
+		frame := b[i:]
+
+		if frames := vbrFrameCount(frame, version == 3, mono); frames > 0 {
+			return frames * int64(samplesPerFrame) * 1000 / int64(sampleRate)
+		}
+
+		audioBytes := size - audioStart - int64(i)
+
+		return audioBytes * 8 / int64(bitrate)
+	}
+
+	return 0
+}
+
+// returns the frame count of the Xing/Info or VBRI header of the first frame, 0 when there is none
+func vbrFrameCount(frame []byte, mpeg1 bool, mono bool) int64 {
+	xingOffset := 4 + 32
+	switch {
+	case mpeg1 && mono:
+		xingOffset = 4 + 17
+	case !mpeg1 && !mono:
+		xingOffset = 4 + 17
+	case !mpeg1 && mono:
+		xingOffset = 4 + 9
+	}
+
+	if len(frame) >= xingOffset+12 {
+		id := string(frame[xingOffset : xingOffset+4])
+		flags := binary.BigEndian.Uint32(frame[xingOffset+4 : xingOffset+8])
+
+		if (id == "Xing" || id == "Info") && flags&0x01 != 0 {
+			return int64(binary.BigEndian.Uint32(frame[xingOffset+8 : xingOffset+12]))
+		}
+	}
+
+	const vbriOffset = 4 + 32
+
+	if len(frame) >= vbriOffset+18 && string(frame[vbriOffset:vbriOffset+4]) == "VBRI" {
+		return int64(binary.BigEndian.Uint32(frame[vbriOffset+14 : vbriOffset+18]))
+	}
+
+	return 0
+}
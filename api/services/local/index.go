@@ -0,0 +1,324 @@
+package local
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/formats"
+	"github.com/to-dy/music-playlist-converter/api/services/match"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+)
+
+// audio file of the music folder with its tags
+type Track struct {
+	// slash separated path relative to the music folder, used as the track id
+	Path    string   `json:"path"`
+	Title   string   `json:"title"`
+	Artists []string `json:"artists,omitempty"`
+	Album   string   `json:"album,omitempty"`
+	// milliseconds
+	Duration int64  `json:"duration,omitempty"`
+	ISRC     string `json:"isrc,omitempty"`
+	// unchanged files are not read again when rescanning
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+type ScanResult struct {
+	Tracks int `json:"tracks"`
+	// files whose tags were read, new or changed since the last scan
+	Read int `json:"read"`
+	// files indexed from their file name because their tags couldn't be read
+	Failed int `json:"failed"`
+}
+
+// index of the tracks in the music folder, persisted to skip reading unchanged files after a restart
+type Index struct {
+	dir  string
+	path string
+
+	mu     sync.RWMutex
+	tracks map[string]*Track
+
+	// serializes scans
+	scanMu sync.Mutex
+	loaded bool
+}
+
+func NewIndex(dir string, path string) *Index {
+	return &Index{dir: dir, path: path, tracks: map[string]*Track{}}
+}
+
+/*
+walks the music folder and reads the tags of new and changed files,
+deleted files are dropped from the index
+*/
+func (idx *Index) Scan() (*ScanResult, error) {
+	idx.scanMu.Lock()
+	defer idx.scanMu.Unlock()
+
+	if err := idx.load(); err != nil {
+		log.Println("error loading local music index - " + err.Error())
+	}
+
+	return idx.scan()
+}
+
+// scans the music folder once, later changes are picked up with Scan
+func (idx *Index) ensureScanned() error {
+	idx.scanMu.Lock()
+	defer idx.scanMu.Unlock()
+
+	if idx.loaded {
+		return nil
+	}
+
+	if err := idx.load(); err != nil {
+		log.Println("error loading local music index - " + err.Error())
+	}
+
+	_, err := idx.scan()
+
+	return err
+}
+
+func (idx *Index) scan() (*ScanResult, error) {
+	idx.mu.RLock()
+	previous := idx.tracks
+	idx.mu.RUnlock()
+
+	tracks := map[string]*Track{}
+	result := &ScanResult{}
+
+	err := filepath.WalkDir(idx.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Println("error scanning " + path + " - " + err.Error())
+
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if entry.IsDir() || !isAudioFile(path) {
+			return nil
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(idx.dir, path)
+
+		if err != nil {
+			return nil
+		}
+
+		relativePath = filepath.ToSlash(relativePath)
+
+		if track, found := previous[relativePath]; found && track.Size == info.Size() && track.ModTime.Equal(info.ModTime()) {
+			tracks[relativePath] = track
+			return nil
+		}
+
+		tags, err := ReadTags(path)
+		result.Read++
+
+		if err != nil {
+			log.Println("error reading tags of " + path + " - " + err.Error())
+			result.Failed++
+			tags = &Tags{}
+		}
+
+		tracks[relativePath] = newTrack(relativePath, tags, info)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	idx.tracks = tracks
+	idx.loaded = true
+	idx.mu.Unlock()
+
+	result.Tracks = len(tracks)
+
+	if err := idx.save(tracks); err != nil {
+		log.Println("error saving local music index - " + err.Error())
+	}
+
+	return result, nil
+}
+
+// untagged files are named from their file name, "Artist - Title.mp3" names give both
+func newTrack(relativePath string, tags *Tags, info fs.FileInfo) *Track {
+	track := &Track{
+		Path:     relativePath,
+		Title:    tags.Title,
+		Artists:  tags.Artists,
+		Album:    tags.Album,
+		Duration: tags.Duration,
+		ISRC:     tags.ISRC,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	}
+
+	if track.Title == "" {
+		artist, title := formats.FileNameTags(relativePath)
+		track.Title = title
+
+		if len(track.Artists) == 0 && artist != "" {
+			track.Artists = []string{artist}
+		}
+	}
+
+	return track
+}
+
+// loads the persisted index, a missing index file is an empty index
+func (idx *Index) load() error {
+	if idx.path == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(idx.path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tracks := map[string]*Track{}
+
+	if err := json.Unmarshal(b, &tracks); err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	idx.tracks = tracks
+	idx.mu.Unlock()
+
+	return nil
+}
+
+func (idx *Index) save(tracks map[string]*Track) error {
+	if idx.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0o755); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(tracks)
+
+	if err != nil {
+		return err
+	}
+
+	// written next to the index and renamed so a crash doesn't leave a truncated index
+	tmp := idx.path + ".tmp"
+
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, idx.path)
+}
+
+// returns the indexed track at the slash separated path relative to the music folder
+func (idx *Index) Lookup(relativePath string) (*Track, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	track, found := idx.tracks[relativePath]
+
+	return track, found
+}
+
+// returns at most limit indexed tracks, best match first, scored like conversion candidates
+func (idx *Index) Search(source *services.SearchTrack, limit int) services.SearchTrackList {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	type scored struct {
+		track *services.SearchTrack
+		score float64
+	}
+
+	results := []scored{}
+
+	for _, track := range idx.tracks {
+		candidate := track.toSearchTrack()
+
+		if score := match.Score(source, candidate); score > 0 {
+			results = append(results, scored{track: candidate, score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score == results[j].score {
+			return results[i].track.Id < results[j].track.Id
+		}
+
+		return results[i].score > results[j].score
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	list := make(services.SearchTrackList, 0, len(results))
+
+	for _, result := range results {
+		list = append(list, result.track)
+	}
+
+	return list
+}
+
+func (idx *Index) FindISRC(isrc string) (*Track, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	for _, track := range idx.tracks {
+		if track.ISRC != "" && strings.EqualFold(track.ISRC, isrc) {
+			return track, true
+		}
+	}
+
+	return nil, false
+}
+
+func (t *Track) toSearchTrack() *services.SearchTrack {
+	artists := make(shared_types.Artists, 0, len(t.Artists))
+	for _, artist := range t.Artists {
+		artists = append(artists, shared_types.Artist{Name: artist})
+	}
+
+	return &services.SearchTrack{
+		Id:       t.Path,
+		Title:    t.Title,
+		Artists:  artists,
+		Duration: t.Duration,
+		Album:    shared_types.Album{Name: t.Album},
+		ISRC:     t.ISRC,
+	}
+}
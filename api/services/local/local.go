@@ -0,0 +1,383 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/formats"
+	"github.com/to-dy/music-playlist-converter/api/services/match"
+	"github.com/to-dy/music-playlist-converter/initializers"
+)
+
+const defaultIndexPath = "data/local_index.json"
+
+const maxSameNamePlaylists = 100
+
+var (
+	// root of the music folder, playlists and tracks outside of it are not read
+	musicDir string
+	// folder inside musicDir the converted playlists are written to
+	playlistDir string
+
+	GlobalIndex *Index
+)
+
+var ErrMusicDirNotSet = errors.New("LOCAL_MUSIC_DIR is not set")
+
+func init() {
+	initializers.LoadEnv()
+
+	indexPath := os.Getenv("LOCAL_MUSIC_INDEX_PATH")
+	if indexPath == "" {
+		indexPath = defaultIndexPath
+	}
+
+	UseMusicDir(os.Getenv("LOCAL_MUSIC_DIR"), indexPath)
+}
+
+// sets the music folder and the file its index is persisted to, an empty dir disables the folder
+func UseMusicDir(dir string, indexPath string) {
+	musicDir = ""
+
+	if dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			musicDir = abs
+		} else {
+			log.Println("invalid LOCAL_MUSIC_DIR - " + err.Error())
+		}
+	}
+
+	playlistDir = filepath.Join(musicDir, "Playlists")
+	if dir := os.Getenv("LOCAL_MUSIC_PLAYLIST_DIR"); dir != "" {
+		playlistDir = filepath.Join(musicDir, dir)
+	}
+
+	GlobalIndex = NewIndex(musicDir, indexPath)
+}
+
+// checks the music folder is configured and readable
+func Ping() error {
+	if musicDir == "" {
+		return ErrMusicDirNotSet
+	}
+
+	info, err := os.Stat(musicDir)
+
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return errors.New(musicDir + " is not a directory")
+	}
+
+	return nil
+}
+
+// rescans the music folder, new and changed files are read again
+func Scan() (*ScanResult, error) {
+	if err := Ping(); err != nil {
+		return nil, err
+	}
+
+	return GlobalIndex.Scan()
+}
+
+/*
+returns the slash separated path of an absolute path relative to the music folder,
+found is false for paths outside of it
+*/
+func relativeToMusicDir(path string) (relativePath string, found bool) {
+	if musicDir == "" {
+		return "", false
+	}
+
+	relativePath, err := filepath.Rel(musicDir, filepath.Clean(path))
+
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(relativePath), true
+}
+
+func absolutePath(relativePath string) string {
+	return filepath.Join(musicDir, filepath.FromSlash(relativePath))
+}
+
+func isPlaylistFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	return ext == ".m3u8" || ext == ".m3u"
+}
+
+// reads a playlist of the music folder, nil when it doesn't exist
+func ReadPlaylist(id string) (*formats.Playlist, error) {
+	f, err := os.Open(absolutePath(id))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	playlist, err := formats.DecodeM3U(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if playlist.Title == "" {
+		playlist.Title = strings.TrimSuffix(filepath.Base(id), filepath.Ext(id))
+	}
+
+	return playlist, nil
+}
+
+/*
+returns the tracks of a local playlist, entries are looked up in the index and read from the file
+when they aren't indexed, the #EXTINF line is used for files that don't exist and for urls
+
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string) (tracks services.SearchTrackList, truncated bool, err error) {
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	playlist, err := ReadPlaylist(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if playlist == nil {
+		return nil, false, errors.New("local playlist " + id + " not found")
+	}
+
+	if err := GlobalIndex.ensureScanned(); err != nil {
+		log.Println("error scanning local music - " + err.Error())
+	}
+
+	playlistFolder := filepath.Dir(absolutePath(id))
+	tracks = services.SearchTrackList{}
+
+	for _, entry := range playlist.Entries {
+		track := entryTrack(entry, playlistFolder)
+
+		if track.Title == "" {
+			continue
+		}
+
+		tracks = append(tracks, track)
+	}
+
+	// allowedNumberOfConversions = 0 means convert all tracks
+	if allowedNumberOfConversions != 0 && len(tracks) > allowedNumberOfConversions {
+		tracks = tracks[0:allowedNumberOfConversions]
+		truncated = true
+	}
+
+	return tracks, truncated, nil
+}
+
+func entryTrack(entry *formats.Entry, playlistFolder string) *services.SearchTrack {
+	fromEntry := &Track{
+		Path:     entry.Location,
+		Title:    entry.Title,
+		Album:    entry.Album,
+		Duration: entry.Duration,
+		ISRC:     entry.ISRC,
+	}
+
+	if entry.Artist != "" {
+		fromEntry.Artists = []string{entry.Artist}
+	}
+
+	// single letter schemes are windows drive letters
+	if u, err := url.Parse(entry.Location); err == nil && len(u.Scheme) > 1 && u.Scheme != "file" {
+		// streams and urls of other services
+		return fromEntry.toSearchTrack()
+	}
+
+	path := entry.Location
+	if u, err := url.Parse(path); err == nil && u.Scheme == "file" {
+		path = u.Path
+	}
+
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(playlistFolder, path)
+	}
+
+	relativePath, inMusicDir := relativeToMusicDir(path)
+
+	if inMusicDir {
+		if track, found := GlobalIndex.Lookup(relativePath); found {
+			return track.toSearchTrack()
+		}
+
+		// the playlist can be newer than the last scan
+		if info, err := os.Stat(path); err == nil && isAudioFile(path) {
+			if tags, err := ReadTags(path); err == nil {
+				return newTrack(relativePath, tags, info).toSearchTrack()
+			}
+		}
+
+		fromEntry.Path = relativePath
+	}
+
+	if fromEntry.Title == "" {
+		artist, title := formats.FileNameTags(path)
+		fromEntry.Title = title
+
+		if len(fromEntry.Artists) == 0 && artist != "" {
+			fromEntry.Artists = []string{artist}
+		}
+	}
+
+	return fromEntry.toSearchTrack()
+}
+
+// searches the indexed tracks, the index is built on the first search
+func SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	if err := Ping(); err != nil {
+		return nil, err
+	}
+
+	if err := GlobalIndex.ensureScanned(); err != nil {
+		return nil, err
+	}
+
+	return GlobalIndex.Search(track, limit), nil
+}
+
+func SearchISRC(isrc string) (*services.SearchTrack, bool, error) {
+	if err := Ping(); err != nil {
+		return nil, false, err
+	}
+
+	if err := GlobalIndex.ensureScanned(); err != nil {
+		return nil, false, err
+	}
+
+	track, found := GlobalIndex.FindISRC(isrc)
+
+	if !found {
+		return nil, false, nil
+	}
+
+	return track.toSearchTrack(), true, nil
+}
+
+/*
+finds the file of a track of another provider in the music folder, matched like conversion candidates,
+returns the absolute path of the file
+*/
+func ResolveFile(track *services.SearchTrack) (path string, found bool, err error) {
+	if err := Ping(); err != nil {
+		return "", false, err
+	}
+
+	if err := GlobalIndex.ensureScanned(); err != nil {
+		return "", false, err
+	}
+
+	provider, _ := services.GetProvider(ProviderName)
+	best, found, err := match.FindBest(provider, track, match.DefaultConfig())
+
+	if err != nil || !found {
+		return "", false, err
+	}
+
+	return absolutePath(best.Track.Id), true, nil
+}
+
+// creates an empty m3u8 playlist in the playlist folder and returns its id, the path relative to the music folder
+func CreatePlaylist(name string) (string, error) {
+	if err := Ping(); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(playlistDir, 0o755); err != nil {
+		return "", err
+	}
+
+	fileName := formats.SafeFileName(name)
+
+	path := filepath.Join(playlistDir, fileName+".m3u8")
+
+	// existing playlists with the same name are kept, "name (2).m3u8" is created instead
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+
+	for n := 2; os.IsExist(err) && n <= maxSameNamePlaylists; n++ {
+		path = filepath.Join(playlistDir, fileName+" ("+fmt.Sprint(n)+").m3u8")
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := formats.EncodeM3U(f, &formats.Playlist{Title: name}); err != nil {
+		f.Close()
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	id, _ := relativeToMusicDir(path)
+
+	return id, nil
+}
+
+// appends the tracks to the playlist, entries are relative to the playlist file so the folder can be moved
+func AddTracksToPlaylist(id string, tracks services.SearchTrackList) error {
+	path := absolutePath(id)
+	playlistFolder := filepath.Dir(path)
+
+	entries := make([]*formats.Entry, 0, len(tracks))
+
+	for _, track := range tracks {
+		location, err := filepath.Rel(playlistFolder, absolutePath(track.Id))
+
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, &formats.Entry{
+			Location: filepath.ToSlash(location),
+			Title:    track.Title,
+			Artist:   track.MainArtist(),
+			Duration: track.Duration,
+		})
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+
+	if err != nil {
+		return err
+	}
+
+	if err := formats.AppendM3U(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
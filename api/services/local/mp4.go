@@ -0,0 +1,210 @@
+package local
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// the moov atom holds the sample tables too, larger ones are not read
+const maxMoovSize = 64 * 1024 * 1024
+
+var errInvalidMP4 = errors.New("invalid mp4 file")
+
+type atom struct {
+	kind string
+	data []byte
+}
+
+// splits the content of an atom into its child atoms
+func childAtoms(b []byte) []atom {
+	atoms := []atom{}
+
+	for len(b) >= 8 {
+		size := int64(binary.BigEndian.Uint32(b[0:4]))
+		kind := string(b[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			size = int64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return atoms
+			}
+
+			size = int64(binary.BigEndian.Uint64(b[8:16]))
+			headerSize = 16
+		}
+
+		if size < headerSize || size > int64(len(b)) {
+			return atoms
+		}
+
+		atoms = append(atoms, atom{kind: kind, data: b[headerSize:size]})
+		b = b[size:]
+	}
+
+	return atoms
+}
+
+func findAtom(atoms []atom, kind string) (atom, bool) {
+	for _, a := range atoms {
+		if a.kind == kind {
+			return a, true
+		}
+	}
+
+	return atom{}, false
+}
+
+/*
+reads the iTunes style metadata of an mp4 audio file from moov.udta.meta.ilst,
+the duration comes from the movie header moov.mvhd
+*/
+func readMP4(f *os.File, size int64) (*Tags, error) {
+	moov, err := readMoov(f, size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tags := &Tags{}
+	children := childAtoms(moov)
+
+	if mvhd, found := findAtom(children, "mvhd"); found {
+		tags.Duration = movieDuration(mvhd.data)
+	}
+
+	udta, found := findAtom(children, "udta")
+
+	if !found {
+		return tags, nil
+	}
+
+	meta, found := findAtom(childAtoms(udta.data), "meta")
+
+	// meta is a full atom, its children start after the version and flags
+	if !found || len(meta.data) < 4 {
+		return tags, nil
+	}
+
+	ilst, found := findAtom(childAtoms(meta.data[4:]), "ilst")
+
+	if !found {
+		return tags, nil
+	}
+
+	for _, item := range childAtoms(ilst.data) {
+		switch item.kind {
+		case "\xa9nam":
+			tags.setField("TITLE", mp4Text(item.data))
+
+		case "\xa9ART":
+			tags.setField("ARTIST", mp4Text(item.data))
+
+		case "\xa9alb":
+			tags.setField("ALBUM", mp4Text(item.data))
+
+		case "----":
+			// freeform atoms hold fields like com.apple.iTunes:ISRC
+			fields := childAtoms(item.data)
+			name, found := findAtom(fields, "name")
+
+			if found && len(name.data) > 4 {
+				tags.setField(string(name.data[4:]), mp4Text(item.data))
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+// finds the top level moov atom, it can be before or after the media data
+func readMoov(f *os.File, size int64) ([]byte, error) {
+	offset := int64(0)
+	header := make([]byte, 16)
+
+	for offset+8 <= size {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return nil, errInvalidMP4
+		}
+
+		atomSize := int64(binary.BigEndian.Uint32(header[0:4]))
+		kind := string(header[4:8])
+		headerSize := int64(8)
+
+		switch atomSize {
+		case 0:
+			atomSize = size - offset
+		case 1:
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, errInvalidMP4
+			}
+
+			atomSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if atomSize < headerSize || offset+atomSize > size {
+			return nil, errInvalidMP4
+		}
+
+		if kind == "moov" {
+			if atomSize > maxMoovSize {
+				return nil, errInvalidMP4
+			}
+
+			moov := make([]byte, atomSize-headerSize)
+
+			if _, err := f.ReadAt(moov, offset+headerSize); err != nil && err != io.EOF {
+				return nil, err
+			}
+
+			return moov, nil
+		}
+
+		offset += atomSize
+	}
+
+	return nil, errInvalidMP4
+}
+
+// returns the text of the data atom of a metadata item, data atoms start with a type and a locale
+func mp4Text(item []byte) string {
+	data, found := findAtom(childAtoms(item), "data")
+
+	if !found || len(data.data) < 8 {
+		return ""
+	}
+
+	return string(data.data[8:])
+}
+
+// milliseconds, version 1 movie headers use 64 bit times
+func movieDuration(mvhd []byte) int64 {
+	if len(mvhd) < 20 {
+		return 0
+	}
+
+	var timescale, duration int64
+
+	if mvhd[0] == 1 {
+		if len(mvhd) < 32 {
+			return 0
+		}
+
+		timescale = int64(binary.BigEndian.Uint32(mvhd[20:24]))
+		duration = int64(binary.BigEndian.Uint64(mvhd[24:32]))
+	} else {
+		timescale = int64(binary.BigEndian.Uint32(mvhd[12:16]))
+		duration = int64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+
+	if timescale == 0 {
+		return 0
+	}
+
+	return duration * 1000 / timescale
+}
@@ -0,0 +1,101 @@
+package local
+
+import (
+	"net/url"
+	"path/filepath"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "local"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "Local music"
+}
+
+// playlists are file urls, found by their scheme
+func (p *provider) Hosts() []string {
+	return []string{}
+}
+
+func (p *provider) Schemes() []string {
+	return []string{"file"}
+}
+
+// expects file urls of m3u8 or m3u playlists inside the music folder e.g file:///music/Playlists/mix.m3u8
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	if u.Scheme != "file" || !isPlaylistFile(u.Path) {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	id, inMusicDir := relativeToMusicDir(filepath.FromSlash(u.Path))
+
+	if !inMusicDir {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	return id, nil
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := ReadPlaylist(id)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         id,
+		Title:      playlist.Title,
+		Url:        p.PlaylistURL(id),
+		TrackCount: len(playlist.Entries),
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	return GetPlaylistTracks(id)
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	return SearchTracks(track, limit)
+}
+
+func (p *provider) SearchISRC(isrc string) (*services.SearchTrack, bool, error) {
+	return SearchISRC(isrc)
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	return AddTracksToPlaylist(playlistId, tracks)
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(absolutePath(id))}).String()
+}
+
+// tracks outside of the music folder keep the location of their playlist entry
+func (p *provider) TrackURL(id string) string {
+	if u, err := url.Parse(id); err == nil && len(u.Scheme) > 1 {
+		return id
+	}
+
+	path := filepath.FromSlash(id)
+	if !filepath.IsAbs(path) {
+		path = absolutePath(id)
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,91 @@
+package local
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errUnsupportedFile = errors.New("unsupported audio file")
+
+// tags read from an audio file, fields the file doesn't have are left empty
+type Tags struct {
+	Title   string
+	Artists []string
+	Album   string
+	// milliseconds
+	Duration int64
+	ISRC     string
+}
+
+// audio file extensions scanned in the music folder and the tag format they carry
+var tagReaders = map[string]func(f *os.File, size int64) (*Tags, error){
+	".mp3":  readID3,
+	".flac": readFLAC,
+	".ogg":  readOgg,
+	".oga":  readOgg,
+	".opus": readOgg,
+	".m4a":  readMP4,
+	".mp4":  readMP4,
+}
+
+func isAudioFile(path string) bool {
+	_, supported := tagReaders[strings.ToLower(filepath.Ext(path))]
+
+	return supported
+}
+
+// reads the tags of an mp3 (ID3v2), flac or ogg (Vorbis comments) or m4a (MP4 metadata) file
+func ReadTags(path string) (*Tags, error) {
+	reader, supported := tagReaders[strings.ToLower(filepath.Ext(path))]
+
+	if !supported {
+		return nil, errUnsupportedFile
+	}
+
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reader(f, info.Size())
+}
+
+// sets a vorbis comment style field, the names are the same in vorbis comments and mp4 freeform atoms
+func (t *Tags) setField(name string, value string) {
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return
+	}
+
+	switch strings.ToUpper(name) {
+	case "TITLE":
+		if t.Title == "" {
+			t.Title = value
+		}
+
+	case "ARTIST":
+		t.Artists = append(t.Artists, value)
+
+	case "ALBUM":
+		if t.Album == "" {
+			t.Album = value
+		}
+
+	case "ISRC":
+		if t.ISRC == "" {
+			t.ISRC = strings.ToUpper(value)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package local
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// comment packets holding embedded cover art can be large, bigger packets are not read
+const maxCommentSize = 16 * 1024 * 1024
+
+var (
+	errInvalidFLAC = errors.New("invalid flac file")
+	errInvalidOgg  = errors.New("invalid ogg file")
+)
+
+// reads the STREAMINFO and VORBIS_COMMENT metadata blocks of a flac file
+func readFLAC(f *os.File, size int64) (*Tags, error) {
+	tags := &Tags{}
+	offset := int64(0)
+
+	header := make([]byte, id3HeaderSize)
+
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+
+	// some taggers put an id3v2 tag in front of the stream
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		offset = id3HeaderSize + int64(syncsafe(header[6:10]))
+	}
+
+	marker := make([]byte, 4)
+
+	if _, err := f.ReadAt(marker, offset); err != nil || string(marker) != "fLaC" {
+		return nil, errInvalidFLAC
+	}
+
+	offset += 4
+
+	for {
+		blockHeader := make([]byte, 4)
+
+		if _, err := f.ReadAt(blockHeader, offset); err != nil {
+			return nil, errInvalidFLAC
+		}
+
+		last := blockHeader[0]&0x80 != 0
+		blockType := blockHeader[0] & 0x7F
+		blockSize := int64(blockHeader[1])<<16 | int64(blockHeader[2])<<8 | int64(blockHeader[3])
+		offset += 4
+
+		if offset+blockSize > size {
+			return nil, errInvalidFLAC
+		}
+
+		switch blockType {
+		case 0:
+			block := make([]byte, blockSize)
+
+			if _, err := f.ReadAt(block, offset); err != nil || len(block) < 18 {
+				return nil, errInvalidFLAC
+			}
+
+			sampleRate := int64(block[10])<<12 | int64(block[11])<<4 | int64(block[12])>>4
+			totalSamples := int64(block[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(block[14:18]))
+
+			if sampleRate > 0 {
+				tags.Duration = totalSamples * 1000 / sampleRate
+			}
+
+		case 4:
+			if blockSize > maxCommentSize {
+				break
+			}
+
+			block := make([]byte, blockSize)
+
+			if _, err := f.ReadAt(block, offset); err != nil {
+				return nil, errInvalidFLAC
+			}
+
+			readVorbisComment(tags, block)
+		}
+
+		offset += blockSize
+
+		if last {
+			break
+		}
+	}
+
+	return tags, nil
+}
+
+// parses a vorbis comment: vendor string, comment count then "NAME=value" comments, all little endian
+func readVorbisComment(tags *Tags, b []byte) {
+	r := bytes.NewReader(b)
+
+	var vendorLength uint32
+	if binary.Read(r, binary.LittleEndian, &vendorLength) != nil || int64(vendorLength) > int64(r.Len()) {
+		return
+	}
+
+	r.Seek(int64(vendorLength), io.SeekCurrent)
+
+	var count uint32
+	if binary.Read(r, binary.LittleEndian, &count) != nil {
+		return
+	}
+
+	for i := uint32(0); i < count; i++ {
+		var length uint32
+		if binary.Read(r, binary.LittleEndian, &length) != nil || int64(length) > int64(r.Len()) {
+			return
+		}
+
+		comment := make([]byte, length)
+		r.Read(comment)
+
+		if name, value, found := strings.Cut(string(comment), "="); found {
+			tags.setField(name, value)
+		}
+	}
+}
+
+type oggPage struct {
+	granule  int64
+	segments []byte
+	data     []byte
+}
+
+func readOggPage(r io.Reader) (*oggPage, error) {
+	header := make([]byte, 27)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if string(header[0:4]) != "OggS" {
+		return nil, errInvalidOgg
+	}
+
+	page := &oggPage{
+		granule:  int64(binary.LittleEndian.Uint64(header[6:14])),
+		segments: make([]byte, header[26]),
+	}
+
+	if _, err := io.ReadFull(r, page.segments); err != nil {
+		return nil, err
+	}
+
+	dataSize := 0
+	for _, segment := range page.segments {
+		dataSize += int(segment)
+	}
+
+	page.data = make([]byte, dataSize)
+
+	if _, err := io.ReadFull(r, page.data); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+/*
+reads the comment header of an ogg vorbis or opus file
+
+the duration is the granule position of the last page divided by the sample rate,
+opus granules always count 48kHz samples and start after the pre-skip
+*/
+func readOgg(f *os.File, size int64) (*Tags, error) {
+	tags := &Tags{}
+	r := io.NewSectionReader(f, 0, size)
+
+	var sampleRate, preSkip int64
+	packets := [][]byte{}
+	packet := []byte{}
+
+	// the identification and comment headers are the first two packets
+	for len(packets) < 2 {
+		page, err := readOggPage(r)
+
+		if err != nil {
+			return nil, errInvalidOgg
+		}
+
+		offset := 0
+		for _, segment := range page.segments {
+			packet = append(packet, page.data[offset:offset+int(segment)]...)
+			offset += int(segment)
+
+			if len(packet) > maxCommentSize {
+				return nil, errInvalidOgg
+			}
+
+			// a segment shorter than 255 bytes ends the packet
+			if segment < 255 {
+				packets = append(packets, packet)
+				packet = []byte{}
+			}
+		}
+	}
+
+	identification, comment := packets[0], packets[1]
+
+	switch {
+	case bytes.HasPrefix(identification, []byte("\x01vorbis")) && len(identification) >= 16:
+		sampleRate = int64(binary.LittleEndian.Uint32(identification[12:16]))
+
+		if bytes.HasPrefix(comment, []byte("\x03vorbis")) {
+			readVorbisComment(tags, comment[7:])
+		}
+
+	case bytes.HasPrefix(identification, []byte("OpusHead")) && len(identification) >= 12:
+		sampleRate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(identification[10:12]))
+
+		if bytes.HasPrefix(comment, []byte("OpusTags")) {
+			readVorbisComment(tags, comment[8:])
+		}
+
+	default:
+		return nil, errInvalidOgg
+	}
+
+	if granule := lastOggGranule(f, size); granule > preSkip && sampleRate > 0 {
+		tags.Duration = (granule - preSkip) * 1000 / sampleRate
+	}
+
+	return tags, nil
+}
+
+// returns the granule position of the last page, found in the last bytes of the file
+func lastOggGranule(f *os.File, size int64) int64 {
+	tailSize := int64(64 * 1024)
+	if tailSize > size {
+		tailSize = size
+	}
+
+	tail := make([]byte, tailSize)
+
+	if _, err := f.ReadAt(tail, size-tailSize); err != nil && err != io.EOF {
+		return 0
+	}
+
+	index := bytes.LastIndex(tail, []byte("OggS"))
+
+	if index < 0 || index+14 > len(tail) {
+		return 0
+	}
+
+	return int64(binary.LittleEndian.Uint64(tail[index+6 : index+14]))
+}
@@ -0,0 +1,239 @@
+package match
+
+// picks the best candidate for a track out of several provider search results
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const (
+	defaultCandidates = 5
+	defaultThreshold  = 0.6
+)
+
+type Config struct {
+	// number of search results requested from the provider per track
+	Candidates int
+	// minimum confidence (0 - 1) a candidate needs to be accepted
+	Threshold float64
+}
+
+// how a match was found
+type Strategy string
+
+const (
+	StrategyISRC  Strategy = "isrc"
+	StrategyFuzzy Strategy = "fuzzy"
+)
+
+type Match struct {
+	Track      *services.SearchTrack
+	Confidence float64
+	Strategy   Strategy
+}
+
+/*
+returns the config set with MATCH_CANDIDATES and MATCH_THRESHOLD
+
+invalid or missing values fall back to the defaults
+*/
+func DefaultConfig() *Config {
+	config := &Config{
+		Candidates: defaultCandidates,
+		Threshold:  defaultThreshold,
+	}
+
+	if value := os.Getenv("MATCH_CANDIDATES"); value != "" {
+		candidates, err := strconv.Atoi(value)
+
+		if err != nil || candidates < 1 {
+			log.Println("invalid MATCH_CANDIDATES - " + value)
+		} else {
+			config.Candidates = candidates
+		}
+	}
+
+	if value := os.Getenv("MATCH_THRESHOLD"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+
+		if err != nil || threshold < 0 || threshold > 1 {
+			log.Println("invalid MATCH_THRESHOLD - " + value)
+		} else {
+			config.Threshold = threshold
+		}
+	}
+
+	return config
+}
+
+/*
+finds the track on the provider
+
+when the track has an ISRC and the provider supports ISRC lookups the ISRC match is used,
+otherwise (or when the lookup fails) the best scored search candidate is returned.
+found is false when there are no candidates or the best candidate is below the threshold,
+the best rejected candidate is still returned so its confidence can be reported
+*/
+func FindBest(provider services.Provider, track *services.SearchTrack, config *Config) (best *Match, found bool, err error) {
+	if searcher, ok := provider.(services.ISRCSearcher); ok && track.ISRC != "" {
+		entry, isrcFound, isrcErr := searcher.SearchISRC(track.ISRC)
+
+		if isrcErr != nil {
+			log.Println("isrc search error, falling back to fuzzy search", isrcErr)
+		}
+
+		if isrcFound {
+			return &Match{Track: entry, Confidence: 1, Strategy: StrategyISRC}, true, nil
+		}
+	}
+
+	candidates, err := provider.SearchTracks(track, config.Candidates)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	best = Best(track, candidates)
+
+	if best == nil {
+		return nil, false, nil
+	}
+
+	return best, best.Confidence >= config.Threshold, nil
+}
+
+// returns the highest scored candidate, nil if there are no candidates
+func Best(source *services.SearchTrack, candidates services.SearchTrackList) *Match {
+	matches := make([]*Match, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		matches = append(matches, &Match{
+			Track:      candidate,
+			Confidence: Score(source, candidate),
+			Strategy:   StrategyFuzzy,
+		})
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+
+	// stable so the provider ranking breaks ties
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Confidence > matches[j].Confidence
+	})
+
+	return matches[0]
+}
+
+const (
+	titleWeight    = 0.45
+	artistWeight   = 0.30
+	albumWeight    = 0.10
+	durationWeight = 0.15
+
+	// durations within this delta are considered equal
+	durationToleranceMs = 3000
+	// durations further apart than this score 0
+	durationMaxDeltaMs = 30000
+
+	// applied for every version keyword (live, karaoke...) the candidate has but the source doesn't
+	versionPenalty = 0.6
+)
+
+/*
+scores how likely candidate is the same recording as source, from 0 to 1
+
+album and duration only count when both tracks have them
+*/
+func Score(source *services.SearchTrack, candidate *services.SearchTrack) float64 {
+	total := titleWeight * titleScore(source.Title, candidate.Title)
+	weights := titleWeight
+
+	if len(source.Artists) > 0 && len(candidate.Artists) > 0 {
+		total += artistWeight * artistScore(source, candidate)
+		weights += artistWeight
+	}
+
+	if source.Album.Name != "" && candidate.Album.Name != "" {
+		total += albumWeight * albumScore(source.Album.Name, candidate.Album.Name)
+		weights += albumWeight
+	}
+
+	if source.Duration > 0 && candidate.Duration > 0 {
+		total += durationWeight * durationScore(source.Duration, candidate.Duration)
+		weights += durationWeight
+	}
+
+	score := total / weights
+
+	for range extraVersions(source.Title, candidate.Title) {
+		score *= versionPenalty
+	}
+
+	return math.Round(score*1000) / 1000
+}
+
+func titleScore(source string, candidate string) float64 {
+	a := normalizeTitle(source)
+	b := normalizeTitle(candidate)
+
+	if a == b {
+		return 1
+	}
+
+	return dice(tokens(a), tokens(b))
+}
+
+// fraction of the source artists found in the candidate artists
+func artistScore(source *services.SearchTrack, candidate *services.SearchTrack) float64 {
+	candidateTokens := []string{}
+	for _, artist := range candidate.Artists {
+		candidateTokens = append(candidateTokens, tokens(normalize(artist.Name))...)
+	}
+
+	matched := 0.0
+
+	for _, artist := range source.Artists {
+		artistTokens := tokens(normalize(artist.Name))
+
+		if len(artistTokens) == 0 {
+			continue
+		}
+
+		matched += coverage(artistTokens, candidateTokens)
+	}
+
+	return matched / float64(len(source.Artists))
+}
+
+func albumScore(source string, candidate string) float64 {
+	a := normalizeTitle(source)
+	b := normalizeTitle(candidate)
+
+	if a == b {
+		return 1
+	}
+
+	return dice(tokens(a), tokens(b))
+}
+
+func durationScore(source int64, candidate int64) float64 {
+	delta := math.Abs(float64(source - candidate))
+
+	if delta <= durationToleranceMs {
+		return 1
+	}
+
+	if delta >= durationMaxDeltaMs {
+		return 0
+	}
+
+	return 1 - (delta-durationToleranceMs)/(durationMaxDeltaMs-durationToleranceMs)
+}
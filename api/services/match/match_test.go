@@ -0,0 +1,245 @@
+package match
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+)
+
+func track(title string, artists ...string) *services.SearchTrack {
+	t := &services.SearchTrack{Title: title}
+
+	for _, artist := range artists {
+		t.Artists = append(t.Artists, shared_types.Artist{Name: artist})
+	}
+
+	return t
+}
+
+func withAlbum(t *services.SearchTrack, album string) *services.SearchTrack {
+	t.Album = shared_types.Album{Name: album}
+	return t
+}
+
+func withDuration(t *services.SearchTrack, duration int64) *services.SearchTrack {
+	t.Duration = duration
+	return t
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    *services.SearchTrack
+		candidate *services.SearchTrack
+		want      float64
+	}{
+		{
+			name:      "same recording",
+			source:    withDuration(withAlbum(track("Hey Jude", "The Beatles"), "Hey Jude"), 431000),
+			candidate: withDuration(withAlbum(track("Hey Jude", "The Beatles"), "Hey Jude"), 431000),
+			want:      1,
+		},
+		{
+			name:      "featured artists and remaster notes are ignored",
+			source:    track("Song (feat. Someone) - Remastered 2011", "Artist"),
+			candidate: track("Song [2009 Remaster]", "Artist"),
+			want:      1,
+		},
+		{
+			name:      "accents, case and punctuation are ignored",
+			source:    track("Déjà Vu!", "Beyoncé"),
+			candidate: track("deja vu", "BEYONCE"),
+			want:      1,
+		},
+		{
+			name:      "live version of a studio track is penalized",
+			source:    track("Song", "Artist"),
+			candidate: track("Song - Live", "Artist"),
+			want:      0.48,
+		},
+		{
+			name:      "every extra version keyword is penalized",
+			source:    track("Song"),
+			candidate: track("Song (Karaoke Instrumental)"),
+			want:      0.18,
+		},
+		{
+			name:      "version keywords of the source aren't penalized",
+			source:    track("Song (Live)", "Artist"),
+			candidate: track("Song (Live)", "Artist"),
+			want:      1,
+		},
+		{
+			name:      "missing source artists lower the score",
+			source:    track("Song", "First", "Second"),
+			candidate: track("Song", "First"),
+			want:      0.8,
+		},
+		{
+			name:      "album is only scored when both tracks have one",
+			source:    withAlbum(track("Song", "Artist"), "Album"),
+			candidate: track("Song", "Artist"),
+			want:      1,
+		},
+		{
+			name:      "duration delta beyond the tolerance",
+			source:    withDuration(track("Song"), 200000),
+			candidate: withDuration(track("Song"), 216500),
+			want:      0.875,
+		},
+		{
+			name:      "unrelated titles",
+			source:    track("Yesterday", "The Beatles"),
+			candidate: track("Bohemian Rhapsody", "Queen"),
+			want:      0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Score(tt.source, tt.candidate); got != tt.want {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationScore(t *testing.T) {
+	tests := []struct {
+		source    int64
+		candidate int64
+		want      float64
+	}{
+		{200000, 200000, 1},
+		{200000, 203000, 1},
+		{203000, 200000, 1},
+		{200000, 216500, 0.5},
+		{200000, 230000, 0},
+		{200000, 260000, 0},
+	}
+
+	for _, tt := range tests {
+		if got := durationScore(tt.source, tt.candidate); got != tt.want {
+			t.Errorf("durationScore(%d, %d) = %v, want %v", tt.source, tt.candidate, got, tt.want)
+		}
+	}
+}
+
+func TestExtraVersions(t *testing.T) {
+	tests := []struct {
+		source    string
+		candidate string
+		want      int
+	}{
+		{"Song", "Song", 0},
+		{"Song", "Song (Live at Wembley)", 1},
+		{"Song - Live", "Song (Live)", 0},
+		{"Song", "Song (Slowed + Reverb)", 2},
+		{"Song", "Song (A Cappella)", 1},
+		// keywords only count as whole words
+		{"Olive", "Olive", 0},
+		{"Song", "Delivery", 0},
+	}
+
+	for _, tt := range tests {
+		if got := extraVersions(tt.source, tt.candidate); len(got) != tt.want {
+			t.Errorf("extraVersions(%q, %q) = %v, want %d keywords", tt.source, tt.candidate, got, tt.want)
+		}
+	}
+}
+
+func TestBestKeepsProviderRankingOnTies(t *testing.T) {
+	source := track("Song", "Artist")
+	first := track("Song", "Artist")
+	first.Id = "first"
+	second := track("Song", "Artist")
+	second.Id = "second"
+	live := track("Song (Live)", "Artist")
+
+	best := Best(source, services.SearchTrackList{live, first, second})
+
+	if best == nil || best.Track.Id != "first" || best.Strategy != StrategyFuzzy {
+		t.Fatalf("Best() = %+v, want the first exact candidate", best)
+	}
+
+	if Best(source, services.SearchTrackList{}) != nil {
+		t.Error("Best() of no candidates isn't nil")
+	}
+}
+
+// returns its candidates for any search, and the isrc track for its isrc
+type fakeProvider struct {
+	candidates services.SearchTrackList
+	isrcTrack  *services.SearchTrack
+}
+
+func (p *fakeProvider) Name() string                                  { return "fake" }
+func (p *fakeProvider) DisplayName() string                           { return "Fake" }
+func (p *fakeProvider) Hosts() []string                               { return []string{} }
+func (p *fakeProvider) ResolvePlaylistURL(u *url.URL) (string, error) { return "", nil }
+func (p *fakeProvider) PlaylistURL(id string) string                  { return id }
+
+func (p *fakeProvider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	return nil, false, nil
+}
+
+func (p *fakeProvider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	return p.candidates, nil
+}
+
+func (p *fakeProvider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return "", nil
+}
+
+func (p *fakeProvider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	return nil
+}
+
+func (p *fakeProvider) SearchISRC(isrc string) (*services.SearchTrack, bool, error) {
+	if p.isrcTrack != nil && p.isrcTrack.ISRC == isrc {
+		return p.isrcTrack, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func TestFindBest(t *testing.T) {
+	config := &Config{Candidates: 5, Threshold: 0.6}
+
+	isrcTrack := track("Different Title", "Artist")
+	isrcTrack.ISRC = "GBAYE0601498"
+
+	source := track("Song", "Artist")
+	source.ISRC = "GBAYE0601498"
+
+	provider := &fakeProvider{candidates: services.SearchTrackList{track("Song", "Artist")}, isrcTrack: isrcTrack}
+
+	best, found, err := FindBest(provider, source, config)
+
+	if err != nil || !found || best.Strategy != StrategyISRC || best.Track != isrcTrack || best.Confidence != 1 {
+		t.Fatalf("FindBest() = %+v, %v, %v, want the isrc match", best, found, err)
+	}
+
+	source.ISRC = "USUM71703861"
+
+	best, found, err = FindBest(provider, source, config)
+
+	if err != nil || !found || best.Strategy != StrategyFuzzy {
+		t.Fatalf("FindBest() = %+v, %v, %v, want the fuzzy match when the isrc isn't found", best, found, err)
+	}
+
+	// the rejected candidate is returned so its confidence can be reported
+	provider.candidates = services.SearchTrackList{track("Song (Karaoke Version)", "Artist")}
+
+	best, found, err = FindBest(provider, track("Song", "Artist"), config)
+
+	if err != nil || found || best == nil || best.Confidence >= config.Threshold {
+		t.Fatalf("FindBest() = %+v, %v, %v, want a rejected candidate below the threshold", best, found, err)
+	}
+}
@@ -0,0 +1,132 @@
+package match
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// (feat. artist), [with artist]
+	featuringPattern = regexp.MustCompile(`[(\[]\s*(feat|ft|featuring|with)\b[^)\]]*[)\]]`)
+	// feat. artist at the end of a title
+	trailingFeaturingPattern = regexp.MustCompile(`\s(feat|ft|featuring)\.?\s.*$`)
+	// - remastered 2011, (2009 remaster), [remastered version]
+	remasterPattern = regexp.MustCompile(`[-(\[]\s*(\d{4}\s+)?(digital\s+)?remaster(ed)?(\s+\d{4})?(\s+version)?\s*[)\]]?`)
+)
+
+// words marking a different recording of a song
+var versionKeywords = []string{
+	"live", "karaoke", "cover", "instrumental", "acoustic", "remix", "sped", "slowed",
+	"reverb", "nightcore", "tribute", "demo", "8d", "cappella",
+}
+
+// lower cases, strips accents and punctuation
+func normalize(s string) string {
+	s = strings.ToLower(norm.NFD.String(s))
+	s = strings.ReplaceAll(s, "&", " and ")
+
+	var b strings.Builder
+
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// drop accents left over by NFD
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// normalizes a title or album name dropping featured artists and remaster notes
+func normalizeTitle(s string) string {
+	s = strings.ToLower(s)
+	s = featuringPattern.ReplaceAllString(s, " ")
+	s = trailingFeaturingPattern.ReplaceAllString(s, " ")
+	s = remasterPattern.ReplaceAllString(s, " ")
+
+	return normalize(s)
+}
+
+func tokens(s string) []string {
+	return strings.Fields(s)
+}
+
+// returns version keywords found in candidate but not in source
+func extraVersions(source string, candidate string) []string {
+	sourceTokens := tokens(normalize(source))
+	candidateTokens := tokens(normalize(candidate))
+
+	extra := []string{}
+
+	for _, keyword := range versionKeywords {
+		if contains(candidateTokens, keyword) && !contains(sourceTokens, keyword) {
+			extra = append(extra, keyword)
+		}
+	}
+
+	return extra
+}
+
+// dice coefficient of two token sets
+func dice(a []string, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	setA := toSet(a)
+	setB := toSet(b)
+
+	common := 0
+	for token := range setA {
+		if setB[token] {
+			common++
+		}
+	}
+
+	return 2 * float64(common) / float64(len(setA)+len(setB))
+}
+
+// fraction of a's tokens found in b
+func coverage(a []string, b []string) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+
+	setB := toSet(b)
+
+	found := 0
+	for _, token := range a {
+		if setB[token] {
+			found++
+		}
+	}
+
+	return float64(found) / float64(len(a))
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+
+	for _, item := range list {
+		set[item] = true
+	}
+
+	return set
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
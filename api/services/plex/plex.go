@@ -0,0 +1,332 @@
+package plex
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/initializers"
+)
+
+const pageSize = 100
+
+const clientIdentifier = "music-playlist-converter"
+
+var (
+	// e.g http://plex.local:32400
+	baseURL string
+	token   string
+	// identifies the server in library uris and web app urls
+	machineIdentifier string
+	machineMu         sync.Mutex
+)
+
+func init() {
+	initializers.LoadEnv()
+
+	baseURL = strings.TrimSuffix(os.Getenv("PLEX_BASE_URL"), "/")
+	token = os.Getenv("PLEX_TOKEN")
+}
+
+type Metadata struct {
+	RatingKey string `json:"ratingKey"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	// album artist of a track
+	GrandparentTitle string `json:"grandparentTitle"`
+	// track artist when it differs from the album artist
+	OriginalTitle string `json:"originalTitle"`
+	// album of a track
+	ParentTitle string `json:"parentTitle"`
+	// milliseconds
+	Duration int64 `json:"duration"`
+	// number of playlist items
+	LeafCount    int    `json:"leafCount"`
+	PlaylistType string `json:"playlistType"`
+}
+
+type MediaContainer struct {
+	Size              int         `json:"size"`
+	TotalSize         int         `json:"totalSize"`
+	MachineIdentifier string      `json:"machineIdentifier"`
+	Metadata          []*Metadata `json:"Metadata"`
+	Hub               []struct {
+		Type     string      `json:"type"`
+		Metadata []*Metadata `json:"Metadata"`
+	} `json:"Hub"`
+}
+
+type response struct {
+	MediaContainer MediaContainer `json:"MediaContainer"`
+}
+
+func authorize(agent *fiber.Agent) *fiber.Agent {
+	return agent.
+		Set("X-Plex-Token", token).
+		Set("X-Plex-Client-Identifier", clientIdentifier).
+		Set(fiber.HeaderAccept, fiber.MIMEApplicationJSON)
+}
+
+// checks the server is configured and the token is accepted
+func Ping() error {
+	if baseURL == "" || token == "" {
+		return errors.New("PLEX_BASE_URL and PLEX_TOKEN are required")
+	}
+
+	_, err := getMachineIdentifier()
+
+	return err
+}
+
+// the identifier is fetched once, a failed lookup is retried on the next call
+func getMachineIdentifier() (string, error) {
+	machineMu.Lock()
+	defer machineMu.Unlock()
+
+	if machineIdentifier != "" {
+		return machineIdentifier, nil
+	}
+
+	cli := fiber.Client{}
+
+	var bodyData response
+
+	status, _, errs := authorize(cli.Get(baseURL + "/identity")).Struct(&bodyData)
+
+	if errs != nil {
+		return "", errs[0]
+	}
+
+	if status != http.StatusOK || bodyData.MediaContainer.MachineIdentifier == "" {
+		return "", errors.New("error getting plex server identity | status code: " + fmt.Sprint(status))
+	}
+
+	machineIdentifier = bodyData.MediaContainer.MachineIdentifier
+
+	return machineIdentifier, nil
+}
+
+// library uri of the server, followed by /library/metadata/{ids} to point to items
+func libraryURI() (string, error) {
+	machine, err := getMachineIdentifier()
+
+	if err != nil {
+		return "", err
+	}
+
+	return "server://" + machine + "/com.plexapp.plugins.library", nil
+}
+
+// returns the audio playlist, nil when it doesn't exist
+func FindPlaylist(id string) (*Metadata, error) {
+	cli := fiber.Client{}
+
+	res := authorize(cli.Get(baseURL + "/playlists/" + url.PathEscape(id)))
+
+	var bodyData response
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.New("error verifying playlist | status code: " + fmt.Sprint(status))
+	}
+
+	for _, playlist := range bodyData.MediaContainer.Metadata {
+		if playlist.PlaylistType == "audio" {
+			return playlist, nil
+		}
+	}
+
+	return nil, nil
+}
+
+/*
+fetches the playlist items page by page until all tracks or the allowed number of conversions are fetched
+
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string) (tracks []*Metadata, truncated bool, err error) {
+	cli := fiber.Client{}
+
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	tracks = []*Metadata{}
+
+	for start := 0; ; start += pageSize {
+		res := authorize(cli.Get(baseURL + "/playlists/" + url.PathEscape(id) + "/items?X-Plex-Container-Start=" + fmt.Sprint(start) +
+			"&X-Plex-Container-Size=" + fmt.Sprint(pageSize)))
+
+		var bodyData response
+		status, _, errs := res.Struct(&bodyData)
+
+		if errs != nil {
+			return nil, false, errs[0]
+		}
+
+		if status != http.StatusOK {
+			return nil, false, errors.New("error getting playlist tracks | status code: " + fmt.Sprint(status))
+		}
+
+		items := bodyData.MediaContainer.Metadata
+		tracks = append(tracks, items...)
+		hasMore := len(items) > 0 && start+len(items) < bodyData.MediaContainer.TotalSize
+
+		// allowedNumberOfConversions = 0 means convert all tracks
+		if allowedNumberOfConversions != 0 && len(tracks) >= allowedNumberOfConversions {
+			truncated = len(tracks) > allowedNumberOfConversions || hasMore
+			tracks = tracks[0:allowedNumberOfConversions]
+
+			break
+		}
+
+		if !hasMore {
+			break
+		}
+	}
+
+	return tracks, truncated, nil
+}
+
+func ToSearchTrackList(tracks []*Metadata) services.SearchTrackList {
+	searchTrackList := make(services.SearchTrackList, 0, len(tracks))
+
+	for _, track := range tracks {
+		searchTrackList = append(searchTrackList, toSearchTrack(track))
+	}
+
+	return searchTrackList
+}
+
+func toSearchTrack(track *Metadata) *services.SearchTrack {
+	artist := track.OriginalTitle
+	if artist == "" {
+		artist = track.GrandparentTitle
+	}
+
+	searchTrack := &services.SearchTrack{
+		Id:       track.RatingKey,
+		Title:    track.Title,
+		Duration: track.Duration,
+		Album:    shared_types.Album{Name: track.ParentTitle},
+	}
+
+	if artist != "" {
+		searchTrack.Artists = shared_types.Artists{{Name: artist}}
+	}
+
+	return searchTrack
+}
+
+/*
+searches the music libraries of the server, only the track hub of the results is used
+
+plex matches the query against titles so the artist is left to the matcher
+*/
+func SearchTracks(query string, limit int) ([]*Metadata, error) {
+	cli := fiber.Client{}
+
+	res := authorize(cli.Get(baseURL + "/hubs/search?query=" + url.QueryEscape(query) + "&limit=" + fmt.Sprint(limit)))
+
+	var bodyData response
+
+	status, _, errs := res.Struct(&bodyData)
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.New("error searching track | status code: " + fmt.Sprint(status))
+	}
+
+	for _, hub := range bodyData.MediaContainer.Hub {
+		if hub.Type == "track" {
+			return hub.Metadata, nil
+		}
+	}
+
+	return []*Metadata{}, nil
+}
+
+// creates an empty audio playlist and returns its rating key
+func CreatePlaylist(name string) (string, error) {
+	cli := fiber.Client{}
+
+	uri, err := libraryURI()
+
+	if err != nil {
+		return "", err
+	}
+
+	res := authorize(cli.Post(baseURL + "/playlists?type=audio&smart=0&title=" + url.QueryEscape(name) + "&uri=" + url.QueryEscape(uri)))
+
+	var bodyData response
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return "", errs[0]
+	}
+
+	if status == http.StatusOK && len(bodyData.MediaContainer.Metadata) > 0 {
+		return bodyData.MediaContainer.Metadata[0].RatingKey, nil
+	}
+
+	return "", errors.New("error creating playlist | status code: " + fmt.Sprint(status))
+}
+
+func AddTracksToPlaylist(playlistId string, ratingKeys []string) error {
+	cli := fiber.Client{}
+
+	uri, err := libraryURI()
+
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(ratingKeys); start += pageSize {
+		end := start + pageSize
+		if end > len(ratingKeys) {
+			end = len(ratingKeys)
+		}
+
+		itemsURI := uri + "/library/metadata/" + strings.Join(ratingKeys[start:end], ",")
+
+		res := authorize(cli.Put(baseURL + "/playlists/" + url.PathEscape(playlistId) + "/items?uri=" + url.QueryEscape(itemsURI)))
+
+		status, b, errs := res.Bytes()
+
+		if errs != nil {
+			return errs[0]
+		}
+
+		if status != http.StatusOK {
+			log.Println("plex add tracks : ", string(b))
+
+			return errors.New("error adding tracks to playlist | status code: " + fmt.Sprint(status))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package plex
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "plex"
+
+const appHost = "app.plex.tv"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "Plex"
+}
+
+// the server configured with PLEX_BASE_URL and the hosted web app
+func (p *provider) Hosts() []string {
+	u, err := url.Parse(baseURL)
+
+	if err != nil || u.Host == "" {
+		return []string{appHost}
+	}
+
+	return []string{u.Host, appHost}
+}
+
+/*
+expects web app urls in the format {base}/web/index.html#!/server/{machineIdentifier}/playlist?key=%2Fplaylists%2F{id}
+or https://app.plex.tv/desktop/#!/server/{machineIdentifier}/playlist?key=%2Fplaylists%2F{id}
+*/
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	route, rawQuery, found := strings.Cut(strings.TrimPrefix(u.Fragment, "!"), "?")
+
+	if !found || !strings.HasSuffix(route, "/playlist") {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+
+	if err != nil {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	keyParts := strings.Split(strings.Trim(query.Get("key"), "/"), "/")
+
+	if len(keyParts) < 2 || keyParts[0] != "playlists" || keyParts[1] == "" {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	return keyParts[1], nil
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := FindPlaylist(id)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         playlist.RatingKey,
+		Title:      playlist.Title,
+		Url:        p.PlaylistURL(playlist.RatingKey),
+		TrackCount: playlist.LeafCount,
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	tracks, truncated, err := GetPlaylistTracks(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(tracks), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	tracks, err := SearchTracks(track.Title, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSearchTrackList(tracks), nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	ids := make([]string, 0, len(tracks))
+
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+
+	return AddTracksToPlaylist(playlistId, ids)
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	machine, err := getMachineIdentifier()
+
+	if err != nil {
+		return baseURL + "/web/index.html"
+	}
+
+	return baseURL + "/web/index.html#!/server/" + machine + "/playlist?key=" + url.QueryEscape("/playlists/"+id)
+}
+
+func (p *provider) TrackURL(id string) string {
+	machine, err := getMachineIdentifier()
+
+	if err != nil {
+		return ""
+	}
+
+	return baseURL + "/web/index.html#!/server/" + machine + "/details?key=" + url.QueryEscape("/library/metadata/"+id)
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gookit/goutil/arrutil"
+)
+
+var ErrInvalidPlaylistURL = errors.New("invalid playlist url")
+
+// returned by FindPlaylist for playlists without tracks to convert e.g a video description without a tracklist
+var ErrEmptyPlaylist = errors.New("playlist has no tracks")
+
+// provider agnostic playlist details
+type Playlist struct {
+	Id         string
+	Title      string
+	Url        string
+	TrackCount int
+}
+
+/*
+Provider is implemented by every music platform a playlist can be converted from or to.
+
+Tracks are exchanged using SearchTrack, a track returned by SearchTracks or
+GetPlaylistTracks carries the provider specific id in SearchTrack.Id
+*/
+type Provider interface {
+	// unique name used in sessions and routes e.g "spotify"
+	Name() string
+	// human readable name used in messages e.g "Spotify"
+	DisplayName() string
+	// playlist url hosts handled by the provider
+	Hosts() []string
+
+	// extracts the playlist id from a playlist url, returns ErrInvalidPlaylistURL if the url is not a playlist url
+	ResolvePlaylistURL(u *url.URL) (string, error)
+	/*
+		returns nil if the playlist does not exist.
+		sessionId identifies the user for providers reading private playlists, public playlists ignore it
+	*/
+	FindPlaylist(id string, sessionId string) (*Playlist, error)
+	// truncated is true when only the allowed number of conversions was fetched from a longer playlist
+	GetPlaylistTracks(id string, sessionId string) (tracks SearchTrackList, truncated bool, err error)
+	// returns at most limit candidates for the track, best ranked first
+	SearchTracks(track *SearchTrack, limit int) (SearchTrackList, error)
+
+	CreatePlaylist(name string, sessionId string) (string, error)
+	// adds tracks previously returned by SearchTracks to the playlist
+	AddTracks(playlistId string, tracks SearchTrackList, sessionId string) error
+	PlaylistURL(id string) string
+}
+
+// implemented by providers that can look up a track by its ISRC
+type ISRCSearcher interface {
+	SearchISRC(isrc string) (*SearchTrack, bool, error)
+}
+
+// implemented by providers with web or file urls for their tracks, exported playlist files link to them
+type TrackLinker interface {
+	// returns an empty string if the track has no url
+	TrackURL(id string) string
+}
+
+// implemented by providers that can name tracks from their urls e.g the video urls of a Google Takeout playlist
+type TrackURLResolver interface {
+	// returns the tracks of the urls in order, nil for the urls that weren't found. stops when ctx is done
+	LookupTrackURLs(ctx context.Context, urls []string) (SearchTrackList, error)
+}
+
+// implemented by providers whose playlist urls are told apart by their scheme instead of their host e.g file urls
+type SchemeProvider interface {
+	Schemes() []string
+}
+
+// implemented by providers playlists can only be converted from e.g uploaded playlist files
+type SourceOnlyProvider interface {
+	SourceOnly() bool
+}
+
+func isSourceOnly(p Provider) bool {
+	sourceOnly, ok := p.(SourceOnlyProvider)
+
+	return ok && sourceOnly.SourceOnly()
+}
+
+var (
+	providers     = map[string]Provider{}
+	providerNames = []string{}
+	providersMu   sync.RWMutex
+)
+
+// registers a provider, it is expected to be called from the provider package init function
+func RegisterProvider(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if _, exists := providers[p.Name()]; exists {
+		panic("provider already registered: " + p.Name())
+	}
+
+	providers[p.Name()] = p
+	providerNames = append(providerNames, p.Name())
+}
+
+func GetProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	p, ok := providers[name]
+
+	return p, ok
+}
+
+// finds the provider that handles playlist urls with the given host, urls without a host have no provider
+func FindProviderByHost(host string) (Provider, bool) {
+	if host == "" {
+		return nil, false
+	}
+
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	for _, name := range providerNames {
+		if arrutil.Contains(providers[name].Hosts(), host) {
+			return providers[name], true
+		}
+	}
+
+	return nil, false
+}
+
+// returns registered providers in registration order
+func Providers() []Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	list := make([]Provider, 0, len(providerNames))
+
+	for _, name := range providerNames {
+		list = append(list, providers[name])
+	}
+
+	return list
+}
+
+// reports whether playlists can be converted to the named provider
+func IsConversionTarget(name string) bool {
+	p, ok := GetProvider(name)
+
+	return ok && !isSourceOnly(p)
+}
+
+// returns names of the providers a playlist from the source provider can be converted to
+func ConversionTargets(source string) []string {
+	targets := []string{}
+
+	for _, p := range Providers() {
+		if p.Name() != source && !isSourceOnly(p) {
+			targets = append(targets, p.Name())
+		}
+	}
+
+	return targets
+}
+
+// finds the provider of a playlist url by its scheme, or by its host for the providers without a scheme of their own
+func FindProviderByURL(u *url.URL) (Provider, bool) {
+	if p, found := findProviderByScheme(strings.ToLower(u.Scheme)); found {
+		return p, true
+	}
+
+	return FindProviderByHost(u.Host)
+}
+
+func findProviderByScheme(scheme string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	for _, name := range providerNames {
+		if schemeProvider, ok := providers[name].(SchemeProvider); ok && arrutil.Contains(schemeProvider.Schemes(), scheme) {
+			return providers[name], true
+		}
+	}
+
+	return nil, false
+}
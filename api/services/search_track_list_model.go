@@ -5,8 +5,21 @@ import "github.com/to-dy/music-playlist-converter/api/services/shared_types"
 type SearchTrackList []*SearchTrack
 
 type SearchTrack struct {
+	// provider specific id of the track e.g spotify uri or youtube video id
+	Id       string
 	Title    string
 	Artists  shared_types.Artists
 	Duration int64
 	Album    shared_types.Album
+	// international standard recording code, empty when the provider doesn't expose it
+	ISRC string
+}
+
+// returns the name of the first artist or an empty string
+func (t *SearchTrack) MainArtist() string {
+	if len(t.Artists) > 0 {
+		return t.Artists[0].Name
+	}
+
+	return ""
 }
@@ -0,0 +1,142 @@
+package soundcloud
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "soundcloud"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "SoundCloud"
+}
+
+func (p *provider) Hosts() []string {
+	return []string{"soundcloud.com", "www.soundcloud.com", "m.soundcloud.com"}
+}
+
+/*
+expects urls in the format https://soundcloud.com/{user}/sets/{slug} or https://soundcloud.com/{user}/likes,
+the url is resolved to the set or user id with the resolve endpoint
+*/
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	if len(pathParts) < 2 || pathParts[0] == "" {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	likes := len(pathParts) == 2 && pathParts[1] == "likes"
+	set := len(pathParts) >= 3 && pathParts[1] == "sets" && pathParts[2] != ""
+
+	if !likes && !set {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	pageURL := "https://soundcloud.com/" + pathParts[0]
+	if set {
+		pageURL += "/sets/" + pathParts[2]
+	}
+
+	resource, err := resolve(pageURL)
+
+	if err != nil {
+		log.Println("soundcloud resolve error", err)
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	if resource == nil {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	if likes && resource.Kind == "user" {
+		return likesPrefix + fmt.Sprint(resource.Id), nil
+	}
+
+	if set && resource.Kind == "playlist" {
+		return fmt.Sprint(resource.Id), nil
+	}
+
+	return "", services.ErrInvalidPlaylistURL
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := FindPlaylist(id)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	playlistURL := playlist.Permalink
+	if playlistURL == "" {
+		playlistURL = p.PlaylistURL(id)
+	}
+
+	return &services.Playlist{
+		Id:         id,
+		Title:      playlist.Title,
+		Url:        playlistURL,
+		TrackCount: playlist.TrackCount,
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	tracks, truncated, err := GetPlaylistTracks(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(tracks), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	tracks, err := SearchTracks(track.Title, track.MainArtist(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSearchTrackList(tracks), nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name, sessionId)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	ids := make([]string, 0, len(tracks))
+
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+
+	return AddTracksToPlaylist(playlistId, ids, sessionId)
+}
+
+// soundcloud urls use slugs, the api url of the set redirects to its page
+func (p *provider) PlaylistURL(id string) string {
+	if strings.HasPrefix(id, likesPrefix) {
+		return "https://api.soundcloud.com/users/" + strings.TrimPrefix(id, likesPrefix)
+	}
+
+	return "https://api.soundcloud.com/playlists/" + id
+}
+
+func (p *provider) TrackURL(id string) string {
+	return "https://api.soundcloud.com/tracks/" + id
+}
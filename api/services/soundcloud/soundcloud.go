@@ -0,0 +1,558 @@
+package soundcloud
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+	"github.com/to-dy/music-playlist-converter/initializers"
+)
+
+var soundcloudBaseURL = "https://api.soundcloud.com"
+
+var endpoint = oauth2.Endpoint{
+	AuthURL:   "https://secure.soundcloud.com/authorize",
+	TokenURL:  "https://secure.soundcloud.com/oauth/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// soundcloud pages hold at most 200 items
+const pageSize = 200
+
+type User struct {
+	Id       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type Track struct {
+	Id    int64  `json:"id"`
+	Title string `json:"title"`
+	// milliseconds
+	Duration          int64 `json:"duration"`
+	User              User  `json:"user"`
+	PublisherMetadata *struct {
+		Artist     string `json:"artist"`
+		AlbumTitle string `json:"album_title"`
+		ISRC       string `json:"isrc"`
+	} `json:"publisher_metadata"`
+}
+
+type TracksResponse struct {
+	Collection []*Track `json:"collection"`
+	NextHref   string   `json:"next_href"`
+}
+
+type PlaylistResponse struct {
+	Id         int64    `json:"id"`
+	Kind       string   `json:"kind"`
+	Title      string   `json:"title"`
+	TrackCount int      `json:"track_count"`
+	Permalink  string   `json:"permalink_url"`
+	Tracks     []*Track `json:"tracks"`
+}
+
+// resource returned by the resolve endpoint
+type resolved struct {
+	Id         int64  `json:"id"`
+	Kind       string `json:"kind"`
+	Title      string `json:"title"`
+	Username   string `json:"username"`
+	Permalink  string `json:"permalink_url"`
+	TrackCount int    `json:"track_count"`
+	// number of liked tracks of a user
+	LikesCount int `json:"public_favorites_count"`
+}
+
+var OauthConfig *oauth2.Config
+var clientCredentialsConfig *clientcredentials.Config
+
+func init() {
+	initializers.LoadEnv()
+
+	OauthConfig = &oauth2.Config{
+		ClientID:     os.Getenv("SOUNDCLOUD_CLIENT_ID"),
+		ClientSecret: os.Getenv("SOUNDCLOUD_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("SOUNDCLOUD_REDIRECT_URI"),
+		Endpoint:     endpoint,
+	}
+
+	clientCredentialsConfig = &clientcredentials.Config{
+		ClientID:     os.Getenv("SOUNDCLOUD_CLIENT_ID"),
+		ClientSecret: os.Getenv("SOUNDCLOUD_CLIENT_SECRET"),
+		TokenURL:     endpoint.TokenURL,
+		AuthStyle:    oauth2.AuthStyleInParams,
+	}
+}
+
+/*
+soundcloud requires PKCE for the authorization code flow, golang.org/x/oauth2 v0.8.0 has no helper for it.
+returns a code verifier and the auth code url carrying its S256 challenge
+*/
+func AuthCodeURL(state string) (verifier string, authURL string, err error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	verifier = base64.RawURLEncoding.EncodeToString(b)
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL = OauthConfig.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	return verifier, authURL, nil
+}
+
+func Exchange(ctx context.Context, code string, verifier string) (*oauth2.Token, error) {
+	return OauthConfig.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+}
+
+func StoreAuthCodeToken(token *oauth2.Token, sessionId string) {
+	prefix := sessionId + "_"
+
+	tokenstore.GlobalTokenStore.SetToken(prefix+string(tokenstore.SOUNDCLOUD_AC), tokenstore.TokenEntry{
+		Token:      token,
+		Expiration: token.Expiry,
+	})
+}
+
+func getAuthCodeToken(sessionId string) (string, error) {
+	token, tokenValid := tokenstore.GlobalTokenStore.GetToken(sessionId + "_" + string(tokenstore.SOUNDCLOUD_AC))
+
+	if tokenValid {
+		return token.AccessToken, nil
+	}
+
+	if token != nil {
+		// refresh token
+		ts := OauthConfig.TokenSource(context.Background(), token)
+		token, err := ts.Token()
+
+		if err != nil {
+			return "", err
+		}
+
+		StoreAuthCodeToken(token, sessionId)
+
+		return token.AccessToken, nil
+	}
+
+	return "", errors.New("soundcloud token not found, authorize SoundCloud first")
+}
+
+func getClientToken() (string, error) {
+	token, tokenValid := tokenstore.GlobalTokenStore.GetToken(string(tokenstore.SOUNDCLOUD_CC))
+
+	if tokenValid {
+		return token.AccessToken, nil
+	}
+
+	token, err := clientCredentialsConfig.Token(context.Background())
+
+	if err != nil {
+		return "", err
+	}
+
+	tokenstore.GlobalTokenStore.SetToken(string(tokenstore.SOUNDCLOUD_CC), tokenstore.TokenEntry{
+		Token:      token,
+		Expiration: token.Expiry,
+	})
+
+	return token.AccessToken, nil
+}
+
+// resolves a soundcloud.com url to the api resource it points to, nil when it doesn't exist
+func resolve(pageURL string) (*resolved, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	// the resolve endpoint redirects to the resource
+	res := cli.Get(soundcloudBaseURL+"/resolve?url="+url.QueryEscape(pageURL)).
+		Set("Authorization", "OAuth "+token).
+		MaxRedirectsCount(3)
+
+	var bodyData resolved
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status == http.StatusOK {
+		return &bodyData, nil
+	}
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	return nil, errors.New("error resolving soundcloud url | status code: " + fmt.Sprint(status))
+}
+
+// the liked tracks of a user are read as a playlist with the id "likes:{userId}"
+const likesPrefix = "likes:"
+
+// returns the user whose likes are read, nil when it doesn't exist
+func findUser(id string) (*resolved, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	res := cli.Get(soundcloudBaseURL+"/users/"+url.PathEscape(id)).
+		Set("Authorization", "OAuth "+token)
+
+	var bodyData resolved
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status == http.StatusOK {
+		return &bodyData, nil
+	}
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	return nil, errors.New("error getting user | status code: " + fmt.Sprint(status))
+}
+
+func FindPlaylist(id string) (*PlaylistResponse, error) {
+	if strings.HasPrefix(id, likesPrefix) {
+		user, err := findUser(strings.TrimPrefix(id, likesPrefix))
+
+		if err != nil || user == nil {
+			return nil, err
+		}
+
+		likes := &PlaylistResponse{
+			Id:         user.Id,
+			Kind:       "likes",
+			Title:      user.Username + " likes",
+			TrackCount: user.LikesCount,
+		}
+
+		if user.Permalink != "" {
+			likes.Permalink = strings.TrimSuffix(user.Permalink, "/") + "/likes"
+		}
+
+		return likes, nil
+	}
+
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	res := cli.Get(soundcloudBaseURL+"/playlists/"+url.PathEscape(id)+"?show_tracks=false").
+		Set("Authorization", "OAuth "+token)
+
+	var bodyData PlaylistResponse
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status == http.StatusOK {
+		return &bodyData, nil
+	}
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	return nil, errors.New("error verifying playlist | status code: " + fmt.Sprint(status))
+}
+
+/*
+fetches the tracks of a set, or the liked tracks of a user, page by page until all tracks
+or the allowed number of conversions are fetched
+
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string) (tracks []*Track, truncated bool, err error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, false, tokenErr
+	}
+
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	next := soundcloudBaseURL + "/playlists/" + url.PathEscape(id) + "/tracks"
+	if strings.HasPrefix(id, likesPrefix) {
+		next = soundcloudBaseURL + "/users/" + url.PathEscape(strings.TrimPrefix(id, likesPrefix)) + "/likes/tracks"
+	}
+
+	next += "?linked_partitioning=true&limit=" + fmt.Sprint(pageSize)
+	tracks = []*Track{}
+
+	// next_href keeps the query parameters
+	for next != "" {
+		res := cli.Get(next).Set("Authorization", "OAuth "+token)
+
+		var bodyData TracksResponse
+		status, _, errs := res.Struct(&bodyData)
+
+		if errs != nil {
+			return nil, false, errs[0]
+		}
+
+		if status != http.StatusOK {
+			return nil, false, errors.New("error getting playlist tracks | status code: " + fmt.Sprint(status))
+		}
+
+		tracks = append(tracks, bodyData.Collection...)
+		next = bodyData.NextHref
+
+		// allowedNumberOfConversions = 0 means convert all tracks
+		if allowedNumberOfConversions != 0 && len(tracks) >= allowedNumberOfConversions {
+			truncated = len(tracks) > allowedNumberOfConversions || next != ""
+			tracks = tracks[0:allowedNumberOfConversions]
+
+			break
+		}
+	}
+
+	return tracks, truncated, nil
+}
+
+func ToSearchTrackList(tracks []*Track) services.SearchTrackList {
+	searchTrackList := make(services.SearchTrackList, 0, len(tracks))
+
+	for _, track := range tracks {
+		searchTrackList = append(searchTrackList, toSearchTrack(track))
+	}
+
+	return searchTrackList
+}
+
+func toSearchTrack(track *Track) *services.SearchTrack {
+	artist, title := ArtistAndTitle(track)
+
+	searchTrack := &services.SearchTrack{
+		Id:       fmt.Sprint(track.Id),
+		Title:    title,
+		Duration: track.Duration,
+	}
+
+	if artist != "" {
+		searchTrack.Artists = shared_types.Artists{{Name: artist}}
+	}
+
+	if track.PublisherMetadata != nil {
+		searchTrack.Album = shared_types.Album{Name: track.PublisherMetadata.AlbumTitle}
+		searchTrack.ISRC = track.PublisherMetadata.ISRC
+	}
+
+	return searchTrack
+}
+
+// separators used by uploaders between the artist and the title
+var titleSeparators = []string{" - ", " – ", " — "}
+
+/*
+returns the artist and title of a track
+
+the publisher metadata artist is used when set, otherwise uploads named "Artist - Title" are split,
+the uploader name is the artist of anything else
+*/
+func ArtistAndTitle(track *Track) (artist string, title string) {
+	title = strings.TrimSpace(track.Title)
+
+	if track.PublisherMetadata != nil && track.PublisherMetadata.Artist != "" {
+		artist = track.PublisherMetadata.Artist
+
+		// the title often repeats the artist anyway
+		for _, separator := range titleSeparators {
+			if strings.HasPrefix(title, artist+separator) {
+				return artist, strings.TrimSpace(strings.TrimPrefix(title, artist+separator))
+			}
+		}
+
+		return artist, title
+	}
+
+	for _, separator := range titleSeparators {
+		if left, right, ok := strings.Cut(title, separator); ok && strings.TrimSpace(left) != "" && strings.TrimSpace(right) != "" {
+			return strings.TrimSpace(left), strings.TrimSpace(right)
+		}
+	}
+
+	return track.User.Username, title
+}
+
+// searches soundcloud tracks and returns at most limit results in the order soundcloud ranks them
+func SearchTracks(query string, artist string, limit int) ([]*Track, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	q := query
+	if artist != "" {
+		q = artist + " - " + query
+	}
+
+	res := cli.Get(soundcloudBaseURL+"/tracks?linked_partitioning=true&limit="+fmt.Sprint(limit)+"&q="+url.QueryEscape(q)).
+		Set("Authorization", "OAuth "+token)
+
+	var bodyData TracksResponse
+
+	status, _, errs := res.Struct(&bodyData)
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status == http.StatusOK {
+		return bodyData.Collection, nil
+	}
+
+	return nil, errors.New("error searching track | status code: " + fmt.Sprint(status))
+}
+
+type trackRef struct {
+	Id string `json:"id"`
+}
+
+type playlistTracksBody struct {
+	Playlist struct {
+		Title   string     `json:"title,omitempty"`
+		Sharing string     `json:"sharing,omitempty"`
+		Tracks  []trackRef `json:"tracks"`
+	} `json:"playlist"`
+}
+
+// creates an empty public set owned by the session user and returns its id
+func CreatePlaylist(name string, sessionId string) (string, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getAuthCodeToken(sessionId)
+
+	if tokenErr != nil {
+		return "", tokenErr
+	}
+
+	var body playlistTracksBody
+	body.Playlist.Title = name
+	body.Playlist.Sharing = "public"
+	body.Playlist.Tracks = []trackRef{}
+
+	res := cli.Post(soundcloudBaseURL+"/playlists").
+		Set("Authorization", "OAuth "+token).
+		JSON(body)
+
+	var bodyData PlaylistResponse
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return "", errs[0]
+	}
+
+	if (status == http.StatusCreated || status == http.StatusOK) && bodyData.Id != 0 {
+		return fmt.Sprint(bodyData.Id), nil
+	}
+
+	return "", errors.New("error creating playlist | status code: " + fmt.Sprint(status))
+}
+
+// soundcloud replaces the whole track list of a set, the new tracks are appended to the current ones
+func AddTracksToPlaylist(playlistId string, trackIds []string, sessionId string) error {
+	cli := fiber.Client{}
+
+	token, tokenErr := getAuthCodeToken(sessionId)
+
+	if tokenErr != nil {
+		return tokenErr
+	}
+
+	res := cli.Get(soundcloudBaseURL+"/playlists/"+url.PathEscape(playlistId)).
+		Set("Authorization", "OAuth "+token)
+
+	var current PlaylistResponse
+
+	status, _, errs := res.Struct(&current)
+
+	if errs != nil {
+		return errs[0]
+	}
+
+	if status != http.StatusOK {
+		return errors.New("error getting playlist | status code: " + fmt.Sprint(status))
+	}
+
+	var body playlistTracksBody
+
+	for _, track := range current.Tracks {
+		body.Playlist.Tracks = append(body.Playlist.Tracks, trackRef{Id: fmt.Sprint(track.Id)})
+	}
+
+	for _, id := range trackIds {
+		body.Playlist.Tracks = append(body.Playlist.Tracks, trackRef{Id: id})
+	}
+
+	update := cli.Put(soundcloudBaseURL+"/playlists/"+url.PathEscape(playlistId)).
+		Set("Authorization", "OAuth "+token).
+		JSON(body)
+
+	status, b, errs := update.Bytes()
+
+	if errs != nil {
+		return errs[0]
+	}
+
+	if status != http.StatusOK {
+		log.Println("soundcloud update playlist : ", string(b))
+
+		return errors.New("error adding tracks to playlist | status code: " + fmt.Sprint(status))
+	}
+
+	return nil
+}
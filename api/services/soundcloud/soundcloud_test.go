@@ -0,0 +1,238 @@
+package soundcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+)
+
+const testClientToken = "client-token"
+
+var testUser = &resolved{Id: 42, Kind: "user", Username: "dj", Permalink: "https://soundcloud.com/dj", LikesCount: 3}
+
+// fake of the soundcloud api with the user "dj", its set "mix" of 2 tracks and 3 liked tracks
+type fakeAPI struct {
+	*httptest.Server
+
+	// pages requested by the tracks endpoints
+	pages int
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	f := &fakeAPI{}
+
+	tracks := map[string][]*Track{
+		"/playlists/7/tracks":    {{Id: 1, Title: "Artist - First"}, {Id: 2, Title: "Second", User: User{Username: "uploader"}}},
+		"/users/42/likes/tracks": {{Id: 3, Title: "Liked 1"}, {Id: 4, Title: "Liked 2"}, {Id: 5, Title: "Liked 3"}},
+	}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "OAuth "+testClientToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/resolve":
+			switch r.URL.Query().Get("url") {
+			case "https://soundcloud.com/dj":
+				writeJSON(w, testUser)
+			case "https://soundcloud.com/dj/sets/mix":
+				writeJSON(w, &resolved{Id: 7, Kind: "playlist", Title: "Mix"})
+			case "https://soundcloud.com/dj/sets/not-a-set":
+				writeJSON(w, &resolved{Id: 9, Kind: "track"})
+			default:
+				writeNotFound(w)
+			}
+
+		case "/users/42":
+			writeJSON(w, testUser)
+
+		case "/playlists/7":
+			writeJSON(w, &PlaylistResponse{Id: 7, Kind: "playlist", Title: "Mix", TrackCount: 2, Permalink: "https://soundcloud.com/dj/sets/mix"})
+
+		case "/playlists/7/tracks", "/users/42/likes/tracks":
+			if r.URL.Query().Get("linked_partitioning") != "true" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			f.pages++
+
+			// pages of 2 tracks, next_href carries the offset
+			all := tracks[r.URL.Path]
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			end := offset + 2
+
+			page := &TracksResponse{}
+
+			if end < len(all) {
+				page.NextHref = f.URL + r.URL.Path + "?linked_partitioning=true&offset=" + strconv.Itoa(end)
+			} else {
+				end = len(all)
+			}
+
+			page.Collection = all[offset:end]
+			writeJSON(w, page)
+
+		default:
+			writeNotFound(w)
+		}
+	}))
+	t.Cleanup(f.Close)
+
+	prevBaseURL := soundcloudBaseURL
+	soundcloudBaseURL = f.URL
+	t.Cleanup(func() { soundcloudBaseURL = prevBaseURL })
+
+	tokenstore.GlobalTokenStore.SetToken(string(tokenstore.SOUNDCLOUD_CC), tokenstore.TokenEntry{
+		Token:      &oauth2.Token{AccessToken: testClientToken},
+		Expiration: time.Now().Add(time.Hour),
+	})
+
+	return f
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// soundcloud answers missing resources with a json error
+func writeNotFound(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(`{"code":404,"message":"404 - Not Found","errors":[]}`))
+}
+
+func TestResolvePlaylistURL(t *testing.T) {
+	newFakeAPI(t)
+
+	tests := []struct {
+		url     string
+		want    string
+		wantErr error
+	}{
+		{url: "https://soundcloud.com/dj/sets/mix", want: "7"},
+		{url: "https://m.soundcloud.com/dj/sets/mix/?si=share", want: "7"},
+		{url: "https://soundcloud.com/dj/likes", want: "likes:42"},
+		{url: "https://soundcloud.com/dj/likes/", want: "likes:42"},
+		{url: "https://soundcloud.com/dj", wantErr: services.ErrInvalidPlaylistURL},
+		{url: "https://soundcloud.com/dj/tracks", wantErr: services.ErrInvalidPlaylistURL},
+		{url: "https://soundcloud.com/dj/sets/", wantErr: services.ErrInvalidPlaylistURL},
+		{url: "https://soundcloud.com/dj/sets/missing", wantErr: services.ErrInvalidPlaylistURL},
+		{url: "https://soundcloud.com/dj/sets/not-a-set", wantErr: services.ErrInvalidPlaylistURL},
+		{url: "https://soundcloud.com/nobody/likes", wantErr: services.ErrInvalidPlaylistURL},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		id, err := (&provider{}).ResolvePlaylistURL(u)
+
+		if id != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("ResolvePlaylistURL(%s) = %q, %v, want %q, %v", tt.url, id, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFindPlaylist(t *testing.T) {
+	newFakeAPI(t)
+
+	tests := []struct {
+		id   string
+		want *services.Playlist
+	}{
+		{id: "7", want: &services.Playlist{Id: "7", Title: "Mix", Url: "https://soundcloud.com/dj/sets/mix", TrackCount: 2}},
+		{id: "likes:42", want: &services.Playlist{Id: "likes:42", Title: "dj likes", Url: "https://soundcloud.com/dj/likes", TrackCount: 3}},
+		{id: "404", want: nil},
+		{id: "likes:404", want: nil},
+	}
+
+	for _, tt := range tests {
+		playlist, err := (&provider{}).FindPlaylist(tt.id, "")
+
+		if err != nil {
+			t.Errorf("FindPlaylist(%s) error = %v", tt.id, err)
+			continue
+		}
+
+		if (playlist == nil) != (tt.want == nil) || (playlist != nil && *playlist != *tt.want) {
+			t.Errorf("FindPlaylist(%s) = %+v, want %+v", tt.id, playlist, tt.want)
+		}
+	}
+}
+
+func TestGetPlaylistTracks(t *testing.T) {
+	f := newFakeAPI(t)
+
+	tests := []struct {
+		name      string
+		id        string
+		allowed   string
+		want      []string
+		truncated bool
+		pages     int
+	}{
+		{name: "set", id: "7", want: []string{"First", "Second"}, pages: 1},
+		{name: "likes follow next_href", id: "likes:42", want: []string{"Liked 1", "Liked 2", "Liked 3"}, pages: 2},
+		{name: "likes cut to the allowed number of conversions", id: "likes:42", allowed: "2", want: []string{"Liked 1", "Liked 2"}, truncated: true, pages: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALLOWED_NUMBER_OF_CONVERSIONS", tt.allowed)
+			f.pages = 0
+
+			tracks, truncated, err := (&provider{}).GetPlaylistTracks(tt.id, "")
+
+			if err != nil || truncated != tt.truncated || len(tracks) != len(tt.want) {
+				t.Fatalf("GetPlaylistTracks(%s) = %d tracks, %v, %v, want %d, %v", tt.id, len(tracks), truncated, err, len(tt.want), tt.truncated)
+			}
+
+			for i, title := range tt.want {
+				if tracks[i].Title != title {
+					t.Errorf("track %d = %q, want %q", i, tracks[i].Title, title)
+				}
+			}
+
+			if f.pages != tt.pages {
+				t.Errorf("requested %d pages, want %d", f.pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestArtistAndTitle(t *testing.T) {
+	type metadata = struct {
+		Artist     string `json:"artist"`
+		AlbumTitle string `json:"album_title"`
+		ISRC       string `json:"isrc"`
+	}
+
+	tests := []struct {
+		track  *Track
+		artist string
+		title  string
+	}{
+		{track: &Track{Title: "Artist - Title", User: User{Username: "uploader"}}, artist: "Artist", title: "Title"},
+		{track: &Track{Title: "Title", User: User{Username: "uploader"}}, artist: "uploader", title: "Title"},
+		{track: &Track{Title: "Artist – Title (Remix)", PublisherMetadata: &metadata{Artist: "Artist"}}, artist: "Artist", title: "Title (Remix)"},
+		{track: &Track{Title: "Other - Title", PublisherMetadata: &metadata{Artist: "Artist"}}, artist: "Artist", title: "Other - Title"},
+	}
+
+	for _, tt := range tests {
+		if artist, title := ArtistAndTitle(tt.track); artist != tt.artist || title != tt.title {
+			t.Errorf("ArtistAndTitle(%q) = %q, %q, want %q, %q", tt.track.Title, artist, title, tt.artist, tt.title)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package spotify
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "spotify"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "Spotify"
+}
+
+func (p *provider) Hosts() []string {
+	return []string{"open.spotify.com"}
+}
+
+// expects urls in the format https://open.spotify.com/playlist/{id}
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	pathParts := strings.Split(u.Path, "/")
+
+	if len(pathParts) < 3 || pathParts[1] != "playlist" || pathParts[2] == "" {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	return pathParts[2], nil
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := FindPlaylist(id)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         playlist.Id,
+		Title:      playlist.Name,
+		Url:        p.PlaylistURL(playlist.Id),
+		TrackCount: playlist.Tracks.Total,
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	tracks, truncated, err := GetPlaylistTracks(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(tracks), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	entries, err := SearchTracks(track.Title, track.MainArtist(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(services.SearchTrackList, 0, len(entries))
+
+	for _, entry := range entries {
+		candidates = append(candidates, toSearchTrack(entry))
+	}
+
+	return candidates, nil
+}
+
+func (p *provider) SearchISRC(isrc string) (*services.SearchTrack, bool, error) {
+	entry, found, err := SearchISRC(isrc)
+
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	return toSearchTrack(entry), true, nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	userId, err := GetUserId(sessionId)
+
+	if err != nil {
+		return "", err
+	}
+
+	return CreatePlaylist(name, userId, sessionId)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	uris := make([]string, 0, len(tracks))
+
+	for _, track := range tracks {
+		uris = append(uris, track.Id)
+	}
+
+	return AddTracksToPlaylistByUris(playlistId, uris, sessionId)
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	return "https://open.spotify.com/playlist/" + id
+}
+
+// track ids are spotify uris e.g spotify:track:{id}
+func (p *provider) TrackURL(id string) string {
+	return "https://open.spotify.com/track/" + strings.TrimPrefix(id, "spotify:track:")
+}
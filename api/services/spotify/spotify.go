@@ -8,8 +8,6 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/oauth2"
@@ -35,12 +33,15 @@ type tokenResponse struct {
 }
 
 type Track struct {
-	Album    shared_types.Album `json:"album"`
-	Artists  shared_types.Artists
-	Duration int    `json:"duration_ms"`
-	IsLocal  bool   `json:"is_local"`
-	Name     string `json:"name"`
-	Uri      string `json:"uri"`
+	Album       shared_types.Album `json:"album"`
+	Artists     shared_types.Artists
+	Duration    int    `json:"duration_ms"`
+	IsLocal     bool   `json:"is_local"`
+	Name        string `json:"name"`
+	Uri         string `json:"uri"`
+	ExternalIds struct {
+		ISRC string `json:"isrc"`
+	} `json:"external_ids"`
 }
 
 type Artist shared_types.Artist
@@ -227,89 +228,142 @@ func FindPlaylist(id string) (*PlaylistResponse, error) {
 		return &bodyData, nil
 	}
 
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
 	return nil, errors.New("error verifying playlist | status code: " + fmt.Sprint(status))
 }
 
-func GetPlaylistTracks(id string) ([]*Item, error) {
-	cli := fiber.Client{}
+/*
+fetches the playlist tracks page by page until all tracks or the allowed number of conversions are fetched
 
-	token, _ := getClientToken()
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string) (tracks []*Item, truncated bool, err error) {
+	cli := fiber.Client{}
 
-	allowedNumberOfConversions, intConvErr := strconv.Atoi(os.Getenv("ALLOWED_NUMBER_OF_CONVERSIONS"))
+	token, tokenErr := getClientToken()
 
-	if intConvErr != nil {
-		log.Println(intConvErr)
-		return nil, intConvErr
+	if tokenErr != nil {
+		return nil, false, tokenErr
 	}
 
-	res := cli.Get(spotifyBaseURL+"/playlists/"+id+"/tracks?limit=50&fields=total,limit,next,offset,previous,items(track(name,is_local,duration_ms,album(album_type,name),artists(name)))").
-		Set("Authorization", "Bearer "+token).Debug()
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
 
-	var bodyData PlaylistTracksResponse
-	_, _, errs := res.Struct(&bodyData)
-
-	if errs != nil {
-		log.Panic(errs)
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
 	}
 
-	tracks := bodyData.Items
+	tracks = []*Item{}
+	next := spotifyBaseURL + "/playlists/" + id + "/tracks?limit=100&fields=total,limit,next,offset,previous,items(track(name,uri,is_local,duration_ms,external_ids(isrc),album(album_type,name),artists(name)))"
 
-	// TODO: update this implementation to properly fetch more tracks, possibly using a while loop
+	// the next url returned by spotify keeps the fields filter
+	for next != "" {
+		res := cli.Get(next).Set("Authorization", "Bearer "+token).Debug()
 
-	// if there are more tracks fetch them
-	if (len(tracks) < allowedNumberOfConversions) && (len(tracks) < bodyData.Total) {
-		_, _, errs = cli.Get(bodyData.Next).Set("Authorization", "Bearer "+token).Debug().
-			Struct(&bodyData)
+		var bodyData PlaylistTracksResponse
+		status, _, errs := res.Struct(&bodyData)
 
 		if errs != nil {
-			log.Panic(errs)
+			return nil, false, errs[0]
+		}
+
+		if status != http.StatusOK {
+			return nil, false, errors.New("error getting playlist tracks | status code: " + fmt.Sprint(status))
 		}
 
 		tracks = append(tracks, bodyData.Items...)
+		next = bodyData.Next
 
-	}
+		// allowedNumberOfConversions = 0 means convert all tracks
+		if allowedNumberOfConversions != 0 && len(tracks) >= allowedNumberOfConversions {
+			truncated = len(tracks) > allowedNumberOfConversions || next != ""
+			tracks = tracks[0:allowedNumberOfConversions]
 
-	// allowedNumberOfConversions = 0 means convert all tracks
-	if allowedNumberOfConversions != 0 {
-		// check so we don't go out of range
-		if allowedNumberOfConversions > len(tracks) {
-			allowedNumberOfConversions = len(tracks)
+			break
 		}
-
-		tracks = tracks[0:allowedNumberOfConversions]
 	}
 
-	return tracks, nil
+	return tracks, truncated, nil
 }
 
 func ToSearchTrackList(tracks []*Item) services.SearchTrackList {
 	searchTrackList := make(services.SearchTrackList, 0, len(tracks))
 
 	for _, track := range tracks {
-		t := services.SearchTrack{
-			Title:    track.Track.Name,
-			Artists:  track.Track.Artists,
-			Album:    track.Track.Album,
-			Duration: int64(track.Track.Duration),
-		}
-
-		searchTrackList = append(searchTrackList, &t)
+		searchTrackList = append(searchTrackList, toSearchTrack(&track.Track))
 	}
 
 	return searchTrackList
 }
 
-func SearchTrack(query string, artist string) (*Track, bool, error) {
+func toSearchTrack(track *Track) *services.SearchTrack {
+	return &services.SearchTrack{
+		Id:       track.Uri,
+		Title:    track.Name,
+		Artists:  track.Artists,
+		Album:    track.Album,
+		Duration: int64(track.Duration),
+		ISRC:     track.ExternalIds.ISRC,
+	}
+}
+
+type SearchResponse struct {
+	Tracks struct {
+		Items []*Track `json:"items"`
+	} `json:"tracks"`
+}
+
+// searches spotify tracks and returns at most limit results in the order spotify ranks them
+func SearchTracks(query string, artist string, limit int) ([]*Track, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	q := "track:" + query
+	if artist != "" {
+		q += " artist:" + artist
+	}
+
+	makeQuery := url.QueryEscape(q)
+
+	res := cli.Get(spotifyBaseURL+"/search?q="+makeQuery+"&type=track&limit="+fmt.Sprint(limit)).
+		Set("Authorization", "Bearer "+token).Debug()
+
+	var bodyData SearchResponse
+
+	status, _, errs := res.Struct(&bodyData)
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status == http.StatusOK {
+		return bodyData.Tracks.Items, nil
+	}
+
+	return nil, errors.New("error searching track | status code: " + fmt.Sprint(status))
+}
+
+// searches a track by its isrc code
+func SearchISRC(isrc string) (*Track, bool, error) {
 	cli := fiber.Client{}
 
-	token, _ := getClientToken()
+	token, tokenErr := getClientToken()
 
-	makeQuery := url.QueryEscape("track:" + query + " artist:" + artist)
+	if tokenErr != nil {
+		return nil, false, tokenErr
+	}
 
-	res := cli.Get(spotifyBaseURL+"/search?q="+makeQuery+"&type=track&limit=1").
+	res := cli.Get(spotifyBaseURL+"/search?q="+url.QueryEscape("isrc:"+isrc)+"&type=track&limit=1").
 		Set("Authorization", "Bearer "+token).Debug()
 
-	var bodyData PlaylistTracksResponse
+	var bodyData SearchResponse
 
 	status, _, errs := res.Struct(&bodyData)
 	if errs != nil {
@@ -317,20 +371,24 @@ func SearchTrack(query string, artist string) (*Track, bool, error) {
 	}
 
 	if status == http.StatusOK {
-		if len(bodyData.Items) > 0 {
-			return &bodyData.Items[0].Track, true, nil
+		if len(bodyData.Tracks.Items) > 0 {
+			return bodyData.Tracks.Items[0], true, nil
 		}
 
-		return nil, false, errors.New("track not found")
+		return nil, false, nil
 	}
 
-	return nil, false, errors.New("error searching track | status code: " + fmt.Sprint(status))
+	return nil, false, errors.New("error searching isrc | status code: " + fmt.Sprint(status))
 }
 
-func CreatePlaylist(name string, userId string, sessionId string) (id string, err []error) {
+func CreatePlaylist(name string, userId string, sessionId string) (string, error) {
 	cli := fiber.Client{}
 
-	token, _ := getAuthCodeToken(sessionId)
+	token, tokenErr := getAuthCodeToken(sessionId)
+
+	if tokenErr != nil {
+		return "", tokenErr
+	}
 
 	body := map[string]string{
 		"name": name,
@@ -348,63 +406,51 @@ func CreatePlaylist(name string, userId string, sessionId string) (id string, er
 	status, _, errs := res.Struct(&bodyData)
 
 	if errs != nil {
-		log.Panic(errs)
-		return id, err
+		return "", errs[0]
 	}
 
-	if status == http.StatusCreated {
+	if status == http.StatusCreated || status == http.StatusOK {
 		return bodyData.Id, nil
 	}
 
-	return id, errs
+	return "", errors.New("error creating playlist | status code: " + fmt.Sprint(status))
 }
 
-func AddTracksToPlaylist(playlistId string, tracks services.SearchTrackList, sessionId string) error {
-	uris := []string{}
-
-	for _, track := range tracks {
-		artist := ""
-		if len(track.Artists) > 0 {
-			artist = track.Artists[0].Name
-		}
-
-		entry, _, err := SearchTrack(track.Title, artist)
-		if err != nil {
-			log.Println("error searching track: ", err)
-
-			continue
-		}
-
-		if entry != nil {
-			uris = append(uris, entry.Uri)
-		}
-	}
-
-	return AddTracksToPlaylistByUris(playlistId, uris, sessionId)
-}
+// spotify accepts a maximum of 100 uris per request
+const maxUrisPerRequest = 100
 
 func AddTracksToPlaylistByUris(playlistId string, uris []string, sessionId string) error {
 	cli := fiber.Client{}
 
-	token, _ := getAuthCodeToken(sessionId)
+	token, tokenErr := getAuthCodeToken(sessionId)
 
-	body := map[string]string{
-		"uris": strings.Join(uris, ","),
+	if tokenErr != nil {
+		return tokenErr
 	}
 
-	res := cli.Post(spotifyBaseURL+"/playlists/"+playlistId+"/tracks").Set("Authorization", "Bearer "+token).
-		JSON(body).Debug()
+	for start := 0; start < len(uris); start += maxUrisPerRequest {
+		end := start + maxUrisPerRequest
+		if end > len(uris) {
+			end = len(uris)
+		}
 
-	status, _, errs := res.Bytes()
+		body := map[string][]string{
+			"uris": uris[start:end],
+		}
 
-	if errs != nil {
-		log.Panic(errs)
-		return errs[0]
-	}
+		res := cli.Post(spotifyBaseURL+"/playlists/"+playlistId+"/tracks").Set("Authorization", "Bearer "+token).
+			JSON(body).Debug()
 
-	if status == http.StatusCreated {
-		return nil
+		status, _, errs := res.Bytes()
+
+		if errs != nil {
+			return errs[0]
+		}
+
+		if status != http.StatusCreated && status != http.StatusOK {
+			return errors.New("error adding tracks to playlist | status code: " + fmt.Sprint(status))
+		}
 	}
 
-	return errors.New("error adding tracks to playlist | status code: " + fmt.Sprint(status))
+	return nil
 }
@@ -0,0 +1,87 @@
+package subsonic
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+)
+
+// the salted token never expires on the server, the session login is kept for a month
+const credentialsLifetime = 30 * 24 * time.Hour
+
+var (
+	ErrCredentialsNotFound = errors.New("subsonic credentials not found, log in to the Subsonic server first")
+	ErrWrongCredentials    = errors.New("wrong subsonic username or password")
+)
+
+/*
+returns the auth query parameters of the subsonic token authentication,
+t is md5(password + s) with a new random salt s so the password is never sent
+*/
+func authParams(username string, password string) (url.Values, error) {
+	b := make([]byte, 8)
+
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	salt := hex.EncodeToString(b)
+	sum := md5.Sum([]byte(password + salt))
+
+	params := url.Values{}
+	params.Set("u", username)
+	params.Set("t", hex.EncodeToString(sum[:]))
+	params.Set("s", salt)
+
+	return params, nil
+}
+
+/*
+checks the credentials against the server and stores the salted token for the session,
+the password itself is not stored
+*/
+func Login(username string, password string, sessionId string) error {
+	params, err := authParams(username, password)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ping(params); err != nil {
+		if isApiError(err, errWrongCredentials) {
+			return ErrWrongCredentials
+		}
+
+		return err
+	}
+
+	tokenstore.GlobalTokenStore.SetToken(sessionId+"_"+string(tokenstore.SUBSONIC_UC), tokenstore.TokenEntry{
+		Token: &oauth2.Token{
+			AccessToken: params.Encode(),
+		},
+		Expiration: time.Now().Add(credentialsLifetime),
+	})
+
+	return nil
+}
+
+/*
+returns the session credentials, playlists are only read and created with the account the session logged in with,
+the server account from the environment is never used in place of it
+*/
+func getAuthParams(sessionId string) (url.Values, error) {
+	token, tokenValid := tokenstore.GlobalTokenStore.GetToken(sessionId + "_" + string(tokenstore.SUBSONIC_UC))
+
+	if !tokenValid {
+		return nil, ErrCredentialsNotFound
+	}
+
+	return url.ParseQuery(token.AccessToken)
+}
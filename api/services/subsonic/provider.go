@@ -0,0 +1,107 @@
+package subsonic
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "subsonic"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "Subsonic"
+}
+
+// the self hosted server configured with SUBSONIC_BASE_URL
+func (p *provider) Hosts() []string {
+	u, err := url.Parse(baseURL)
+
+	if err != nil || u.Host == "" {
+		return []string{}
+	}
+
+	return []string{u.Host}
+}
+
+/*
+expects navidrome urls in the format {base}/app/#/playlist/{id}/show
+or subsonic api urls in the format {base}/rest/getPlaylist?id={id}
+*/
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	if id := u.Query().Get("id"); id != "" && strings.HasSuffix(strings.TrimSuffix(u.Path, ".view"), "/getPlaylist") {
+		return id, nil
+	}
+
+	fragmentParts := strings.Split(strings.Trim(u.Fragment, "/"), "/")
+
+	if len(fragmentParts) < 2 || fragmentParts[0] != "playlist" || fragmentParts[1] == "" {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	return fragmentParts[1], nil
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := FindPlaylist(id, sessionId)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         playlist.Id,
+		Title:      playlist.Name,
+		Url:        p.PlaylistURL(playlist.Id),
+		TrackCount: playlist.SongCount,
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	songs, truncated, err := GetPlaylistTracks(id, sessionId)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(songs), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	songs, err := SearchTracks(track.Title, track.MainArtist(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSearchTrackList(songs), nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name, sessionId)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	ids := make([]string, 0, len(tracks))
+
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+
+	return AddTracksToPlaylist(playlistId, ids, sessionId)
+}
+
+// navidrome web ui url, other subsonic servers ignore the fragment and open their home page
+func (p *provider) PlaylistURL(id string) string {
+	return baseURL + "/app/#/playlist/" + url.PathEscape(id) + "/show"
+}
@@ -0,0 +1,345 @@
+package subsonic
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/initializers"
+)
+
+const (
+	apiVersion = "1.16.1"
+	clientName = "music-playlist-converter"
+)
+
+// number of songs added with a single updatePlaylist request
+const addBatchSize = 100
+
+// subsonic error codes
+const (
+	errWrongCredentials = 40
+	errNotFound         = 70
+)
+
+var (
+	// e.g https://music.example.com, the rest endpoints live under /rest
+	baseURL string
+	// server account only used to search the library, playlists need the session login
+	username string
+	password string
+)
+
+func init() {
+	initializers.LoadEnv()
+
+	baseURL = strings.TrimSuffix(os.Getenv("SUBSONIC_BASE_URL"), "/")
+	username = os.Getenv("SUBSONIC_USERNAME")
+	password = os.Getenv("SUBSONIC_PASSWORD")
+}
+
+type ApiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ApiError) Error() string {
+	return "subsonic error " + fmt.Sprint(e.Code) + ": " + e.Message
+}
+
+type Song struct {
+	Id     string `json:"id"`
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	// seconds
+	Duration int64 `json:"duration"`
+	// OpenSubsonic servers like navidrome list every artist and the isrc codes
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+	ISRC []string `json:"isrc"`
+}
+
+type Playlist struct {
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	Owner     string  `json:"owner"`
+	Public    bool    `json:"public"`
+	SongCount int     `json:"songCount"`
+	Entry     []*Song `json:"entry"`
+}
+
+type response struct {
+	Status    string    `json:"status"`
+	Error     *ApiError `json:"error"`
+	Playlists struct {
+		Playlist []*Playlist `json:"playlist"`
+	} `json:"playlists"`
+	Playlist      *Playlist `json:"playlist"`
+	SearchResult3 struct {
+		Song []*Song `json:"song"`
+	} `json:"searchResult3"`
+}
+
+// sends a request to a subsonic endpoint, the api error is returned when the response status is "failed"
+func request(endpoint string, params url.Values, auth url.Values) (*response, error) {
+	if baseURL == "" {
+		return nil, errors.New("SUBSONIC_BASE_URL is not set")
+	}
+
+	cli := fiber.Client{}
+
+	query := url.Values{}
+	for key, values := range auth {
+		query[key] = values
+	}
+
+	query.Set("v", apiVersion)
+	query.Set("c", clientName)
+	query.Set("f", "json")
+
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	for key, values := range params {
+		for _, value := range values {
+			args.Add(key, value)
+		}
+	}
+
+	// parameters are posted as a form so long song id lists don't hit url length limits
+	res := cli.Post(baseURL + "/rest/" + endpoint + "?" + query.Encode()).
+		Form(args)
+
+	var bodyData struct {
+		Response response `json:"subsonic-response"`
+	}
+
+	status, b, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		log.Println("subsonic "+endpoint+" : ", string(b))
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.New("error calling subsonic " + endpoint + " | status code: " + fmt.Sprint(status))
+	}
+
+	if bodyData.Response.Status != "ok" {
+		if bodyData.Response.Error != nil {
+			return nil, bodyData.Response.Error
+		}
+
+		return nil, errors.New("error calling subsonic " + endpoint + " | status: " + bodyData.Response.Status)
+	}
+
+	return &bodyData.Response, nil
+}
+
+func isApiError(err error, code int) bool {
+	var apiErr *ApiError
+
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+func ping(auth url.Values) error {
+	_, err := request("ping", nil, auth)
+
+	return err
+}
+
+// returns the playlists the session user can read, own playlists and public playlists of other users
+func GetPlaylists(sessionId string) ([]*Playlist, error) {
+	auth, err := getAuthParams(sessionId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := request("getPlaylists", nil, auth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Playlists.Playlist, nil
+}
+
+// returns the playlist with its songs, nil when it doesn't exist
+func FindPlaylist(id string, sessionId string) (*Playlist, error) {
+	auth, err := getAuthParams(sessionId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := request("getPlaylist", url.Values{"id": {id}}, auth)
+
+	if isApiError(err, errNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Playlist, nil
+}
+
+/*
+returns the playlist songs, getPlaylist returns every song at once so the list is only cut to the allowed number of conversions
+
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string, sessionId string) (songs []*Song, truncated bool, err error) {
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	playlist, err := FindPlaylist(id, sessionId)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if playlist == nil {
+		return nil, false, errors.New("subsonic playlist " + id + " not found")
+	}
+
+	songs = playlist.Entry
+
+	// allowedNumberOfConversions = 0 means convert all tracks
+	if allowedNumberOfConversions != 0 && len(songs) > allowedNumberOfConversions {
+		songs = songs[0:allowedNumberOfConversions]
+		truncated = true
+	}
+
+	return songs, truncated, nil
+}
+
+func ToSearchTrackList(songs []*Song) services.SearchTrackList {
+	searchTrackList := make(services.SearchTrackList, 0, len(songs))
+
+	for _, song := range songs {
+		searchTrackList = append(searchTrackList, toSearchTrack(song))
+	}
+
+	return searchTrackList
+}
+
+func toSearchTrack(song *Song) *services.SearchTrack {
+	artists := make(shared_types.Artists, 0, len(song.Artists))
+	for _, artist := range song.Artists {
+		artists = append(artists, shared_types.Artist{Name: artist.Name})
+	}
+
+	if len(artists) == 0 && song.Artist != "" {
+		artists = append(artists, shared_types.Artist{Name: song.Artist})
+	}
+
+	track := &services.SearchTrack{
+		Id:       song.Id,
+		Title:    song.Title,
+		Artists:  artists,
+		Duration: song.Duration * 1000,
+		Album:    shared_types.Album{Name: song.Album},
+	}
+
+	if len(song.ISRC) > 0 {
+		track.ISRC = song.ISRC[0]
+	}
+
+	return track
+}
+
+// searches the server library with the server account and returns at most limit songs
+func SearchTracks(query string, artist string, limit int) ([]*Song, error) {
+	if username == "" {
+		return nil, errors.New("SUBSONIC_USERNAME is not set, searching the subsonic library needs the server account")
+	}
+
+	auth, err := authParams(username, password)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := query
+	if artist != "" {
+		q += " " + artist
+	}
+
+	res, err := request("search3", url.Values{
+		"query":       {q},
+		"songCount":   {fmt.Sprint(limit)},
+		"artistCount": {"0"},
+		"albumCount":  {"0"},
+	}, auth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.SearchResult3.Song, nil
+}
+
+// creates an empty playlist owned by the session user and returns its id
+func CreatePlaylist(name string, sessionId string) (string, error) {
+	auth, err := getAuthParams(sessionId)
+
+	if err != nil {
+		return "", err
+	}
+
+	res, err := request("createPlaylist", url.Values{"name": {name}}, auth)
+
+	if err != nil {
+		return "", err
+	}
+
+	// servers older than api version 1.14.0 don't return the created playlist
+	if res.Playlist == nil || res.Playlist.Id == "" {
+		return "", errors.New("subsonic server didn't return the created playlist")
+	}
+
+	return res.Playlist.Id, nil
+}
+
+func AddTracksToPlaylist(playlistId string, songIds []string, sessionId string) error {
+	auth, err := getAuthParams(sessionId)
+
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(songIds); start += addBatchSize {
+		end := start + addBatchSize
+		if end > len(songIds) {
+			end = len(songIds)
+		}
+
+		_, err := request("updatePlaylist", url.Values{
+			"playlistId":  {playlistId},
+			"songIdToAdd": songIds[start:end],
+		}, auth)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
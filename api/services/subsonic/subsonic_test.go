@@ -0,0 +1,260 @@
+package subsonic
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fake subsonic server with a single user and playlist, answering like navidrome
+type fakeServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	users     map[string]string
+	playlists map[string]*Playlist
+	// songIdToAdd values of every updatePlaylist request
+	updates [][]string
+	// user authenticated for each endpoint call
+	callers map[string]string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{
+		users:     map[string]string{"alice": "secret", "server": "server-password"},
+		playlists: map[string]*Playlist{},
+		callers:   map[string]string{},
+	}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.Close)
+
+	prevBaseURL, prevUsername, prevPassword := baseURL, username, password
+	baseURL, username, password = f.URL, "server", "server-password"
+
+	t.Cleanup(func() {
+		baseURL, username, password = prevBaseURL, prevUsername, prevPassword
+	})
+
+	return f
+}
+
+func (f *fakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	endpoint := strings.TrimPrefix(r.URL.Path, "/rest/")
+
+	if r.Method != http.MethodPost || query.Get("f") != "json" || query.Get("v") == "" || query.Get("c") == "" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := query.Get("u")
+	password, found := f.users[user]
+	sum := md5.Sum([]byte(password + query.Get("s")))
+
+	// the password is never sent, only its salted token
+	if !found || query.Get("p") != "" || query.Get("t") != hex.EncodeToString(sum[:]) {
+		writeFakeResponse(w, map[string]any{"status": "failed", "error": map[string]any{"code": errWrongCredentials, "message": "Wrong username or password"}})
+		return
+	}
+
+	f.callers[endpoint] = user
+
+	switch endpoint {
+	case "ping":
+		writeFakeResponse(w, map[string]any{"status": "ok"})
+
+	case "getPlaylist":
+		playlist, found := f.playlists[r.PostForm.Get("id")]
+
+		if !found {
+			writeFakeResponse(w, map[string]any{"status": "failed", "error": map[string]any{"code": errNotFound, "message": "Playlist not found"}})
+			return
+		}
+
+		writeFakeResponse(w, map[string]any{"status": "ok", "playlist": playlist})
+
+	case "createPlaylist":
+		playlist := &Playlist{Id: fmt.Sprint(len(f.playlists) + 1), Name: r.PostForm.Get("name"), Owner: user}
+		f.playlists[playlist.Id] = playlist
+
+		writeFakeResponse(w, map[string]any{"status": "ok", "playlist": playlist})
+
+	case "updatePlaylist":
+		f.updates = append(f.updates, r.PostForm["songIdToAdd"])
+
+		writeFakeResponse(w, map[string]any{"status": "ok"})
+
+	case "search3":
+		writeFakeResponse(w, map[string]any{"status": "ok", "searchResult3": map[string]any{
+			"song": []*Song{{Id: "s1", Title: r.PostForm.Get("query"), Artist: "Artist", Duration: 200, ISRC: []string{"GBAYE0601498"}}},
+		}})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeFakeResponse(w http.ResponseWriter, body map[string]any) {
+	body["version"] = apiVersion
+
+	json.NewEncoder(w).Encode(map[string]any{"subsonic-response": body})
+}
+
+func TestLogin(t *testing.T) {
+	newFakeServer(t)
+
+	tests := []struct {
+		name      string
+		username  string
+		password  string
+		sessionId string
+		wantErr   error
+	}{
+		{name: "right credentials", username: "alice", password: "secret", sessionId: "login-ok"},
+		{name: "wrong password", username: "alice", password: "wrong", sessionId: "login-wrong-password", wantErr: ErrWrongCredentials},
+		{name: "unknown user", username: "bob", password: "secret", sessionId: "login-unknown-user", wantErr: ErrWrongCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Login(tt.username, tt.password, tt.sessionId)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+
+			auth, err := getAuthParams(tt.sessionId)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, ErrCredentialsNotFound) {
+					t.Errorf("getAuthParams() error = %v, want ErrCredentialsNotFound after a failed login", err)
+				}
+
+				return
+			}
+
+			if err != nil || auth.Get("u") != tt.username || auth.Get("t") == "" || auth.Has("p") {
+				t.Errorf("getAuthParams() = %v, %v, want the salted token of %s", auth, err, tt.username)
+			}
+		})
+	}
+}
+
+func TestPlaylistsNeedTheSessionLogin(t *testing.T) {
+	f := newFakeServer(t)
+	f.playlists["1"] = &Playlist{Id: "1", Name: "Server playlist", Owner: "server"}
+
+	if _, err := FindPlaylist("1", "no-login"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("FindPlaylist() error = %v, want ErrCredentialsNotFound", err)
+	}
+
+	if _, err := CreatePlaylist("Mine", "no-login"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("CreatePlaylist() error = %v, want ErrCredentialsNotFound", err)
+	}
+
+	if len(f.callers) != 0 {
+		t.Errorf("server was called with %v, want no calls without a session login", f.callers)
+	}
+}
+
+func TestFindPlaylist(t *testing.T) {
+	f := newFakeServer(t)
+	f.playlists["1"] = &Playlist{Id: "1", Name: "Mix", Entry: []*Song{{Id: "s1", Title: "Song"}}}
+
+	if err := Login("alice", "secret", "find-playlist"); err != nil {
+		t.Fatal(err)
+	}
+
+	playlist, err := FindPlaylist("1", "find-playlist")
+
+	if err != nil || playlist == nil || playlist.Name != "Mix" || len(playlist.Entry) != 1 {
+		t.Errorf("FindPlaylist() = %+v, %v, want the Mix playlist", playlist, err)
+	}
+
+	if f.callers["getPlaylist"] != "alice" {
+		t.Errorf("getPlaylist was called by %q, want the session user", f.callers["getPlaylist"])
+	}
+
+	playlist, err = FindPlaylist("404", "find-playlist")
+
+	if err != nil || playlist != nil {
+		t.Errorf("FindPlaylist() = %+v, %v, want nil for a missing playlist", playlist, err)
+	}
+}
+
+func TestCreatePlaylistAndAddTracks(t *testing.T) {
+	f := newFakeServer(t)
+
+	if err := Login("alice", "secret", "create-playlist"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := CreatePlaylist("Converted", "create-playlist")
+
+	if err != nil || f.playlists[id] == nil || f.playlists[id].Owner != "alice" {
+		t.Fatalf("CreatePlaylist() = %q, %v, want a playlist owned by the session user", id, err)
+	}
+
+	songIds := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		songIds = append(songIds, fmt.Sprint(i))
+	}
+
+	if err := AddTracksToPlaylist(id, songIds, "create-playlist"); err != nil {
+		t.Fatal(err)
+	}
+
+	batches := []int{}
+	added := []string{}
+
+	for _, update := range f.updates {
+		batches = append(batches, len(update))
+		added = append(added, update...)
+	}
+
+	if fmt.Sprint(batches) != "[100 100 50]" || strings.Join(added, ",") != strings.Join(songIds, ",") {
+		t.Errorf("songs were added in batches %v, want [100 100 50] in order", batches)
+	}
+}
+
+func TestSearchTracksUsesTheServerAccount(t *testing.T) {
+	f := newFakeServer(t)
+
+	songs, err := SearchTracks("Song", "Artist", 5)
+
+	if err != nil || len(songs) != 1 || songs[0].Title != "Song Artist" {
+		t.Fatalf("SearchTracks() = %+v, %v, want the song of the query", songs, err)
+	}
+
+	if f.callers["search3"] != "server" {
+		t.Errorf("search3 was called by %q, want the server account", f.callers["search3"])
+	}
+
+	track := toSearchTrack(songs[0])
+
+	if track.Duration != 200000 || track.ISRC != "GBAYE0601498" || len(track.Artists) != 1 || track.Artists[0].Name != "Artist" {
+		t.Errorf("toSearchTrack() = %+v", track)
+	}
+
+	username = ""
+
+	if _, err := SearchTracks("Song", "", 5); err == nil {
+		t.Error("SearchTracks() without the server account didn't fail")
+	}
+}
@@ -0,0 +1,210 @@
+package tidal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+)
+
+const (
+	deviceAuthorizationURL = "https://auth.tidal.com/v1/oauth2/device_authorization"
+	tokenURL               = "https://auth.tidal.com/v1/oauth2/token"
+	deviceCodeGrantType    = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+var (
+	ErrAuthorizationPending = errors.New("tidal authorization pending")
+	ErrSlowDown             = errors.New("tidal authorization polled too often")
+	ErrDeviceCodeExpired    = errors.New("tidal device code expired, start the login again")
+	ErrAccessDenied         = errors.New("tidal authorization denied")
+)
+
+// user facing part of the device login, the user enters UserCode at VerificationUri
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"deviceCode"`
+	UserCode                string `json:"userCode"`
+	VerificationUri         string `json:"verificationUri"`
+	VerificationUriComplete string `json:"verificationUriComplete"`
+	// seconds
+	ExpiresIn int `json:"expiresIn"`
+	// seconds to wait between two polls
+	Interval int `json:"interval"`
+}
+
+var OauthConfig *oauth2.Config
+var clientCredentialsConfig *clientcredentials.Config
+
+func initAuth() {
+	endpoint := oauth2.Endpoint{
+		TokenURL:  tokenURL,
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+
+	// only used to refresh device login tokens, there is no redirect
+	OauthConfig = &oauth2.Config{
+		ClientID:     clientId,
+		ClientSecret: clientSecret,
+		Endpoint:     endpoint,
+		Scopes:       []string{"r_usr", "w_usr"},
+	}
+
+	clientCredentialsConfig = &clientcredentials.Config{
+		ClientID:     clientId,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL,
+		AuthStyle:    oauth2.AuthStyleInParams,
+	}
+}
+
+/*
+starts the OAuth device authorization grant (RFC 8628)
+
+golang.org/x/oauth2 v0.8.0 has no device flow, the requests are made by hand
+*/
+func StartDeviceAuthorization() (*DeviceAuthorization, error) {
+	cli := fiber.Client{}
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	args.Set("client_id", clientId)
+	args.Set("scope", "r_usr w_usr")
+
+	var bodyData DeviceAuthorization
+
+	status, _, errs := cli.Post(deviceAuthorizationURL).Form(args).Struct(&bodyData)
+
+	// the response holds the device code, only the status is logged
+	if errs != nil {
+		log.Println("tidal device authorization | status code: " + fmt.Sprint(status))
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK || bodyData.DeviceCode == "" {
+		return nil, errors.New("error starting tidal device authorization | status code: " + fmt.Sprint(status))
+	}
+
+	return &bodyData, nil
+}
+
+/*
+polls the token endpoint once for the device code
+
+returns ErrAuthorizationPending until the user approved the login, callers must wait the interval
+returned by StartDeviceAuthorization between two polls
+*/
+func PollDeviceToken(deviceCode string) (*oauth2.Token, error) {
+	cli := fiber.Client{}
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	args.Set("client_id", clientId)
+	args.Set("client_secret", clientSecret)
+	args.Set("device_code", deviceCode)
+	args.Set("grant_type", deviceCodeGrantType)
+	args.Set("scope", "r_usr w_usr")
+
+	var bodyData struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+		Error        string `json:"error"`
+	}
+
+	status, _, errs := cli.Post(tokenURL).Form(args).Struct(&bodyData)
+
+	// the response holds the tokens, only the status is logged
+	if errs != nil {
+		log.Println("tidal device token | status code: " + fmt.Sprint(status))
+		return nil, errs[0]
+	}
+
+	switch bodyData.Error {
+	case "":
+	case "authorization_pending":
+		return nil, ErrAuthorizationPending
+	case "slow_down":
+		return nil, ErrSlowDown
+	case "expired_token":
+		return nil, ErrDeviceCodeExpired
+	case "access_denied":
+		return nil, ErrAccessDenied
+	default:
+		return nil, errors.New("tidal device token error: " + bodyData.Error)
+	}
+
+	if status != http.StatusOK || bodyData.AccessToken == "" {
+		return nil, errors.New("error getting tidal device token | status code: " + fmt.Sprint(status))
+	}
+
+	return &oauth2.Token{
+		AccessToken:  bodyData.AccessToken,
+		RefreshToken: bodyData.RefreshToken,
+		TokenType:    bodyData.TokenType,
+		Expiry:       time.Now().Add(time.Duration(bodyData.ExpiresIn) * time.Second),
+	}, nil
+}
+
+func StoreAuthCodeToken(token *oauth2.Token, sessionId string) {
+	prefix := sessionId + "_"
+
+	tokenstore.GlobalTokenStore.SetToken(prefix+string(tokenstore.TIDAL_AC), tokenstore.TokenEntry{
+		Token:      token,
+		Expiration: token.Expiry,
+	})
+}
+
+func getAuthCodeToken(sessionId string) (string, error) {
+	token, tokenValid := tokenstore.GlobalTokenStore.GetToken(sessionId + "_" + string(tokenstore.TIDAL_AC))
+
+	if tokenValid {
+		return token.AccessToken, nil
+	}
+
+	if token != nil {
+		// refresh token
+		ts := OauthConfig.TokenSource(context.Background(), token)
+		token, err := ts.Token()
+
+		if err != nil {
+			return "", err
+		}
+
+		StoreAuthCodeToken(token, sessionId)
+
+		return token.AccessToken, nil
+	}
+
+	return "", errors.New("tidal token not found, log in to TIDAL first")
+}
+
+// catalog requests use a client credentials token
+func getClientToken() (string, error) {
+	token, tokenValid := tokenstore.GlobalTokenStore.GetToken(string(tokenstore.TIDAL_CC))
+
+	if tokenValid {
+		return token.AccessToken, nil
+	}
+
+	token, err := clientCredentialsConfig.Token(context.Background())
+
+	if err != nil {
+		return "", err
+	}
+
+	tokenstore.GlobalTokenStore.SetToken(string(tokenstore.TIDAL_CC), tokenstore.TokenEntry{
+		Token:      token,
+		Expiration: token.Expiry,
+	})
+
+	return token.AccessToken, nil
+}
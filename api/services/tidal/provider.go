@@ -0,0 +1,116 @@
+package tidal
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "tidal"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "TIDAL"
+}
+
+func (p *provider) Hosts() []string {
+	return []string{"tidal.com", "www.tidal.com", "listen.tidal.com"}
+}
+
+/*
+expects urls in the format https://tidal.com/browse/playlist/{uuid},
+https://listen.tidal.com/playlist/{uuid} is accepted too
+*/
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	if len(pathParts) > 0 && pathParts[0] == "browse" {
+		pathParts = pathParts[1:]
+	}
+
+	if len(pathParts) < 2 || pathParts[0] != "playlist" || !uuidPattern.MatchString(pathParts[1]) {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	return strings.ToLower(pathParts[1]), nil
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	playlist, err := FindPlaylist(id)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         playlist.UUID,
+		Title:      playlist.Title,
+		Url:        p.PlaylistURL(playlist.UUID),
+		TrackCount: playlist.NumberOfTracks,
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	tracks, truncated, err := GetPlaylistTracks(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(tracks), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	tracks, err := SearchTracks(track.Title, track.MainArtist(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSearchTrackList(tracks), nil
+}
+
+func (p *provider) SearchISRC(isrc string) (*services.SearchTrack, bool, error) {
+	track, found, err := SearchISRC(isrc)
+
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	return toSearchTrack(track), true, nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name, sessionId)
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	ids := make([]string, 0, len(tracks))
+
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+
+	return AddTracksToPlaylist(playlistId, ids, sessionId)
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	return "https://tidal.com/browse/playlist/" + id
+}
+
+func (p *provider) TrackURL(id string) string {
+	return "https://tidal.com/browse/track/" + id
+}
@@ -0,0 +1,397 @@
+package tidal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/initializers"
+)
+
+var tidalBaseURL = "https://api.tidal.com/v1"
+
+// tidal returns at most 100 items per page
+const pageSize = 100
+
+var (
+	clientId     string
+	clientSecret string
+	// catalog requests are scoped to a country
+	countryCode = "US"
+)
+
+func init() {
+	initializers.LoadEnv()
+
+	clientId = os.Getenv("TIDAL_CLIENT_ID")
+	clientSecret = os.Getenv("TIDAL_CLIENT_SECRET")
+
+	if code := os.Getenv("TIDAL_COUNTRY_CODE"); code != "" {
+		countryCode = code
+	}
+
+	initAuth()
+}
+
+type Track struct {
+	Id    int64  `json:"id"`
+	Title string `json:"title"`
+	// e.g "Remastered", not part of the title
+	Version  string `json:"version"`
+	Duration int64  `json:"duration"`
+	ISRC     string `json:"isrc"`
+	Artists  []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+	Album struct {
+		Title string `json:"title"`
+	} `json:"album"`
+}
+
+type TracksResponse struct {
+	Limit              int      `json:"limit"`
+	Offset             int      `json:"offset"`
+	TotalNumberOfItems int      `json:"totalNumberOfItems"`
+	Items              []*Track `json:"items"`
+}
+
+type PlaylistResponse struct {
+	UUID           string `json:"uuid"`
+	Title          string `json:"title"`
+	NumberOfTracks int    `json:"numberOfTracks"`
+}
+
+type sessionResponse struct {
+	UserId      int64  `json:"userId"`
+	CountryCode string `json:"countryCode"`
+}
+
+// verify if tidal playlist exists
+func FindPlaylist(id string) (*PlaylistResponse, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	res := cli.Get(tidalBaseURL+"/playlists/"+url.PathEscape(id)+"?countryCode="+countryCode).
+		Set("Authorization", "Bearer "+token)
+
+	var bodyData PlaylistResponse
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status == http.StatusOK {
+		return &bodyData, nil
+	}
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	return nil, errors.New("error verifying playlist | status code: " + fmt.Sprint(status))
+}
+
+/*
+fetches the playlist tracks page by page until all tracks or the allowed number of conversions are fetched
+
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func GetPlaylistTracks(id string) (tracks []*Track, truncated bool, err error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, false, tokenErr
+	}
+
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	tracks = []*Track{}
+
+	for offset := 0; ; offset += pageSize {
+		res := cli.Get(tidalBaseURL+"/playlists/"+url.PathEscape(id)+"/tracks?countryCode="+countryCode+
+			"&limit="+fmt.Sprint(pageSize)+"&offset="+fmt.Sprint(offset)).
+			Set("Authorization", "Bearer "+token)
+
+		var bodyData TracksResponse
+		status, _, errs := res.Struct(&bodyData)
+
+		if errs != nil {
+			return nil, false, errs[0]
+		}
+
+		if status != http.StatusOK {
+			return nil, false, errors.New("error getting playlist tracks | status code: " + fmt.Sprint(status))
+		}
+
+		tracks = append(tracks, bodyData.Items...)
+		hasMore := len(bodyData.Items) > 0 && offset+len(bodyData.Items) < bodyData.TotalNumberOfItems
+
+		// allowedNumberOfConversions = 0 means convert all tracks
+		if allowedNumberOfConversions != 0 && len(tracks) >= allowedNumberOfConversions {
+			truncated = len(tracks) > allowedNumberOfConversions || hasMore
+			tracks = tracks[0:allowedNumberOfConversions]
+
+			break
+		}
+
+		if !hasMore {
+			break
+		}
+	}
+
+	return tracks, truncated, nil
+}
+
+func ToSearchTrackList(tracks []*Track) services.SearchTrackList {
+	searchTrackList := make(services.SearchTrackList, 0, len(tracks))
+
+	for _, track := range tracks {
+		searchTrackList = append(searchTrackList, toSearchTrack(track))
+	}
+
+	return searchTrackList
+}
+
+func toSearchTrack(track *Track) *services.SearchTrack {
+	title := track.Title
+	if track.Version != "" {
+		title += " (" + track.Version + ")"
+	}
+
+	artists := make(shared_types.Artists, 0, len(track.Artists))
+	for _, artist := range track.Artists {
+		artists = append(artists, shared_types.Artist{Name: artist.Name})
+	}
+
+	return &services.SearchTrack{
+		Id:       fmt.Sprint(track.Id),
+		Title:    title,
+		Artists:  artists,
+		Duration: track.Duration * 1000,
+		Album:    shared_types.Album{Name: track.Album.Title},
+		ISRC:     track.ISRC,
+	}
+}
+
+// searches tidal tracks and returns at most limit results in the order tidal ranks them
+func SearchTracks(query string, artist string, limit int) ([]*Track, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	q := query
+	if artist != "" {
+		q += " " + artist
+	}
+
+	res := cli.Get(tidalBaseURL+"/search/tracks?countryCode="+countryCode+"&limit="+fmt.Sprint(limit)+"&query="+url.QueryEscape(q)).
+		Set("Authorization", "Bearer "+token)
+
+	var bodyData TracksResponse
+
+	status, _, errs := res.Struct(&bodyData)
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status == http.StatusOK {
+		return bodyData.Items, nil
+	}
+
+	return nil, errors.New("error searching track | status code: " + fmt.Sprint(status))
+}
+
+// looks up a track by its isrc code
+func SearchISRC(isrc string) (*Track, bool, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getClientToken()
+
+	if tokenErr != nil {
+		return nil, false, tokenErr
+	}
+
+	res := cli.Get(tidalBaseURL+"/tracks?countryCode="+countryCode+"&isrc="+url.QueryEscape(isrc)).
+		Set("Authorization", "Bearer "+token)
+
+	var bodyData TracksResponse
+
+	status, _, errs := res.Struct(&bodyData)
+	if errs != nil {
+		return nil, false, errs[0]
+	}
+
+	if status == http.StatusOK {
+		for _, track := range bodyData.Items {
+			if strings.EqualFold(track.ISRC, isrc) {
+				return track, true, nil
+			}
+		}
+
+		return nil, false, nil
+	}
+
+	if status == http.StatusNotFound {
+		return nil, false, nil
+	}
+
+	return nil, false, errors.New("error searching isrc | status code: " + fmt.Sprint(status))
+}
+
+func getSession(token string) (*sessionResponse, error) {
+	cli := fiber.Client{}
+
+	res := cli.Get(tidalBaseURL+"/sessions").
+		Set("Authorization", "Bearer "+token)
+
+	var bodyData sessionResponse
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.New("error getting tidal session | status code: " + fmt.Sprint(status))
+	}
+
+	return &bodyData, nil
+}
+
+// creates a playlist owned by the logged in user and returns its uuid
+func CreatePlaylist(name string, sessionId string) (string, error) {
+	cli := fiber.Client{}
+
+	token, tokenErr := getAuthCodeToken(sessionId)
+
+	if tokenErr != nil {
+		return "", tokenErr
+	}
+
+	session, err := getSession(token)
+
+	if err != nil {
+		return "", err
+	}
+
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	args.Set("title", name)
+	args.Set("description", "")
+
+	res := cli.Post(tidalBaseURL+"/users/"+fmt.Sprint(session.UserId)+"/playlists?countryCode="+session.CountryCode).
+		Set("Authorization", "Bearer "+token).
+		Form(args)
+
+	var bodyData PlaylistResponse
+
+	status, _, errs := res.Struct(&bodyData)
+
+	if errs != nil {
+		return "", errs[0]
+	}
+
+	if (status == http.StatusCreated || status == http.StatusOK) && bodyData.UUID != "" {
+		return bodyData.UUID, nil
+	}
+
+	return "", errors.New("error creating playlist | status code: " + fmt.Sprint(status))
+}
+
+// tidal rejects playlist changes without the current ETag of the playlist
+func playlistETag(cli *fiber.Client, playlistId string, token string) (string, error) {
+	res := fiber.AcquireResponse()
+	defer fiber.ReleaseResponse(res)
+
+	agent := cli.Get(tidalBaseURL+"/playlists/"+url.PathEscape(playlistId)+"?countryCode="+countryCode).
+		Set("Authorization", "Bearer "+token)
+	agent.SetResponse(res)
+
+	status, _, errs := agent.Bytes()
+
+	if errs != nil {
+		return "", errs[0]
+	}
+
+	if status != http.StatusOK {
+		return "", errors.New("error getting playlist etag | status code: " + fmt.Sprint(status))
+	}
+
+	return string(res.Header.Peek(fiber.HeaderETag)), nil
+}
+
+func AddTracksToPlaylist(playlistId string, trackIds []string, sessionId string) error {
+	cli := fiber.Client{}
+
+	token, tokenErr := getAuthCodeToken(sessionId)
+
+	if tokenErr != nil {
+		return tokenErr
+	}
+
+	for start := 0; start < len(trackIds); start += pageSize {
+		end := start + pageSize
+		if end > len(trackIds) {
+			end = len(trackIds)
+		}
+
+		etag, err := playlistETag(&cli, playlistId, token)
+
+		if err != nil {
+			return err
+		}
+
+		args := fiber.AcquireArgs()
+		args.Set("trackIds", strings.Join(trackIds[start:end], ","))
+		args.Set("onDupes", "ADD")
+		args.Set("onArtifactNotFound", "SKIP")
+
+		res := cli.Post(tidalBaseURL+"/playlists/"+url.PathEscape(playlistId)+"/items?countryCode="+countryCode).
+			Set("Authorization", "Bearer "+token).
+			Set(fiber.HeaderIfNoneMatch, etag).
+			Form(args)
+
+		status, b, errs := res.Bytes()
+		fiber.ReleaseArgs(args)
+
+		if errs != nil {
+			return errs[0]
+		}
+
+		if status != http.StatusOK && status != http.StatusCreated {
+			log.Println("tidal add tracks : ", string(b))
+
+			return errors.New("error adding tracks to playlist | status code: " + fmt.Sprint(status))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package tracklist
+
+// parses tracklists pasted from radio shows and DJ mix descriptions, one track per line
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+)
+
+type Line struct {
+	Track *services.SearchTrack
+	// position of the track in the mix, -1 when the line has no timestamp
+	Offset time.Duration
+	// trailing [...] of the line, usually the record label
+	Label string
+}
+
+var (
+	// "00:12:30", "12:30", "[12:30]", "(1:02:03)" followed by an optional separator
+	timestampPattern = regexp.MustCompile(`^[\[(]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\])]?(?:\s*[-–—|.:]\s*|\s+)`)
+	// "01.", "1)", "[01]", "#1", "01 - " but not the number starting "50 Cent - In Da Club"
+	trackNumberPattern = regexp.MustCompile(`^(?:#?\d{1,3}[.)]|\[\d{1,3}\]|#\d{1,3}|\d{1,3}\s+[-–—])\s*`)
+	labelPattern       = regexp.MustCompile(`\s*\[([^\[\]]*)\]$`)
+	// dashes surrounded by spaces, hyphens inside names like "Jay-Z" don't separate
+	separatorPattern = regexp.MustCompile(`\s+(?:-{1,2}|–|—|‒)\s+`)
+	// artists featured or credited together
+	artistSeparatorPattern = regexp.MustCompile(`(?i)\s+(?:feat\.?|ft\.?|featuring|vs\.?|x|&)\s+|\s*,\s*`)
+	// placeholders for unreleased tracks in DJ tracklists
+	unknownTitles = []string{"id", "?", "??", "???", "unknown"}
+)
+
+// returns the tracks of the lines in the shape "Artist - Title", lines that aren't tracks are skipped
+func Parse(text string) services.SearchTrackList {
+	tracks := services.SearchTrackList{}
+
+	for _, line := range ParseLines(text) {
+		tracks = append(tracks, line.Track)
+	}
+
+	return tracks
+}
+
+// returns the tracks of the lines starting with a timestamp, e.g the tracklist in the description of a mix
+func ParseTimestamped(text string) services.SearchTrackList {
+	tracks := services.SearchTrackList{}
+
+	for _, line := range ParseLines(text) {
+		if line.Offset >= 0 {
+			tracks = append(tracks, line.Track)
+		}
+	}
+
+	return tracks
+}
+
+/*
+parses lines like "01. Artist - Title", "Artist – Title [Label]", "00:12:30 Artist - Title" and "Title by Artist",
+lines without an artist and title, and unknown "ID - ID" tracks are skipped
+*/
+func ParseLines(text string) []*Line {
+	lines := []*Line{}
+
+	for _, raw := range strings.Split(text, "\n") {
+		if line := parseLine(raw); line != nil {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func parseLine(raw string) *Line {
+	text := strings.TrimSpace(raw)
+	line := &Line{Offset: -1}
+
+	// numbers and timestamps come in either order e.g "01. [00:00] Artist - Title"
+	for i := 0; i < 2; i++ {
+		if match := timestampPattern.FindStringSubmatch(text); match != nil && line.Offset < 0 {
+			line.Offset = parseTimestamp(match[1])
+			text = strings.TrimSpace(text[len(match[0]):])
+		}
+
+		if match := trackNumberPattern.FindString(text); match != "" {
+			text = strings.TrimSpace(text[len(match):])
+		}
+	}
+
+	if match := labelPattern.FindStringSubmatch(text); match != nil {
+		line.Label = strings.TrimSpace(match[1])
+		text = strings.TrimSpace(text[:len(text)-len(match[0])])
+	}
+
+	artist, title, found := splitArtistTitle(text)
+
+	if !found || isUnknown(title) {
+		return nil
+	}
+
+	track := &services.SearchTrack{Title: title, Artists: shared_types.Artists{}}
+
+	for _, name := range artistSeparatorPattern.Split(artist, -1) {
+		if name = trimQuotes(name); name != "" && !isUnknown(name) {
+			track.Artists = append(track.Artists, shared_types.Artist{Name: name})
+		}
+	}
+
+	line.Track = track
+
+	return line
+}
+
+func splitArtistTitle(text string) (artist string, title string, found bool) {
+	if location := separatorPattern.FindStringIndex(text); location != nil {
+		artist = trimQuotes(text[:location[0]])
+		title = trimQuotes(text[location[1]:])
+
+		return artist, title, artist != "" && title != ""
+	}
+
+	// "Title by Artist"
+	if i := strings.LastIndex(strings.ToLower(text), " by "); i > 0 {
+		title = trimQuotes(text[:i])
+		artist = trimQuotes(text[i+len(" by "):])
+
+		return artist, title, artist != "" && title != ""
+	}
+
+	return "", "", false
+}
+
+// "1:02:03" and "12:30"
+func parseTimestamp(value string) time.Duration {
+	seconds := 0
+
+	for _, part := range strings.Split(value, ":") {
+		n, _ := strconv.Atoi(part)
+		seconds = seconds*60 + n
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+func isUnknown(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	for _, unknown := range unknownTitles {
+		if value == unknown {
+			return true
+		}
+	}
+
+	return false
+}
+
+func trimQuotes(value string) string {
+	return strings.Trim(strings.TrimSpace(value), `"'“”‘’`)
+}
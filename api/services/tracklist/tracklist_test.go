@@ -0,0 +1,84 @@
+package tracklist
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		skipped bool
+		artists []string
+		title   string
+		offset  time.Duration
+		label   string
+	}{
+		{name: "artist and title", line: "Daft Punk - One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: -1},
+		{name: "numbered", line: "01. Daft Punk - One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: -1},
+		{name: "numbered with a parenthesis", line: "12) Daft Punk – One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: -1},
+		{name: "bracketed number", line: "[03] Daft Punk — One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: -1},
+		{name: "number followed by a dash", line: "07 - Daft Punk - One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: -1},
+		{name: "artist starting with a number", line: "50 Cent - In Da Club", artists: []string{"50 Cent"}, title: "In Da Club", offset: -1},
+		{name: "timestamp", line: "00:12:30 Daft Punk - One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: 12*time.Minute + 30*time.Second},
+		{name: "bracketed timestamp", line: "[1:02:03] Daft Punk - One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: time.Hour + 2*time.Minute + 3*time.Second},
+		{name: "timestamp with a separator", line: "12:30 | Daft Punk - One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: 12*time.Minute + 30*time.Second},
+		{name: "number then timestamp", line: "01. [00:00] Daft Punk - One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: 0},
+		{name: "timestamp then number", line: "(05:00) 02. Daft Punk - One More Time", artists: []string{"Daft Punk"}, title: "One More Time", offset: 5 * time.Minute},
+		{name: "label", line: "Daft Punk - One More Time [Virgin]", artists: []string{"Daft Punk"}, title: "One More Time", offset: -1, label: "Virgin"},
+		{name: "hyphenated artist", line: "Jay-Z - Empire State of Mind", artists: []string{"Jay-Z"}, title: "Empire State of Mind", offset: -1},
+		{name: "featured artists", line: "Daft Punk feat. Pharrell Williams & Nile Rodgers - Get Lucky", artists: []string{"Daft Punk", "Pharrell Williams", "Nile Rodgers"}, title: "Get Lucky", offset: -1},
+		{name: "artists separated by commas and vs", line: "A, B vs C x D - Title", artists: []string{"A", "B", "C", "D"}, title: "Title", offset: -1},
+		{name: "title by artist", line: "\"One More Time\" by Daft Punk", artists: []string{"Daft Punk"}, title: "One More Time", offset: -1},
+		{name: "unknown artist is left out", line: "ID - Some Title", artists: []string{}, title: "Some Title", offset: -1},
+		{name: "unknown track", line: "00:45:00 ID - ID", skipped: true},
+		{name: "unknown title", line: "Daft Punk - ???", skipped: true},
+		{name: "no separator", line: "Tracklist:", skipped: true},
+		{name: "missing title", line: "Daft Punk - ", skipped: true},
+		{name: "empty line", line: "   ", skipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := parseLine(tt.line)
+
+			if tt.skipped {
+				if line != nil {
+					t.Errorf("parseLine(%q) = %+v, want the line skipped", tt.line, line.Track)
+				}
+
+				return
+			}
+
+			if line == nil {
+				t.Fatalf("parseLine(%q) skipped the line", tt.line)
+			}
+
+			artists := []string{}
+			for _, artist := range line.Track.Artists {
+				artists = append(artists, artist.Name)
+			}
+
+			if !reflect.DeepEqual(artists, tt.artists) || line.Track.Title != tt.title || line.Offset != tt.offset || line.Label != tt.label {
+				t.Errorf("parseLine(%q) = %v %q %v %q, want %v %q %v %q",
+					tt.line, artists, line.Track.Title, line.Offset, line.Label, tt.artists, tt.title, tt.offset, tt.label)
+			}
+		})
+	}
+}
+
+func TestParseTimestamped(t *testing.T) {
+	text := "Tracklist:\r\n00:00 Artist One - First\r\nArtist Two - Untimed\r\n\r\n05:30 Artist Three - Second\n"
+
+	tracks := ParseTimestamped(text)
+
+	if len(tracks) != 2 || tracks[0].Title != "First" || tracks[1].Title != "Second" {
+		t.Errorf("ParseTimestamped() = %v, want the two timestamped tracks", tracks)
+	}
+
+	if all := Parse(text); len(all) != 3 {
+		t.Errorf("Parse() returned %d tracks, want 3", len(all))
+	}
+}
@@ -0,0 +1,143 @@
+package upload
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/formats"
+)
+
+var (
+	ErrUnsupportedLibrary = errors.New("unsupported library file format")
+	ErrLibraryNotFound    = errors.New("library not found")
+	ErrPlaylistNotFound   = errors.New("library playlist not found")
+)
+
+// an uploaded file holding several playlists, one of them is picked to be converted
+type Library struct {
+	Id        string
+	SessionId string
+	FileName  string
+	Format    string
+	Playlists []*LibraryPlaylist
+	CreatedAt time.Time
+}
+
+type LibraryPlaylist struct {
+	Id     string                   `json:"id"`
+	Title  string                   `json:"title"`
+	Tracks services.SearchTrackList `json:"tracks"`
+}
+
+func (s *Store) SaveLibrary(library *Library) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.persister != nil {
+		return s.persister.SaveLibrary(library)
+	}
+
+	s.libraries[library.Id] = library
+
+	return nil
+}
+
+// returns nil if the library does not exist
+func (s *Store) GetLibrary(id string) (*Library, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.persister != nil {
+		library, err := s.persister.GetLibrary(id)
+
+		if errors.Is(err, ErrLibraryNotFound) {
+			return nil, nil
+		}
+
+		return library, err
+	}
+
+	return s.libraries[id], nil
+}
+
+// reads a library file of the session, the format is detected from the file name and its first bytes
+func ParseLibrary(fileName string, r io.Reader, sessionId string) (*Library, error) {
+	br := bufio.NewReaderSize(r, formats.LibraryHeadSize)
+	head, _ := br.Peek(formats.LibraryHeadSize)
+
+	format, ok := formats.DetectLibraryFormat(fileName, head)
+
+	if !ok {
+		return nil, ErrUnsupportedLibrary
+	}
+
+	// the whole upload is passed when it can be read again, e.g so zip archives are read from the file
+	var decodeReader io.Reader = br
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		if _, err := seeker.Seek(0, io.SeekStart); err == nil {
+			decodeReader = seeker
+		}
+	}
+
+	playlists, err := format.Decode(fileName, decodeReader)
+
+	if err != nil {
+		log.Println("error decoding " + format.Name() + " library - " + err.Error())
+		return nil, err
+	}
+
+	library := &Library{
+		Id:        utils.UUIDv4(),
+		SessionId: sessionId,
+		FileName:  fileName,
+		Format:    format.Name(),
+		Playlists: []*LibraryPlaylist{},
+		CreatedAt: time.Now(),
+	}
+
+	// the urls of all playlists are looked up together, so the lookups are bounded per library
+	trackLists := make([]services.SearchTrackList, len(playlists))
+	for i, playlist := range playlists {
+		trackLists[i] = entryTracks(playlist)
+	}
+
+	lookupTrackURLs(trackLists...)
+
+	for i, playlist := range playlists {
+		library.Playlists = append(library.Playlists, &LibraryPlaylist{
+			Id:     playlist.Id,
+			Title:  playlist.Title,
+			Tracks: titledTracks(playlist, trackLists[i]),
+		})
+	}
+
+	return library, nil
+}
+
+// returns nil if the library has no playlist with the id
+func (l *Library) Playlist(id string) *LibraryPlaylist {
+	for _, playlist := range l.Playlists {
+		if playlist.Id == id {
+			return playlist
+		}
+	}
+
+	return nil
+}
+
+// makes an upload of a playlist of the library, so it can be converted like an uploaded playlist file
+func (l *Library) Upload(playlistId string) (*Upload, error) {
+	playlist := l.Playlist(playlistId)
+
+	if playlist == nil {
+		return nil, ErrPlaylistNotFound
+	}
+
+	return tracksUpload(l.FileName, l.Format, playlist.Title, playlist.Tracks, l.SessionId)
+}
@@ -0,0 +1,116 @@
+package upload
+
+import (
+	"errors"
+	"log"
+	"net/url"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+)
+
+const ProviderName = "upload"
+
+var ErrSourceOnly = errors.New("uploaded playlists can only be converted from")
+
+// uploads of other sessions are not found
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "Uploaded file"
+}
+
+// uploads have no playlist urls
+func (p *provider) Hosts() []string {
+	return []string{}
+}
+
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	return "", services.ErrInvalidPlaylistURL
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	upload, err := p.sessionUpload(id, sessionId)
+
+	if err != nil || upload == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         upload.Id,
+		Title:      upload.Title,
+		Url:        p.PlaylistURL(upload.Id),
+		TrackCount: len(upload.Tracks),
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (tracks services.SearchTrackList, truncated bool, err error) {
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	upload, err := p.sessionUpload(id, sessionId)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if upload == nil {
+		return nil, false, ErrUploadNotFound
+	}
+
+	tracks = upload.Tracks
+
+	// allowedNumberOfConversions = 0 means convert all tracks
+	if allowedNumberOfConversions != 0 && len(tracks) > allowedNumberOfConversions {
+		tracks = tracks[0:allowedNumberOfConversions]
+		truncated = true
+	}
+
+	return tracks, truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	return nil, ErrSourceOnly
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return "", ErrSourceOnly
+}
+
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	return ErrSourceOnly
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	return PlaylistURL(id)
+}
+
+// track ids are the locations or identifiers of the playlist file entries
+func (p *provider) TrackURL(id string) string {
+	return id
+}
+
+func (p *provider) SourceOnly() bool {
+	return true
+}
+
+func (p *provider) sessionUpload(id string, sessionId string) (*Upload, error) {
+	upload, err := GlobalStore.Get(id)
+
+	if err != nil || upload == nil || upload.SessionId != sessionId {
+		return nil, err
+	}
+
+	return upload, nil
+}
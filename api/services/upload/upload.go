@@ -0,0 +1,384 @@
+package upload
+
+// playlist files uploaded as the source of a conversion
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/formats"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported playlist file format")
+	ErrNoTracks          = errors.New("playlist file has no tracks")
+	ErrUploadNotFound    = errors.New("upload not found")
+)
+
+var GlobalStore = NewStore()
+
+const (
+	// most urls of untitled tracks looked up per uploaded file
+	maxTrackURLLookups    = 1000
+	trackURLLookupBatch   = 50
+	trackURLLookupWorkers = 4
+)
+
+var trackURLLookupTimeout = 20 * time.Second
+
+type Upload struct {
+	Id        string
+	SessionId string
+	FileName  string
+	Format    string
+	Title     string
+	Tracks    services.SearchTrackList
+	CreatedAt time.Time
+}
+
+type Store struct {
+	uploads   map[string]*Upload
+	libraries map[string]*Library
+	mutex     sync.Mutex
+	persister Persister
+}
+
+// keeps uploads across restarts so their conversions can resume
+type Persister interface {
+	SaveUpload(upload *Upload) error
+	// returns ErrUploadNotFound if the upload does not exist
+	GetUpload(id string) (*Upload, error)
+	SaveLibrary(library *Library) error
+	// returns ErrLibraryNotFound if the library does not exist
+	GetLibrary(id string) (*Library, error)
+	// deletes the uploads created before the time, except the ones keep returns true for
+	DeleteUploadsBefore(before time.Time, keep func(id string) bool) error
+	DeleteLibrariesBefore(before time.Time) error
+}
+
+func NewStore() *Store {
+	return &Store{uploads: map[string]*Upload{}, libraries: map[string]*Library{}}
+}
+
+// uploads are kept by the persister from now on instead of in memory
+func (s *Store) UsePersister(p Persister) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.persister = p
+}
+
+func (s *Store) Save(upload *Upload) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.persister != nil {
+		return s.persister.SaveUpload(upload)
+	}
+
+	s.uploads[upload.Id] = upload
+
+	return nil
+}
+
+// returns nil if the upload does not exist
+func (s *Store) Get(id string) (*Upload, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.persister != nil {
+		upload, err := s.persister.GetUpload(id)
+
+		if errors.Is(err, ErrUploadNotFound) {
+			return nil, nil
+		}
+
+		return upload, err
+	}
+
+	return s.uploads[id], nil
+}
+
+// deletes the uploads and libraries created before the time, uploads keep returns true for are kept e.g while converted
+func (s *Store) Sweep(before time.Time, keep func(id string) bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.persister != nil {
+		if err := s.persister.DeleteUploadsBefore(before, keep); err != nil {
+			return err
+		}
+
+		return s.persister.DeleteLibrariesBefore(before)
+	}
+
+	for id, upload := range s.uploads {
+		if upload.CreatedAt.Before(before) && !keep(id) {
+			delete(s.uploads, id)
+		}
+	}
+
+	for id, library := range s.libraries {
+		if library.CreatedAt.Before(before) {
+			delete(s.libraries, id)
+		}
+	}
+
+	return nil
+}
+
+// uploads have no web url, the session playlist url of an upload is "upload:{id}"
+func PlaylistURL(id string) string {
+	return "upload:" + id
+}
+
+/*
+reads a playlist file of the session, the format is picked by the file extension.
+
+entries without a title are named after their file name, entries left without a title are skipped
+*/
+func Parse(fileName string, r io.Reader, sessionId string) (*Upload, error) {
+	format, ok := formats.FormatOfFile(fileName)
+
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	playlist, err := format.Decode(r)
+
+	if err != nil {
+		log.Println("error decoding " + format.Name() + " playlist - " + err.Error())
+		return nil, err
+	}
+
+	return newUpload(fileName, format.Name(), playlist, sessionId)
+}
+
+// reads a csv file of the session with the columns confirmed by the user
+func ParseCSV(fileName string, r io.Reader, columns *formats.CSVColumns, sessionId string) (*Upload, error) {
+	playlist, err := formats.DecodeCSV(r, columns)
+
+	if err != nil {
+		log.Println("error decoding csv playlist - " + err.Error())
+		return nil, err
+	}
+
+	return newUpload(fileName, "csv", playlist, sessionId)
+}
+
+func newUpload(fileName string, format string, playlist *formats.Playlist, sessionId string) (*Upload, error) {
+	title := playlist.Title
+	if title == "" {
+		_, title = formats.FileNameTags(fileName)
+	}
+
+	return tracksUpload(fileName, format, title, playlistTracks(playlist), sessionId)
+}
+
+// makes an upload of tracks that weren't read from a playlist file e.g a pasted tracklist
+func FromTracks(title string, format string, tracks services.SearchTrackList, sessionId string) (*Upload, error) {
+	return tracksUpload("", format, title, tracks, sessionId)
+}
+
+func tracksUpload(fileName string, format string, title string, tracks services.SearchTrackList, sessionId string) (*Upload, error) {
+	if len(tracks) == 0 {
+		return nil, ErrNoTracks
+	}
+
+	return &Upload{
+		Id:        utils.UUIDv4(),
+		SessionId: sessionId,
+		FileName:  fileName,
+		Format:    format,
+		Title:     title,
+		Tracks:    tracks,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// entries left without a title are skipped
+func playlistTracks(playlist *formats.Playlist) services.SearchTrackList {
+	tracks := entryTracks(playlist)
+
+	lookupTrackURLs(tracks)
+
+	return titledTracks(playlist, tracks)
+}
+
+func entryTracks(playlist *formats.Playlist) services.SearchTrackList {
+	tracks := make(services.SearchTrackList, len(playlist.Entries))
+
+	for i, entry := range playlist.Entries {
+		tracks[i] = entryTrack(entry)
+	}
+
+	return tracks
+}
+
+// names the tracks still untitled after their file name, the ones left without a title are skipped
+func titledTracks(playlist *formats.Playlist, entryTracks services.SearchTrackList) services.SearchTrackList {
+	tracks := services.SearchTrackList{}
+
+	for i, track := range entryTracks {
+		if track.Title == "" && playlist.Entries[i].Location != "" {
+			fileArtist, fileTitle := formats.FileNameTags(playlist.Entries[i].Location)
+			track.Title = fileTitle
+
+			if len(track.Artists) == 0 && fileArtist != "" {
+				track.Artists = append(track.Artists, shared_types.Artist{Name: fileArtist})
+			}
+		}
+
+		if track.Title != "" {
+			tracks = append(tracks, track)
+		}
+	}
+
+	return tracks
+}
+
+// the track id is the location of the entry, or its identifier for entries without a location
+func entryTrack(entry *formats.Entry) *services.SearchTrack {
+	id := entry.Location
+	if id == "" {
+		id = entry.Identifier
+	}
+
+	track := &services.SearchTrack{
+		Id:       id,
+		Title:    strings.TrimSpace(entry.Title),
+		Artists:  shared_types.Artists{},
+		Duration: entry.Duration,
+		Album:    shared_types.Album{Name: entry.Album},
+		ISRC:     entry.ISRC,
+	}
+
+	if artist := strings.TrimSpace(entry.Artist); artist != "" {
+		track.Artists = append(track.Artists, shared_types.Artist{Name: artist})
+	}
+
+	return track
+}
+
+type urlBatch struct {
+	resolver services.TrackURLResolver
+	urls     []string
+}
+
+/*
+names untitled tracks located by urls of providers that can look them up e.g the videos of a Google Takeout playlist,
+found tracks replace the untitled ones but keep their url as id.
+
+lookups hold the upload request so they are bounded: repeated urls are looked up once, at most maxTrackURLLookups urls
+are looked up in parallel batches until trackURLLookupTimeout. tracks that weren't looked up are left untitled
+*/
+func lookupTrackURLs(trackLists ...services.SearchTrackList) {
+	ctx, cancel := context.WithTimeout(context.Background(), trackURLLookupTimeout)
+	defer cancel()
+
+	untitled := map[string][]*services.SearchTrack{}
+	urlsByProvider := map[string][]string{}
+	resolvers := map[string]services.TrackURLResolver{}
+
+	for _, tracks := range trackLists {
+		for _, track := range tracks {
+			if track.Title != "" {
+				continue
+			}
+
+			if _, seen := untitled[track.Id]; seen {
+				untitled[track.Id] = append(untitled[track.Id], track)
+				continue
+			}
+
+			if len(untitled) == maxTrackURLLookups {
+				continue
+			}
+
+			u, err := url.Parse(track.Id)
+			if err != nil || u.Host == "" {
+				continue
+			}
+
+			provider, found := services.FindProviderByHost(u.Host)
+			if !found {
+				continue
+			}
+
+			if resolver, ok := provider.(services.TrackURLResolver); ok {
+				resolvers[provider.Name()] = resolver
+				urlsByProvider[provider.Name()] = append(urlsByProvider[provider.Name()], track.Id)
+				untitled[track.Id] = []*services.SearchTrack{track}
+			}
+		}
+	}
+
+	batches := []urlBatch{}
+
+	for name, urls := range urlsByProvider {
+		for start := 0; start < len(urls); start += trackURLLookupBatch {
+			end := start + trackURLLookupBatch
+			if end > len(urls) {
+				end = len(urls)
+			}
+
+			batches = append(batches, urlBatch{resolver: resolvers[name], urls: urls[start:end]})
+		}
+	}
+
+	queue := make(chan urlBatch)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < trackURLLookupWorkers && w < len(batches); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for batch := range queue {
+				found, err := batch.resolver.LookupTrackURLs(ctx, batch.urls)
+
+				if err != nil {
+					log.Println("lookupTrackURLs error - " + err.Error())
+
+					continue
+				}
+
+				// each url is in a single batch, so its tracks are only named by this worker
+				for i, rawURL := range batch.urls {
+					if i >= len(found) || found[i] == nil || found[i].Title == "" {
+						continue
+					}
+
+					for _, track := range untitled[rawURL] {
+						*track = *found[i]
+						track.Id = rawURL
+					}
+				}
+			}
+		}()
+	}
+
+queueing:
+	for _, batch := range batches {
+		select {
+		case queue <- batch:
+		case <-ctx.Done():
+			log.Println("lookupTrackURLs timed out, the urls left are named after their file name")
+			break queueing
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+}
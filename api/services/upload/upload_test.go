@@ -0,0 +1,185 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/formats"
+)
+
+const lookupHost = "lookup.test"
+
+// names the tracks of its urls unless lookup is set, the urls it was asked for are kept in order
+type lookupProvider struct {
+	mutex  sync.Mutex
+	urls   []string
+	lookup func(ctx context.Context, urls []string) (services.SearchTrackList, error)
+}
+
+var testLookupProvider = &lookupProvider{}
+
+func (p *lookupProvider) Name() string                                  { return "test-lookup" }
+func (p *lookupProvider) DisplayName() string                           { return "Lookup" }
+func (p *lookupProvider) Hosts() []string                               { return []string{lookupHost} }
+func (p *lookupProvider) ResolvePlaylistURL(u *url.URL) (string, error) { return "", nil }
+func (p *lookupProvider) PlaylistURL(id string) string                  { return id }
+
+func (p *lookupProvider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	return nil, nil
+}
+
+func (p *lookupProvider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	return nil, false, nil
+}
+
+func (p *lookupProvider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	return nil, nil
+}
+
+func (p *lookupProvider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return "", nil
+}
+
+func (p *lookupProvider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	return nil
+}
+
+func (p *lookupProvider) LookupTrackURLs(ctx context.Context, urls []string) (services.SearchTrackList, error) {
+	p.mutex.Lock()
+	p.urls = append(p.urls, urls...)
+	lookup := p.lookup
+	p.mutex.Unlock()
+
+	if lookup != nil {
+		return lookup(ctx, urls)
+	}
+
+	tracks := make(services.SearchTrackList, len(urls))
+	for i, u := range urls {
+		tracks[i] = &services.SearchTrack{Id: "found", Title: "Found " + u}
+	}
+
+	return tracks, nil
+}
+
+func init() {
+	services.RegisterProvider(testLookupProvider)
+}
+
+// resets the provider and returns the urls it is asked for
+func useLookup(t *testing.T, lookup func(ctx context.Context, urls []string) (services.SearchTrackList, error)) func() []string {
+	t.Helper()
+
+	testLookupProvider.mutex.Lock()
+	testLookupProvider.urls = nil
+	testLookupProvider.lookup = lookup
+	testLookupProvider.mutex.Unlock()
+
+	return func() []string {
+		testLookupProvider.mutex.Lock()
+		defer testLookupProvider.mutex.Unlock()
+
+		return testLookupProvider.urls
+	}
+}
+
+func lookupURL(i int) string {
+	return fmt.Sprintf("https://%s/watch?v=%d", lookupHost, i)
+}
+
+func TestPlaylistTracksLooksUpRepeatedURLsOnce(t *testing.T) {
+	lookedUp := useLookup(t, nil)
+
+	tracks := playlistTracks(&formats.Playlist{Entries: []*formats.Entry{
+		{Location: lookupURL(1)},
+		{Location: lookupURL(2)},
+		{Location: lookupURL(1)},
+		{Location: lookupURL(3), Title: "Titled"},
+	}})
+
+	if got := lookedUp(); len(got) != 2 {
+		t.Fatalf("looked up %v, want the 2 untitled urls once", got)
+	}
+
+	want := []string{"Found " + lookupURL(1), "Found " + lookupURL(2), "Found " + lookupURL(1), "Titled"}
+	wantIds := []string{lookupURL(1), lookupURL(2), lookupURL(1), lookupURL(3)}
+
+	if len(tracks) != len(want) {
+		t.Fatalf("got %d tracks, want %d", len(tracks), len(want))
+	}
+
+	for i, track := range tracks {
+		if track.Title != want[i] {
+			t.Errorf("track %d title = %q, want %q", i, track.Title, want[i])
+		}
+
+		if track.Id != wantIds[i] {
+			t.Errorf("track %d id = %q, want %q", i, track.Id, wantIds[i])
+		}
+	}
+
+	if tracks[0] == tracks[2] {
+		t.Error("tracks of a repeated url share their struct")
+	}
+}
+
+func TestLookupTrackURLsIsCappedAndBatched(t *testing.T) {
+	lookedUp := useLookup(t, func(ctx context.Context, urls []string) (services.SearchTrackList, error) {
+		if len(urls) > trackURLLookupBatch {
+			t.Errorf("looked up %d urls at once, want at most %d", len(urls), trackURLLookupBatch)
+		}
+
+		return make(services.SearchTrackList, len(urls)), nil
+	})
+
+	tracks := services.SearchTrackList{}
+	for i := 0; i < maxTrackURLLookups+10; i++ {
+		tracks = append(tracks, &services.SearchTrack{Id: lookupURL(i)})
+	}
+
+	lookupTrackURLs(tracks)
+
+	if got := len(lookedUp()); got != maxTrackURLLookups {
+		t.Errorf("looked up %d urls, want %d", got, maxTrackURLLookups)
+	}
+}
+
+func TestLookupTrackURLsTimesOut(t *testing.T) {
+	timeout := trackURLLookupTimeout
+	trackURLLookupTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { trackURLLookupTimeout = timeout })
+
+	useLookup(t, func(ctx context.Context, urls []string) (services.SearchTrackList, error) {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	})
+
+	tracks := services.SearchTrackList{}
+	for i := 0; i < trackURLLookupBatch*trackURLLookupWorkers*2; i++ {
+		tracks = append(tracks, &services.SearchTrack{Id: lookupURL(i)})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		lookupTrackURLs(tracks)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookupTrackURLs didn't return after its timeout")
+	}
+
+	for i, track := range tracks {
+		if track.Title != "" {
+			t.Errorf("track %d title = %q, want it left untitled", i, track.Title)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package youtube
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/tracklist"
+)
+
+const ProviderName = "youtube"
+
+// ids of videos converted from the tracklist in their description
+const videoPrefix = "video:"
+
+type provider struct{}
+
+func init() {
+	services.RegisterProvider(&provider{})
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+func (p *provider) DisplayName() string {
+	return "YouTubeMusic"
+}
+
+func (p *provider) Hosts() []string {
+	return []string{"music.youtube.com", "www.youtube.com", "youtube.com", "m.youtube.com", "youtu.be"}
+}
+
+/*
+expects urls in the format https://music.youtube.com/playlist?list={id},
+or video urls https://www.youtube.com/watch?v={id} and https://youtu.be/{id} to convert the tracklist of the video description
+*/
+func (p *provider) ResolvePlaylistURL(u *url.URL) (string, error) {
+	if videoId := watchVideoId(u); videoId != "" {
+		return videoPrefix + videoId, nil
+	}
+
+	list := u.Query().Get("list")
+
+	if u.Path != "/playlist" || list == "" {
+		return "", services.ErrInvalidPlaylistURL
+	}
+
+	return list, nil
+}
+
+func (p *provider) FindPlaylist(id string, sessionId string) (*services.Playlist, error) {
+	if strings.HasPrefix(id, videoPrefix) {
+		return p.findVideoPlaylist(id)
+	}
+
+	playlist, err := FindPlaylist(id)
+
+	if err != nil || playlist == nil {
+		return nil, err
+	}
+
+	return &services.Playlist{
+		Id:         playlist.Id,
+		Title:      playlist.Snippet.Title,
+		Url:        p.PlaylistURL(playlist.Id),
+		TrackCount: int(playlist.ContentDetails.ItemCount),
+	}, nil
+}
+
+// the video title and the tracks of its description, videos without a tracklist are ErrEmptyPlaylist
+func (p *provider) findVideoPlaylist(id string) (*services.Playlist, error) {
+	video, err := FindVideo(strings.TrimPrefix(id, videoPrefix))
+
+	if err != nil || video == nil || video.Snippet == nil {
+		return nil, err
+	}
+
+	tracks := tracklist.ParseTimestamped(video.Snippet.Description)
+
+	if len(tracks) == 0 {
+		return nil, services.ErrEmptyPlaylist
+	}
+
+	return &services.Playlist{
+		Id:         id,
+		Title:      video.Snippet.Title,
+		Url:        p.PlaylistURL(id),
+		TrackCount: len(tracks),
+	}, nil
+}
+
+func (p *provider) GetPlaylistTracks(id string, sessionId string) (services.SearchTrackList, bool, error) {
+	if strings.HasPrefix(id, videoPrefix) {
+		return GetVideoTracks(strings.TrimPrefix(id, videoPrefix))
+	}
+
+	tracks, truncated, err := YTMusic_GetPlaylistTracks(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ToSearchTrackList(tracks), truncated, nil
+}
+
+func (p *provider) SearchTracks(track *services.SearchTrack, limit int) (services.SearchTrackList, error) {
+	entries, err := YTMusic_SearchTracks(track.Title, track.MainArtist(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSearchTrackList(entries), nil
+}
+
+func (p *provider) CreatePlaylist(name string, sessionId string) (string, error) {
+	return CreatePlaylist(name, sessionId)
+}
+
+// youtube has no batch insert, tracks are added one after the other
+func (p *provider) AddTracks(playlistId string, tracks services.SearchTrackList, sessionId string) error {
+	for _, track := range tracks {
+		err := AddTrackToPlaylist(playlistId, &Music{YoutubeId: track.Id, Title: track.Title}, sessionId)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *provider) PlaylistURL(id string) string {
+	if strings.HasPrefix(id, videoPrefix) {
+		return "https://www.youtube.com/watch?v=" + strings.TrimPrefix(id, videoPrefix)
+	}
+
+	return "https://music.youtube.com/playlist?list=" + id
+}
+
+func (p *provider) TrackURL(id string) string {
+	return "https://music.youtube.com/watch?v=" + id
+}
+
+// track ids are the video ids of watch urls, the original urls are kept as ids of the found tracks
+func (p *provider) LookupTrackURLs(ctx context.Context, urls []string) (services.SearchTrackList, error) {
+	ids := []string{}
+
+	for _, rawURL := range urls {
+		if u, err := url.Parse(rawURL); err == nil {
+			if videoId := watchVideoId(u); videoId != "" {
+				ids = append(ids, videoId)
+			}
+		}
+	}
+
+	videos, err := LookupVideos(ctx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make(services.SearchTrackList, len(urls))
+
+	for i, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+
+		if err != nil {
+			continue
+		}
+
+		if video, found := videos[watchVideoId(u)]; found {
+			tracks[i] = videoTrack(video)
+			tracks[i].Id = rawURL
+		}
+	}
+
+	return tracks, nil
+}
+
+// returns the video id of watch urls e.g https://www.youtube.com/watch?v={id} and https://youtu.be/{id}, empty for other urls
+func watchVideoId(u *url.URL) string {
+	if u.Host == "youtu.be" {
+		if videoId := strings.Trim(u.Path, "/"); !strings.Contains(videoId, "/") {
+			return videoId
+		}
+
+		return ""
+	}
+
+	if u.Path == "/watch" {
+		return u.Query().Get("v")
+	}
+
+	return ""
+}
@@ -5,7 +5,11 @@ import (
 	"errors"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gookit/goutil/arrutil"
 	"golang.org/x/oauth2"
@@ -14,6 +18,8 @@ import (
 	"google.golang.org/api/youtube/v3"
 
 	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
+	"github.com/to-dy/music-playlist-converter/api/services/tracklist"
 	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
 	"github.com/to-dy/music-playlist-converter/initializers"
 )
@@ -24,6 +30,15 @@ var OauthConfig *oauth2.Config
 
 var youtubeService *youtube.Service
 
+// most ids the videos endpoint accepts per request
+const videosPerRequest = 50
+
+var (
+	// e.g (Official Music Video), [Official Audio], (Lyrics), (Visualizer)
+	videoTitleNotesPattern = regexp.MustCompile(`(?i)\s*[(\[]\s*(official\s+)?(music\s+)?(video|audio|lyrics?(\s+video)?|visuali[sz]er|hd|hq|4k|mv)\s*[)\]]`)
+	isoDurationPattern     = regexp.MustCompile(`^P(?:(\d+)D)?T?(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+)
+
 func init() {
 	// once.Do(func() {
 	// TODO: investigate why I have to call LoadEnv to access env vars here
@@ -37,8 +52,15 @@ func init() {
 		Scopes:       []string{youtube.YoutubeForceSslScope},
 	}
 
+	// without a key the client would look for google default credentials and fail to start
+	auth := option.WithAPIKey(os.Getenv("YOUTUBE_API_KEY"))
+	if os.Getenv("YOUTUBE_API_KEY") == "" {
+		log.Println("YOUTUBE_API_KEY is not set, youtube searches will fail")
+		auth = option.WithoutAuthentication()
+	}
+
 	ctx := context.Background()
-	service, err := youtube.NewService(ctx, option.WithAPIKey(os.Getenv("YOUTUBE_API_KEY")))
+	service, err := youtube.NewService(ctx, auth)
 	youtubeService = service
 
 	if err != nil {
@@ -105,11 +127,146 @@ func FindPlaylist(id string) (*youtube.Playlist, error) {
 	}
 }
 
+// returns nil if the video does not exist
+func FindVideo(id string) (*youtube.Video, error) {
+	res, err := youtubeService.Videos.List([]string{"id", "snippet"}).Id(id).MaxResults(1).Do()
+
+	if err != nil {
+		log.Println("FindVideo error - " + err.Error())
+
+		return nil, err
+	}
+
+	for _, video := range res.Items {
+		if video.Id == id {
+			return video, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// parses the timestamped tracklist in the description of a video e.g a DJ mix
+func GetVideoTracks(id string) (tracks services.SearchTrackList, truncated bool, err error) {
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
+
+	if intConvErr != nil {
+		log.Println(intConvErr)
+		return nil, false, intConvErr
+	}
+
+	video, err := FindVideo(id)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if video == nil || video.Snippet == nil {
+		return nil, false, errors.New("youtube video " + id + " not found")
+	}
+
+	tracks = tracklist.ParseTimestamped(video.Snippet.Description)
+
+	// allowedNumberOfConversions = 0 means convert all tracks
+	if allowedNumberOfConversions != 0 && len(tracks) > allowedNumberOfConversions {
+		tracks = tracks[0:allowedNumberOfConversions]
+		truncated = true
+	}
+
+	return tracks, truncated, nil
+}
+
+// returns the found videos by id, ids are looked up in batches of videosPerRequest
+func LookupVideos(ctx context.Context, ids []string) (map[string]*youtube.Video, error) {
+	videos := map[string]*youtube.Video{}
+
+	for start := 0; start < len(ids); start += videosPerRequest {
+		end := start + videosPerRequest
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		res, err := youtubeService.Videos.List([]string{"id", "snippet", "contentDetails"}).Id(ids[start:end]...).Context(ctx).Do()
+
+		if err != nil {
+			log.Println("LookupVideos error - " + err.Error())
+
+			return nil, err
+		}
+
+		for _, video := range res.Items {
+			videos[video.Id] = video
+		}
+	}
+
+	return videos, nil
+}
+
+/*
+names the track of a music video, the artist is the channel of "{artist} - Topic" channels
+or comes from "{artist} - {title}" video titles, video notes like "(Official Video)" are dropped from the title
+*/
+func videoTrack(video *youtube.Video) *services.SearchTrack {
+	track := &services.SearchTrack{Id: video.Id, Artists: shared_types.Artists{}}
+
+	if video.ContentDetails != nil {
+		track.Duration = parseISODuration(video.ContentDetails.Duration).Milliseconds()
+	}
+
+	if video.Snippet == nil {
+		return track
+	}
+
+	title := strings.TrimSpace(videoTitleNotesPattern.ReplaceAllString(video.Snippet.Title, ""))
+	channel := strings.TrimSpace(video.Snippet.ChannelTitle)
+	track.Title = title
+
+	if strings.HasSuffix(channel, " - Topic") {
+		track.Artists = append(track.Artists, shared_types.Artist{Name: strings.TrimSuffix(channel, " - Topic")})
+
+		return track
+	}
+
+	if lines := tracklist.ParseLines(title); len(lines) == 1 && len(lines[0].Track.Artists) > 0 {
+		track.Title = lines[0].Track.Title
+		track.Artists = lines[0].Track.Artists
+
+		return track
+	}
+
+	if artist := strings.TrimSuffix(channel, "VEVO"); artist != "" {
+		track.Artists = append(track.Artists, shared_types.Artist{Name: artist})
+	}
+
+	return track
+}
+
+// ISO 8601 durations of videos e.g PT1H2M3S
+func parseISODuration(value string) time.Duration {
+	match := isoDurationPattern.FindStringSubmatch(value)
+
+	if match == nil {
+		return 0
+	}
+
+	duration := time.Duration(0)
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	for i, unit := range units {
+		if n, err := strconv.Atoi(match[i+1]); err == nil {
+			duration += time.Duration(n) * unit
+		}
+	}
+
+	return duration
+}
+
 func ToSearchTrackList(tracks []*Music) services.SearchTrackList {
 	searchTrackList := make(services.SearchTrackList, 0, len(tracks))
 
 	for _, track := range tracks {
 		t := services.SearchTrack{
+			Id:       track.YoutubeId,
 			Title:    track.Title,
 			Artists:  track.Artists,
 			Album:    track.Album,
@@ -141,7 +298,7 @@ func CreatePlaylist(name string, sessionId string) (string, error) {
 	res, err := call.Do()
 
 	if err != nil {
-		log.Panic(err)
+		log.Println("error creating playlist", err)
 
 		return "", err
 	}
@@ -149,57 +306,6 @@ func CreatePlaylist(name string, sessionId string) (string, error) {
 	return res.Id, nil
 }
 
-func AddTracksToPlaylist(playlistId string, tracks services.SearchTrackList, sessionId string) error {
-	token, tokenErr := getAuthCodeToken(sessionId)
-
-	if tokenErr != nil {
-		log.Println(tokenErr)
-		return tokenErr
-	}
-
-	for _, track := range tracks {
-		artist := ""
-		if len(track.Artists) > 0 {
-			artist = track.Artists[0].Name
-		}
-
-		entry, found, sErr := YTMusic_SearchTrack(track.Title, artist)
-
-		if sErr != nil {
-			log.Println("error searching track", sErr)
-			return sErr
-		}
-
-		if found {
-			PlaylistItem := &youtube.PlaylistItem{
-				Snippet: &youtube.PlaylistItemSnippet{
-					ResourceId: &youtube.ResourceId{
-						VideoId: entry.YoutubeId,
-					},
-					Title: entry.Title,
-				},
-			}
-
-			call := youtubeService.PlaylistItems.Insert([]string{"snippet", "status"}, PlaylistItem)
-
-			call.Header().Add("Authorization", "Bearer "+token.AccessToken)
-
-			_, err := call.Do()
-
-			if err != nil {
-				log.Println("error adding track to playlist", err)
-				continue
-			}
-
-		} else {
-			continue
-		}
-
-	}
-
-	return nil
-}
-
 func AddTrackToPlaylist(playlistId string, track *Music, sessionId string) error {
 	token, tokenErr := getAuthCodeToken(sessionId)
 
@@ -210,7 +316,9 @@ func AddTrackToPlaylist(playlistId string, track *Music, sessionId string) error
 
 	PlaylistItem := &youtube.PlaylistItem{
 		Snippet: &youtube.PlaylistItemSnippet{
+			PlaylistId: playlistId,
 			ResourceId: &youtube.ResourceId{
+				Kind:    "youtube#video",
 				VideoId: track.YoutubeId,
 			},
 			Title: track.Title,
@@ -6,13 +6,14 @@ import (
 	"errors"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/to-dy/music-playlist-converter/api/services"
 	"github.com/to-dy/music-playlist-converter/api/services/shared_types"
 )
 
@@ -27,7 +28,7 @@ type Music struct {
 type Artist shared_types.Artist
 
 const (
-	PageTypeArtist   = "MUSIC_PAGE_TYPE_ARTIST'"
+	PageTypeArtist   = "MUSIC_PAGE_TYPE_ARTIST"
 	PageTypeAlbum    = "MUSIC_PAGE_TYPE_ALBUM"
 	PageTypePlaylist = "MUSIC_PAGE_TYPE_PLAYLIST"
 
@@ -62,7 +63,8 @@ func generateBodyContext(data []bodyData) map[string]interface{} {
 	return body
 }
 
-func YTMusic_SearchTrack(query string, artist string) (*Music, bool, error) {
+// searches youtube music songs and returns at most limit results in the order youtube ranks them
+func YTMusic_SearchTracks(query string, artist string, limit int) ([]*Music, error) {
 	// search for track on youtube by provided query(artist + track)
 	cli := fiber.Client{}
 
@@ -71,55 +73,49 @@ func YTMusic_SearchTrack(query string, artist string) (*Music, bool, error) {
 		{Key: "params", Value: "EgWKAQIIAWoKEAoQCRADEAQQBQ%3D%3D"}, // do not know what this does, but it generates the type of data needed
 	})
 
-	res := cli.Post(YTMusic_BaseURL+"/search?alt=json&maxResults=1&key="+YOUTUBE_MUSIC_KEY).
+	res := cli.Post(YTMusic_BaseURL+"/search?alt=json&key="+YOUTUBE_MUSIC_KEY).
 		UserAgent("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)").
 		Set("origin", "https://music.youtube.com").
 		JSON(body)
 
 	var ytmRes YTMusic_SearchResults
 
-	_, _, errs := res.Struct(&ytmRes)
+	status, _, errs := res.Struct(&ytmRes)
 
 	if errs != nil {
-		log.Panic(errs)
-		return nil, false, errs[0]
+		return nil, errs[0]
 	}
 
-	music := parseSearchMusicsBody(&ytmRes)
-
-	// musicBytes, _ := json.Marshal(music)
-
-	// filePath := "res_output.json"
-	// err := os.WriteFile(filePath, musicBytes, 0644)
-
-	// if err != nil {
-	// 	log.Panic("Error writing file:", err)
-	// }
-
-	// log.Println("JSON data written to", filePath)
+	if status != http.StatusOK {
+		return nil, errors.New("error searching track | Status code: " + strconv.Itoa(status))
+	}
 
-	if len(music) > 0 {
+	music := parseSearchMusicsBody(&ytmRes)
 
-		return music[0], true, nil
+	if limit > 0 && len(music) > limit {
+		music = music[:limit]
 	}
 
-	return nil, false, errors.New("track not found")
+	return music, nil
 }
 
-func YTMusic_GetPlaylistTracks(id string) ([]*Music, error) {
-	var tracks = []*Music{}
+/*
+fetches the playlist tracks, following browse continuations until all tracks or the allowed number of conversions are fetched
 
+truncated is true when the playlist has more tracks than the allowed number of conversions
+*/
+func YTMusic_GetPlaylistTracks(id string) (tracks []*Music, truncated bool, err error) {
 	cli := fiber.Client{}
 
 	if !strings.HasPrefix(id, "VL") {
 		id = "VL" + id
 	}
 
-	allowedNumberOfConversions, intConvErr := strconv.Atoi(os.Getenv("ALLOWED_NUMBER_OF_CONVERSIONS"))
+	allowedNumberOfConversions, intConvErr := services.AllowedNumberOfConversions()
 
 	if intConvErr != nil {
 		log.Println(intConvErr)
-		return nil, intConvErr
+		return nil, false, intConvErr
 	}
 
 	body := generateBodyContext([]bodyData{{Key: "browseId", Value: id}})
@@ -134,30 +130,62 @@ func YTMusic_GetPlaylistTracks(id string) ([]*Music, error) {
 	status, _, errs := res.Struct(&ytmRes)
 
 	if errs != nil {
-		log.Panic(errs)
+		return nil, false, errs[0]
+	}
 
-		return nil, errs[0]
+	if status != http.StatusOK {
+		return nil, false, errors.New("playlist not found | Status code: " + strconv.Itoa(status))
 	}
 
-	// TODO: support fetching more tracks via continuations
-	//  continuations := ytmRes.Contents.SingleColumnBrowseResultsRenderer.Tabs[0].TabRenderer.Content.SectionListRenderer.Continuations
+	tracks = parseListMusicsFromPlaylistBody(&ytmRes)
+	continuation := parsePlaylistContinuation(&ytmRes)
 
-	if status == http.StatusOK {
+	// allowedNumberOfConversions = 0 means convert all tracks
+	for continuation != "" && (allowedNumberOfConversions == 0 || len(tracks) < allowedNumberOfConversions) {
+		var contRes YTMusic_PlaylistContinuationResults
 
-		tracks = parseListMusicsFromPlaylistBody(&ytmRes)
+		if err := browseContinuation(&cli, continuation, &contRes); err != nil {
+			return nil, false, err
+		}
 
-		// allowedNumberOfConversions = 0 means convert all tracks
-		if allowedNumberOfConversions != 0 {
-			// check so we don't go out of range
-			if allowedNumberOfConversions > len(tracks) {
-				allowedNumberOfConversions = len(tracks)
-			}
+		page := parseListMusicsFromPlaylistContinuationBody(&contRes)
 
-			tracks = tracks[0:allowedNumberOfConversions]
+		// guard against looping on an empty page
+		if len(page) == 0 {
+			continuation = ""
+			break
 		}
 
-		return tracks, nil
+		tracks = append(tracks, page...)
+		continuation = parsePlaylistContinuationFromContinuationBody(&contRes)
+	}
+
+	if allowedNumberOfConversions != 0 && len(tracks) >= allowedNumberOfConversions {
+		truncated = len(tracks) > allowedNumberOfConversions || continuation != ""
+		tracks = tracks[0:allowedNumberOfConversions]
+	}
+
+	return tracks, truncated, nil
+}
+
+// fetches the next page of a browse response
+func browseContinuation(cli *fiber.Client, continuation string, out interface{}) error {
+	token := url.QueryEscape(continuation)
+
+	res := cli.Post(YTMusic_BaseURL+"/browse?ctoken="+token+"&continuation="+token+"&type=next&alt=json&key="+YOUTUBE_MUSIC_KEY).
+		UserAgent("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)").
+		Set("origin", "https://music.youtube.com").
+		JSON(generateBodyContext(nil)).Debug()
+
+	status, _, errs := res.Struct(out)
+
+	if errs != nil {
+		return errs[0]
+	}
+
+	if status != http.StatusOK {
+		return errors.New("error fetching playlist continuation | Status code: " + strconv.Itoa(status))
 	}
 
-	return nil, errors.New("playlist not found | Status code: " + strconv.Itoa(status))
+	return nil
 }
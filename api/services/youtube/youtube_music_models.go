@@ -47,6 +47,12 @@ type YTMusic_MusicShelfContent struct {
 	} `json:"musicResponsiveListItemRenderer"`
 }
 
+type YTMusic_Continuations []struct {
+	NextContinuationData struct {
+		Continuation string `json:"continuation"`
+	} `json:"nextContinuationData"`
+}
+
 type YTMusic_SearchResults struct {
 	Contents struct {
 		TabbedSearchResultsRenderer struct {
@@ -83,15 +89,12 @@ type YTMusic_PlaylistResults struct {
 						SectionListRenderer struct {
 							Contents []struct {
 								MusicPlaylistShelfRenderer struct {
-									Contents []YTMusic_MusicShelfContent `json:"contents"`
+									Contents      []YTMusic_MusicShelfContent `json:"contents"`
+									Continuations YTMusic_Continuations       `json:"continuations"`
 								} `json:"musicPlaylistShelfRenderer"`
 							} `json:"contents"`
 
-							Continuations []struct {
-								NextContinuationData struct {
-									Continuation string `json:"continuation"`
-								} `json:"nextContinuationData"`
-							} `json:"continuations"`
+							Continuations YTMusic_Continuations `json:"continuations"`
 						} `json:"sectionListRenderer"`
 					} `json:"content"`
 				} `json:"tabRenderer"`
@@ -99,3 +102,13 @@ type YTMusic_PlaylistResults struct {
 		} `json:"singleColumnBrowseResultsRenderer"`
 	} `json:"contents"`
 }
+
+// response of a browse request made with a continuation token
+type YTMusic_PlaylistContinuationResults struct {
+	ContinuationContents struct {
+		MusicPlaylistShelfContinuation struct {
+			Contents      []YTMusic_MusicShelfContent `json:"contents"`
+			Continuations YTMusic_Continuations       `json:"continuations"`
+		} `json:"musicPlaylistShelfContinuation"`
+	} `json:"continuationContents"`
+}
@@ -14,8 +14,14 @@ import (
 func parseSearchMusicsBody(body *YTMusic_SearchResults) []*Music {
 	results := make([]*Music, 0)
 
+	tabs := body.Contents.TabbedSearchResultsRenderer.Tabs
+
+	if len(tabs) == 0 {
+		return results
+	}
+
 	// Extract the necessary data from the body JSON object contents.tabbedSearchResultsRenderer.tabs[0].tabRenderer.content.sectionListRenderer.contents
-	contents := body.Contents.TabbedSearchResultsRenderer.Tabs[0].TabRenderer.Content.SectionListRenderer.Contents
+	contents := tabs[0].TabRenderer.Content.SectionListRenderer.Contents
 
 	if len(contents) != 0 {
 		// contents[0].musicShelfRenderer | extract musicShelfRenderer from the first object in contents in array
@@ -40,37 +46,84 @@ func parseSearchMusicsBody(body *YTMusic_SearchResults) []*Music {
 
 // ref: https://github.com/baptisteArno/node-youtube-music/blob/main/src/listMusicsFromPlaylist.ts#LL6C18-L6C18
 func parseListMusicsFromPlaylistBody(body *YTMusic_PlaylistResults) []*Music {
-	results := []*Music{}
+	tabs := body.Contents.SingleColumnBrowseResultsRenderer.Tabs
 
-	contents := body.Contents.SingleColumnBrowseResultsRenderer.Tabs[0].TabRenderer.Content.SectionListRenderer.Contents
+	if len(tabs) == 0 {
+		return []*Music{}
+	}
 
-	if len(contents) != 0 {
-		musicPlaylistShelfRenderer := contents[0].MusicPlaylistShelfRenderer
+	contents := tabs[0].TabRenderer.Content.SectionListRenderer.Contents
 
-		for _, content := range musicPlaylistShelfRenderer.Contents {
-			song, err := parseMusicInPlaylistItem(&content)
+	if len(contents) == 0 {
+		return []*Music{}
+	}
 
-			if err != nil {
-				log.Println("Error parsing playlist item:", err)
-				continue
-			}
+	return parsePlaylistShelfContents(contents[0].MusicPlaylistShelfRenderer.Contents)
+}
 
-			if song != nil {
-				results = append(results, song)
-			}
+func parseListMusicsFromPlaylistContinuationBody(body *YTMusic_PlaylistContinuationResults) []*Music {
+	return parsePlaylistShelfContents(body.ContinuationContents.MusicPlaylistShelfContinuation.Contents)
+}
+
+func parsePlaylistShelfContents(contents []YTMusic_MusicShelfContent) []*Music {
+	results := []*Music{}
+
+	for _, content := range contents {
+		song, err := parseMusicInPlaylistItem(&content)
+
+		if err != nil {
+			log.Println("Error parsing playlist item:", err)
+			continue
+		}
+
+		if song != nil {
+			results = append(results, song)
 		}
 	}
 
 	return results
 }
 
+// returns the token used to fetch the next page of playlist tracks, empty if there are no more tracks
+func parsePlaylistContinuation(body *YTMusic_PlaylistResults) string {
+	tabs := body.Contents.SingleColumnBrowseResultsRenderer.Tabs
+
+	if len(tabs) == 0 {
+		return ""
+	}
+
+	sectionListRenderer := tabs[0].TabRenderer.Content.SectionListRenderer
+
+	if len(sectionListRenderer.Contents) > 0 {
+		if token := firstContinuation(sectionListRenderer.Contents[0].MusicPlaylistShelfRenderer.Continuations); token != "" {
+			return token
+		}
+	}
+
+	return firstContinuation(sectionListRenderer.Continuations)
+}
+
+func parsePlaylistContinuationFromContinuationBody(body *YTMusic_PlaylistContinuationResults) string {
+	return firstContinuation(body.ContinuationContents.MusicPlaylistShelfContinuation.Continuations)
+}
+
+func firstContinuation(continuations YTMusic_Continuations) string {
+	for _, continuation := range continuations {
+		if continuation.NextContinuationData.Continuation != "" {
+			return continuation.NextContinuationData.Continuation
+		}
+	}
+
+	return ""
+}
+
 // reference: https://github.com/baptisteArno/node-youtube-music/blob/main/src/parsers.ts#L59
 func parseMusicItem(content *YTMusic_MusicShelfContent) (*Music, error) {
 	// func parseMusicItem(content map[string]interface{}) (*Music, error) {
 	var youtubeId, title, album string
 	var artists shared_types.Artists
 
-	var duration *time.Duration
+	duration := new(time.Duration)
 
 	// content.musicResponsiveListItemRenderer | Get the music responsive list item renderer.
 	musicResponsiveListItemRenderer := content.MusicResponsiveListItemRenderer
@@ -110,7 +163,9 @@ func parseMusicItem(content *YTMusic_MusicShelfContent) (*Music, error) {
 				}
 
 				// Extract album
-				if length := len(runs); length > 2 {
+				album = findAlbum(&runs)
+
+				if length := len(runs); album == "" && length > 4 {
 					album = runs[4].Text
 				}
 			}
@@ -141,15 +196,15 @@ func parseMusicInPlaylistItem(content *YTMusic_MusicShelfContent) (*Music, error
 	var youtubeId, title string
 	var artists []shared_types.Artist
 	var album string
-	var duration *time.Duration
+	duration := new(time.Duration)
 
 	if len(flexColumns) > 0 {
 		flexColumn := flexColumns[0].MusicResponsiveListItemFlexColumnRenderer
 		runs := flexColumn.Text.Runs
 
-		// Extract title
-		title = runs[0].Text
 		if len(runs) > 0 {
+			// Extract title
+			title = runs[0].Text
 
 			// Extract YouTube ID
 			_, ok := reflect.TypeOf(runs[0].NavigationEndpoint).FieldByName("WatchEndpoint")
@@ -216,10 +271,21 @@ func parseDuration(durationLabel string) *time.Duration {
 		return &t
 	}
 
-	t := time.Duration(hours*3600 + minutes*60 + seconds)
+	t := time.Duration(hours*3600+minutes*60+seconds) * time.Second
 	return &t
 }
 
+// returns the text of the run linking to an album page
+func findAlbum(data *YTMusic_Runs) string {
+	for _, item := range *data {
+		if item.NavigationEndpoint.BrowseEndpoint.BrowseEndpointContextSupportedConfigs.BrowseEndpointContextMusicConfig.PageType == PageTypeAlbum {
+			return item.Text
+		}
+	}
+
+	return ""
+}
+
 // reference: https://github.com/baptisteArno/node-youtube-music/blob/main/src/parsers.ts#L33
 func listArtists(data *YTMusic_Runs) shared_types.Artists {
 	// Create a new slice to store the artists.
@@ -0,0 +1,754 @@
+package jobstore
+
+// persists conversion jobs and the auth tokens they need in an embedded SQLite database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/to-dy/music-playlist-converter/api/services"
+	"github.com/to-dy/music-playlist-converter/api/services/converter"
+	"github.com/to-dy/music-playlist-converter/api/services/upload"
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+)
+
+const defaultPath = "data/jobs.db"
+
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrInvalidSecretsKey = errors.New("TOKEN_ENCRYPTION_KEY must be 32 base64 encoded bytes")
+	errUndecryptable     = errors.New("secret can't be decrypted with the key")
+)
+
+type Store struct {
+	db *sql.DB
+	// encrypts tokens and sessions at rest, nil when no key is set and secrets are kept in memory only
+	secrets cipher.AEAD
+}
+
+// persisted job, State is nil until the source tracks were fetched
+type Job struct {
+	Id         string
+	SessionId  string
+	Source     string
+	Target     string
+	PlaylistId string
+	Title      string
+	Status     string
+	Error      string
+	State      *converter.State
+	// event log in emission order
+	Events    []*Event
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Event struct {
+	Id   int64
+	Name string
+	Data string
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id TEXT PRIMARY KEY,
+	session_id TEXT NOT NULL,
+	source TEXT NOT NULL,
+	target TEXT NOT NULL,
+	playlist_id TEXT NOT NULL,
+	title TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	source_tracks TEXT,
+	truncated INTEGER NOT NULL DEFAULT 0,
+	target_playlist_id TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS job_tracks (
+	job_id TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	result TEXT NOT NULL,
+	added INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (job_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS job_events (
+	job_id TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+	id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (job_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS tokens (
+	name TEXT PRIMARY KEY,
+	token TEXT NOT NULL,
+	expiration INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id TEXT PRIMARY KEY,
+	data TEXT NOT NULL,
+	expiration INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS uploads (
+	id TEXT PRIMARY KEY,
+	session_id TEXT NOT NULL,
+	file_name TEXT NOT NULL,
+	format TEXT NOT NULL,
+	title TEXT NOT NULL,
+	tracks TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS libraries (
+	id TEXT PRIMARY KEY,
+	session_id TEXT NOT NULL,
+	file_name TEXT NOT NULL,
+	format TEXT NOT NULL,
+	playlists TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+`
+
+/*
+opens the database at JOB_STORE_PATH, creating it when missing.
+tokens and sessions are only persisted, encrypted, when TOKEN_ENCRYPTION_KEY is set
+*/
+func OpenFromEnv() (*Store, error) {
+	path := os.Getenv("JOB_STORE_PATH")
+
+	if path == "" {
+		path = defaultPath
+	}
+
+	store, err := Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := os.Getenv("TOKEN_ENCRYPTION_KEY")
+
+	if key == "" {
+		// secrets persisted by an older version were not encrypted
+		err = store.deleteSecrets()
+	} else {
+		err = store.UseSecretsKey(key)
+	}
+
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite allows a single writer, serialize access instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// sets the AES-256 key encrypting tokens and sessions, key is 32 base64 encoded bytes e.g from `openssl rand -base64 32`
+func (s *Store) UseSecretsKey(key string) error {
+	raw, err := base64.StdEncoding.DecodeString(key)
+
+	if err != nil || len(raw) != 32 {
+		return ErrInvalidSecretsKey
+	}
+
+	block, err := aes.NewCipher(raw)
+
+	if err != nil {
+		return err
+	}
+
+	s.secrets, err = cipher.NewGCM(block)
+
+	return err
+}
+
+// true when tokens and sessions can be persisted
+func (s *Store) PersistsSecrets() bool {
+	return s.secrets != nil
+}
+
+func (s *Store) deleteSecrets() error {
+	_, err := s.db.Exec(`DELETE FROM tokens; DELETE FROM sessions;`)
+
+	return err
+}
+
+// encrypts the secret stored under name, the name is authenticated so a secret can't be moved to another row
+func (s *Store) seal(name string, plaintext []byte) (string, error) {
+	nonce := make([]byte, s.secrets.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(s.secrets.Seal(nonce, nonce, plaintext, []byte(name))), nil
+}
+
+// returns errUndecryptable for secrets written with another key or before encryption
+func (s *Store) open(name string, sealed string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+
+	if err != nil || len(data) < s.secrets.NonceSize() {
+		return nil, errUndecryptable
+	}
+
+	nonce, ciphertext := data[:s.secrets.NonceSize()], data[s.secrets.NonceSize():]
+	plaintext, err := s.secrets.Open(nil, nonce, ciphertext, []byte(name))
+
+	if err != nil {
+		return nil, errUndecryptable
+	}
+
+	return plaintext, nil
+}
+
+func (s *Store) CreateJob(job *Job) error {
+	_, err := s.db.Exec(
+		`INSERT INTO jobs (id, session_id, source, target, playlist_id, title, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.Id, job.SessionId, job.Source, job.Target, job.PlaylistId, job.Title, job.Status,
+		job.CreatedAt.UnixMilli(), job.UpdatedAt.UnixMilli(),
+	)
+
+	return err
+}
+
+func (s *Store) UpdateStatus(id string, status string, errMessage string) error {
+	_, err := s.db.Exec(
+		`UPDATE jobs SET status = ?, error = ?, updated_at = ? WHERE id = ?`,
+		status, errMessage, time.Now().UnixMilli(), id,
+	)
+
+	return err
+}
+
+/*
+checkpoints the conversion state of a job
+
+only the track results at the changed indexes are written, the source tracks and
+target playlist are written when changed is empty
+*/
+func (s *Store) SaveState(id string, state *converter.State, changed []int) error {
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if len(changed) == 0 {
+		sourceTracks, err := json.Marshal(state.Tracks)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			`UPDATE jobs SET source_tracks = ?, truncated = ?, target_playlist_id = ?, updated_at = ? WHERE id = ?`,
+			string(sourceTracks), state.Truncated, state.PlaylistId, time.Now().UnixMilli(), id,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, position := range changed {
+		result := state.Results[position]
+		data, err := json.Marshal(result)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO job_tracks (job_id, position, result, added) VALUES (?, ?, ?, ?)
+			ON CONFLICT (job_id, position) DO UPDATE SET result = excluded.result, added = excluded.added`,
+			id, position, string(data), result.Added,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(changed) > 0 {
+		if _, err := tx.Exec(`UPDATE jobs SET updated_at = ? WHERE id = ?`, time.Now().UnixMilli(), id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// deletes the jobs with one of the statuses last updated before the time, their tracks and events are deleted with them
+func (s *Store) DeleteJobsUpdatedBefore(before time.Time, statuses ...string) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	where := `WHERE updated_at < ? AND status IN (?`
+	args := []interface{}{before.UnixMilli(), statuses[0]}
+
+	for _, status := range statuses[1:] {
+		where += `, ?`
+		args = append(args, status)
+	}
+
+	_, err := s.db.Exec(`DELETE FROM jobs `+where+`)`, args...)
+
+	return err
+}
+
+func (s *Store) AppendEvent(jobId string, event *Event) error {
+	_, err := s.db.Exec(
+		`INSERT INTO job_events (job_id, id, name, data) VALUES (?, ?, ?, ?)`,
+		jobId, event.Id, event.Name, event.Data,
+	)
+
+	return err
+}
+
+func (s *Store) GetJob(id string) (*Job, error) {
+	jobs, err := s.queryJobs(`WHERE id = ?`, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(jobs) == 0 {
+		return nil, ErrJobNotFound
+	}
+
+	return jobs[0], nil
+}
+
+// returns jobs with one of the statuses, oldest first
+func (s *Store) JobsWithStatus(statuses ...string) ([]*Job, error) {
+	if len(statuses) == 0 {
+		return []*Job{}, nil
+	}
+
+	where := `WHERE status IN (?`
+	args := []interface{}{statuses[0]}
+
+	for _, status := range statuses[1:] {
+		where += `, ?`
+		args = append(args, status)
+	}
+
+	return s.queryJobs(where+`) ORDER BY created_at`, args...)
+}
+
+func (s *Store) queryJobs(where string, args ...interface{}) ([]*Job, error) {
+	rows, err := s.db.Query(
+		`SELECT id, session_id, source, target, playlist_id, title, status, error,
+		source_tracks, truncated, target_playlist_id, created_at, updated_at FROM jobs `+where,
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := []*Job{}
+
+	for rows.Next() {
+		var job Job
+		var sourceTracks sql.NullString
+		var truncated bool
+		var targetPlaylistId string
+		var createdAt, updatedAt int64
+
+		err := rows.Scan(
+			&job.Id, &job.SessionId, &job.Source, &job.Target, &job.PlaylistId, &job.Title, &job.Status, &job.Error,
+			&sourceTracks, &truncated, &targetPlaylistId, &createdAt, &updatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		job.CreatedAt = time.UnixMilli(createdAt)
+		job.UpdatedAt = time.UnixMilli(updatedAt)
+
+		if sourceTracks.Valid {
+			var tracks services.SearchTrackList
+
+			if err := json.Unmarshal([]byte(sourceTracks.String), &tracks); err != nil {
+				return nil, err
+			}
+
+			job.State = &converter.State{
+				Tracks:     tracks,
+				Truncated:  truncated,
+				PlaylistId: targetPlaylistId,
+			}
+		}
+
+		jobs = append(jobs, &job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	for _, job := range jobs {
+		events, err := s.events(job.Id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		job.Events = events
+
+		if job.State == nil {
+			continue
+		}
+
+		results, err := s.trackResults(job.Id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		job.State.Results = results
+	}
+
+	return jobs, nil
+}
+
+func (s *Store) events(jobId string) ([]*Event, error) {
+	rows, err := s.db.Query(`SELECT id, name, data FROM job_events WHERE job_id = ? ORDER BY id`, jobId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		var event Event
+
+		if err := rows.Scan(&event.Id, &event.Name, &event.Data); err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+// returns the results of the searched tracks, stopping at the first gap so the conversion resumes from there
+func (s *Store) trackResults(jobId string) ([]*converter.TrackResult, error) {
+	rows, err := s.db.Query(`SELECT position, result FROM job_tracks WHERE job_id = ? ORDER BY position`, jobId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := []*converter.TrackResult{}
+
+	for rows.Next() {
+		var position int
+		var data string
+
+		if err := rows.Scan(&position, &data); err != nil {
+			return nil, err
+		}
+
+		if position != len(results) {
+			break
+		}
+
+		var result converter.TrackResult
+
+		if err := json.Unmarshal([]byte(data), &result); err != nil {
+			return nil, err
+		}
+
+		results = append(results, &result)
+	}
+
+	return results, rows.Err()
+}
+
+// implements tokenstore.Persister, the tokens are encrypted with the secrets key
+
+func (s *Store) SaveToken(name string, entry tokenstore.TokenEntry) error {
+	if s.secrets == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry.Token)
+
+	if err != nil {
+		return err
+	}
+
+	token, err := s.seal(name, data)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO tokens (name, token, expiration) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET token = excluded.token, expiration = excluded.expiration`,
+		name, token, entry.Expiration.UnixMilli(),
+	)
+
+	return err
+}
+
+// tokens that can't be decrypted, e.g after the key changed, are deleted
+func (s *Store) LoadTokens() (map[string]tokenstore.TokenEntry, error) {
+	entries := map[string]tokenstore.TokenEntry{}
+
+	if s.secrets == nil {
+		return entries, nil
+	}
+
+	rows, err := s.db.Query(`SELECT name, token, expiration FROM tokens`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	undecryptable := []string{}
+
+	for rows.Next() {
+		var name, token string
+		var expiration int64
+
+		if err := rows.Scan(&name, &token, &expiration); err != nil {
+			return nil, err
+		}
+
+		data, err := s.open(name, token)
+
+		if errors.Is(err, errUndecryptable) {
+			undecryptable = append(undecryptable, name)
+			continue
+		}
+
+		var entry tokenstore.TokenEntry
+
+		if err := json.Unmarshal(data, &entry.Token); err != nil {
+			return nil, err
+		}
+
+		entry.Expiration = time.UnixMilli(expiration)
+		entries[name] = entry
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	for _, name := range undecryptable {
+		if _, err := s.db.Exec(`DELETE FROM tokens WHERE name = ?`, name); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *Store) DeleteTokens(prefix string) error {
+	_, err := s.db.Exec(`DELETE FROM tokens WHERE substr(name, 1, ?) = ?`, len(prefix), prefix)
+
+	return err
+}
+
+func (s *Store) DeleteTokensExpiredBefore(before time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM tokens WHERE expiration < ?`, before.UnixMilli())
+
+	return err
+}
+
+// implements upload.Persister
+
+func (s *Store) SaveUpload(u *upload.Upload) error {
+	tracks, err := json.Marshal(u.Tracks)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO uploads (id, session_id, file_name, format, title, tracks, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		u.Id, u.SessionId, u.FileName, u.Format, u.Title, string(tracks), u.CreatedAt.UnixMilli(),
+	)
+
+	return err
+}
+
+func (s *Store) GetUpload(id string) (*upload.Upload, error) {
+	u := &upload.Upload{Id: id}
+
+	var tracks string
+	var createdAt int64
+
+	err := s.db.QueryRow(
+		`SELECT session_id, file_name, format, title, tracks, created_at FROM uploads WHERE id = ?`, id,
+	).Scan(&u.SessionId, &u.FileName, &u.Format, &u.Title, &tracks, &createdAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, upload.ErrUploadNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(tracks), &u.Tracks); err != nil {
+		return nil, err
+	}
+
+	u.CreatedAt = time.UnixMilli(createdAt)
+
+	return u, nil
+}
+
+func (s *Store) DeleteUploadsBefore(before time.Time, keep func(id string) bool) error {
+	rows, err := s.db.Query(`SELECT id FROM uploads WHERE created_at < ?`, before.UnixMilli())
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	ids := []string{}
+
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+
+		if !keep(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows.Close()
+
+	for _, id := range ids {
+		if _, err := s.db.Exec(`DELETE FROM uploads WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteLibrariesBefore(before time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM libraries WHERE created_at < ?`, before.UnixMilli())
+
+	return err
+}
+
+func (s *Store) SaveLibrary(l *upload.Library) error {
+	playlists, err := json.Marshal(l.Playlists)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO libraries (id, session_id, file_name, format, playlists, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		l.Id, l.SessionId, l.FileName, l.Format, string(playlists), l.CreatedAt.UnixMilli(),
+	)
+
+	return err
+}
+
+func (s *Store) GetLibrary(id string) (*upload.Library, error) {
+	l := &upload.Library{Id: id}
+
+	var playlists string
+	var createdAt int64
+
+	err := s.db.QueryRow(
+		`SELECT session_id, file_name, format, playlists, created_at FROM libraries WHERE id = ?`, id,
+	).Scan(&l.SessionId, &l.FileName, &l.Format, &playlists, &createdAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, upload.ErrLibraryNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(playlists), &l.Playlists); err != nil {
+		return nil, err
+	}
+
+	l.CreatedAt = time.UnixMilli(createdAt)
+
+	return l, nil
+}
@@ -0,0 +1,217 @@
+package jobstore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/to-dy/music-playlist-converter/api/stores/tokenstore"
+)
+
+const testKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func openTestStore(t *testing.T, key string) *Store {
+	t.Helper()
+
+	store, err := Open(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { store.Close() })
+
+	if key != "" {
+		if err := store.UseSecretsKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store
+}
+
+func TestUseSecretsKeyRejectsShortKeys(t *testing.T) {
+	store := openTestStore(t, "")
+
+	for _, key := range []string{"not base64!", "c2hvcnQ="} {
+		if err := store.UseSecretsKey(key); err != ErrInvalidSecretsKey {
+			t.Errorf("UseSecretsKey(%q) = %v, expected ErrInvalidSecretsKey", key, err)
+		}
+	}
+}
+
+func TestTokensAreEncrypted(t *testing.T) {
+	store := openTestStore(t, testKey)
+
+	entry := tokenstore.TokenEntry{
+		Token:      &oauth2.Token{AccessToken: "access-secret", RefreshToken: "refresh-secret"},
+		Expiration: time.Now().Add(time.Hour).Truncate(time.Millisecond),
+	}
+
+	if err := store.SaveToken("session_token", entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw string
+	if err := store.db.QueryRow(`SELECT token FROM tokens WHERE name = ?`, "session_token").Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(raw, "secret") {
+		t.Fatalf("token stored in plaintext: %s", raw)
+	}
+
+	entries, err := store.LoadTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, ok := entries["session_token"]
+	if !ok || loaded.Token.AccessToken != "access-secret" || loaded.Token.RefreshToken != "refresh-secret" {
+		t.Fatalf("loaded %+v", loaded)
+	}
+
+	if !loaded.Expiration.Equal(entry.Expiration) {
+		t.Errorf("expiration %v, expected %v", loaded.Expiration, entry.Expiration)
+	}
+}
+
+func TestUndecryptableTokensAreDeleted(t *testing.T) {
+	store := openTestStore(t, testKey)
+
+	if _, err := store.db.Exec(`INSERT INTO tokens (name, token, expiration) VALUES ('plain', '{"access_token":"x"}', 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.LoadTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Fatalf("loaded %d tokens, expected the plaintext token to be dropped", len(entries))
+	}
+
+	var count int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM tokens`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("%d tokens left in the table", count)
+	}
+}
+
+func TestSessionStorage(t *testing.T) {
+	store := openTestStore(t, testKey)
+	sessions := store.Sessions()
+
+	if err := sessions.Set("session", []byte("data"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := sessions.Get("session")
+	if err != nil || string(value) != "data" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+
+	// a session copied to another id doesn't decrypt
+	if _, err := store.db.Exec(`INSERT INTO sessions (id, data, expiration) SELECT 'other', data, expiration FROM sessions`); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := sessions.Get("other"); err != nil || value != nil {
+		t.Errorf("Get(other) = %q, %v, expected nothing", value, err)
+	}
+
+	if err := sessions.Set("expired", []byte("data"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if value, err := sessions.Get("expired"); err != nil || value != nil {
+		t.Errorf("Get(expired) = %q, %v, expected nothing", value, err)
+	}
+
+	if err := store.DeleteExpiredSessions(); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("%d sessions left, expected only the valid one", count)
+	}
+}
+
+func TestDeleteJobsUpdatedBefore(t *testing.T) {
+	store := openTestStore(t, "")
+	old := time.Now().Add(-48 * time.Hour)
+
+	for _, job := range []*Job{
+		{Id: "old-finished", Status: "completed", CreatedAt: old, UpdatedAt: old},
+		{Id: "old-paused", Status: "paused", CreatedAt: old, UpdatedAt: old},
+		{Id: "recent-finished", Status: "completed", CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	} {
+		if err := store.CreateJob(job); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.AppendEvent(job.Id, &Event{Id: 1, Name: "phase", Data: "{}"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.DeleteJobsUpdatedBefore(time.Now().Add(-24*time.Hour), "completed", "failed", "cancelled"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetJob("old-finished"); err != ErrJobNotFound {
+		t.Errorf("old finished job not deleted: %v", err)
+	}
+
+	for _, id := range []string{"old-paused", "recent-finished"} {
+		if _, err := store.GetJob(id); err != nil {
+			t.Errorf("job %s deleted: %v", id, err)
+		}
+	}
+
+	var events int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM job_events WHERE job_id = 'old-finished'`).Scan(&events); err != nil {
+		t.Fatal(err)
+	}
+
+	if events != 0 {
+		t.Errorf("%d events of the deleted job left", events)
+	}
+}
+
+func TestDeleteTokensOfSession(t *testing.T) {
+	store := openTestStore(t, testKey)
+	entry := tokenstore.TokenEntry{Token: &oauth2.Token{AccessToken: "token"}, Expiration: time.Now().Add(time.Hour)}
+
+	for _, name := range []string{"session_token", "sessionX_token", "other_token"} {
+		if err := store.SaveToken(name, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.DeleteTokens("session_"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.LoadTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := entries["session_token"]; ok || len(entries) != 2 {
+		t.Errorf("tokens left %v, expected only the tokens of the other sessions", entries)
+	}
+}
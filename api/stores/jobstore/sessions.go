@@ -0,0 +1,98 @@
+package jobstore
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// fiber.Storage keeping the sessions in the job store, so a session and its jobs survive a restart
+type SessionStorage struct {
+	store *Store
+}
+
+// the storage of the sessions, the store must persist secrets
+func (s *Store) Sessions() *SessionStorage {
+	return &SessionStorage{store: s}
+}
+
+// returns nil for missing and expired sessions
+func (ss *SessionStorage) Get(id string) ([]byte, error) {
+	var data string
+	var expiration int64
+
+	err := ss.store.db.QueryRow(`SELECT data, expiration FROM sessions WHERE id = ?`, id).Scan(&data, &expiration)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if expiration != 0 && expiration < time.Now().UnixMilli() {
+		return nil, nil
+	}
+
+	value, err := ss.store.open(id, data)
+
+	if errors.Is(err, errUndecryptable) {
+		log.Println("dropping session that can't be decrypted with TOKEN_ENCRYPTION_KEY")
+
+		return nil, ss.Delete(id)
+	}
+
+	return value, err
+}
+
+// exp of 0 keeps the session until deleted
+func (ss *SessionStorage) Set(id string, value []byte, exp time.Duration) error {
+	if id == "" || len(value) == 0 {
+		return nil
+	}
+
+	data, err := ss.store.seal(id, value)
+
+	if err != nil {
+		return err
+	}
+
+	var expiration int64
+	if exp > 0 {
+		expiration = time.Now().Add(exp).UnixMilli()
+	}
+
+	_, err = ss.store.db.Exec(
+		`INSERT INTO sessions (id, data, expiration) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data, expiration = excluded.expiration`,
+		id, data, expiration,
+	)
+
+	return err
+}
+
+func (ss *SessionStorage) Delete(id string) error {
+	_, err := ss.store.db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+
+	return err
+}
+
+func (ss *SessionStorage) Reset() error {
+	_, err := ss.store.db.Exec(`DELETE FROM sessions`)
+
+	return err
+}
+
+// the database is closed with the store
+func (ss *SessionStorage) Close() error {
+	return nil
+}
+
+// deletes the sessions that expired before now
+func (s *Store) DeleteExpiredSessions() error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE expiration != 0 AND expiration < ?`, time.Now().UnixMilli())
+
+	return err
+}
@@ -3,6 +3,7 @@ package session
 import (
 	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
 )
 
@@ -16,6 +17,19 @@ const (
 
 	ConvertTo     = "convert_to"
 	AuthCodeToken = "spotify_auth_code_token"
+
+	// set once the session connected to a media server with MEDIA_SERVER_TOKEN
+	MediaServerAccess = "media_server_access"
+
+	// device code of a pending tidal login
+	TidalDeviceCode = "tidal_device_code"
+	// seconds to wait between two polls of the pending tidal login, raised when tidal asks to slow down
+	TidalPollInterval = "tidal_poll_interval"
+	// unix time of the last poll of the pending tidal login
+	TidalLastPoll = "tidal_last_poll"
+
+	// id of the last conversion job started by the session
+	ConversionJob = "conversion_job"
 )
 
 // var Store *session.Store
@@ -30,9 +44,20 @@ const (
 // 	})
 // }
 
-var Store *session.Store = session.New(session.Config{
-	Expiration:     time.Hour,
-	CookieSameSite: "Lax",
-	CookiePath:     "/",
-	CookieHTTPOnly: true,
-})
+var Store *session.Store = newStore(nil)
+
+// keeps the sessions in storage instead of in memory, must be called before the server starts
+func UseStorage(storage fiber.Storage) {
+	Store = newStore(storage)
+}
+
+// a nil storage keeps the sessions in memory
+func newStore(storage fiber.Storage) *session.Store {
+	return session.New(session.Config{
+		Expiration:     time.Hour,
+		CookieSameSite: "Lax",
+		CookiePath:     "/",
+		CookieHTTPOnly: true,
+		Storage:        storage,
+	})
+}
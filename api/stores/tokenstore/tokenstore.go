@@ -1,7 +1,9 @@
 package tokenstore
 
 import (
+	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,8 +14,20 @@ var GlobalTokenStore *TokenStore
 var once sync.Once
 
 type TokenStore struct {
-	store map[string]TokenEntry
-	mutex sync.Mutex
+	store     map[string]TokenEntry
+	mutex     sync.Mutex
+	persister Persister
+	// sessions with unfinished conversions, only their tokens are persisted
+	persistedSessions map[string]bool
+}
+
+// keeps tokens across restarts so background conversions can resume
+type Persister interface {
+	SaveToken(name string, entry TokenEntry) error
+	LoadTokens() (map[string]TokenEntry, error)
+	// deletes the tokens whose name starts with prefix
+	DeleteTokens(prefix string) error
+	DeleteTokensExpiredBefore(before time.Time) error
 }
 
 type TokenEntry struct {
@@ -29,6 +43,7 @@ func init() {
 
 func NewTokenStore() *TokenStore {
 	return &TokenStore{
+		persistedSessions: map[string]bool{},
 
 		store: map[string]TokenEntry{
 			string(YOUTUBE_CC): {
@@ -42,12 +57,125 @@ func NewTokenStore() *TokenStore {
 	}
 }
 
+// loads the persisted tokens and persists every token set from now on
+func (ts *TokenStore) UsePersister(p Persister) error {
+	entries, err := p.LoadTokens()
+
+	if err != nil {
+		return err
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	for name, entry := range entries {
+		// the api key always comes from the environment
+		if name == string(YOUTUBE_CC) {
+			continue
+		}
+
+		ts.store[name] = entry
+
+		// the session still had unfinished conversions
+		if sessionId, ok := tokenSession(name); ok {
+			ts.persistedSessions[sessionId] = true
+		}
+	}
+
+	ts.persister = p
+
+	return nil
+}
+
 func (ts *TokenStore) SetToken(name string, token TokenEntry) {
 	ts.mutex.Lock()
 	defer ts.mutex.Unlock()
 
 	// oauth tokens with Authorization code flow will be prefixed with the server session id
 	ts.store[name] = token
+
+	if sessionId, ok := tokenSession(name); ok && ts.persistedSessions[sessionId] {
+		ts.persist(name, token)
+	}
+}
+
+/*
+persists the tokens of the session, and the ones it gets from now on, so its conversions can resume after a restart.
+called when the session starts a conversion
+*/
+func (ts *TokenStore) PersistSession(sessionId string) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if ts.persistedSessions[sessionId] {
+		return
+	}
+
+	ts.persistedSessions[sessionId] = true
+
+	for name, entry := range ts.store {
+		if strings.HasPrefix(name, sessionId+"_") {
+			ts.persist(name, entry)
+		}
+	}
+}
+
+// deletes the persisted tokens of the session once it has no unfinished conversions, the session keeps using them until it expires
+func (ts *TokenStore) ReleaseSession(sessionId string) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	delete(ts.persistedSessions, sessionId)
+
+	if ts.persister == nil {
+		return
+	}
+
+	if err := ts.persister.DeleteTokens(sessionId + "_"); err != nil {
+		log.Println("error deleting persisted tokens - " + err.Error())
+	}
+}
+
+// deletes the tokens expired before the time, the api key from the environment never expires
+func (ts *TokenStore) DeleteExpired(before time.Time) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	for name, entry := range ts.store {
+		if name != string(YOUTUBE_CC) && entry.Expiration.Before(before) {
+			delete(ts.store, name)
+		}
+	}
+
+	if ts.persister == nil {
+		return
+	}
+
+	if err := ts.persister.DeleteTokensExpiredBefore(before); err != nil {
+		log.Println("error deleting expired tokens - " + err.Error())
+	}
+}
+
+// caller must hold ts.mutex
+func (ts *TokenStore) persist(name string, token TokenEntry) {
+	if ts.persister == nil {
+		return
+	}
+
+	if err := ts.persister.SaveToken(name, token); err != nil {
+		log.Println("error persisting token - " + err.Error())
+	}
+}
+
+// tokens of a session are named "{session id}_{token name}", client tokens have no session
+func tokenSession(name string) (string, bool) {
+	for _, tokenName := range sessionTokenNames {
+		if strings.HasSuffix(name, "_"+string(tokenName)) {
+			return strings.TrimSuffix(name, "_"+string(tokenName)), true
+		}
+	}
+
+	return "", false
 }
 
 func (ts *TokenStore) GetToken(name string) (*oauth2.Token, bool) {
@@ -0,0 +1,148 @@
+package tokenstore
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type memoryPersister struct {
+	tokens map[string]TokenEntry
+}
+
+func (p *memoryPersister) SaveToken(name string, entry TokenEntry) error {
+	p.tokens[name] = entry
+	return nil
+}
+
+func (p *memoryPersister) LoadTokens() (map[string]TokenEntry, error) {
+	entries := map[string]TokenEntry{}
+	for name, entry := range p.tokens {
+		entries[name] = entry
+	}
+
+	return entries, nil
+}
+
+func (p *memoryPersister) DeleteTokens(prefix string) error {
+	for name := range p.tokens {
+		if strings.HasPrefix(name, prefix) {
+			delete(p.tokens, name)
+		}
+	}
+
+	return nil
+}
+
+func (p *memoryPersister) DeleteTokensExpiredBefore(before time.Time) error {
+	for name, entry := range p.tokens {
+		if entry.Expiration.Before(before) {
+			delete(p.tokens, name)
+		}
+	}
+
+	return nil
+}
+
+func tokenEntry(expiration time.Time) TokenEntry {
+	return TokenEntry{Token: &oauth2.Token{AccessToken: "token"}, Expiration: expiration}
+}
+
+func TestOnlyTokensOfSessionsWithConversionsArePersisted(t *testing.T) {
+	persister := &memoryPersister{tokens: map[string]TokenEntry{}}
+	ts := NewTokenStore()
+
+	if err := ts.UsePersister(persister); err != nil {
+		t.Fatal(err)
+	}
+
+	expiration := time.Now().Add(time.Hour)
+
+	ts.SetToken("converting_"+string(SPOTIFY_AC), tokenEntry(expiration))
+	ts.SetToken("idle_"+string(SPOTIFY_AC), tokenEntry(expiration))
+	ts.SetToken(string(SPOTIFY_CC), tokenEntry(expiration))
+
+	if len(persister.tokens) != 0 {
+		t.Fatalf("persisted %d tokens before any conversion", len(persister.tokens))
+	}
+
+	ts.PersistSession("converting")
+
+	if _, ok := persister.tokens["converting_"+string(SPOTIFY_AC)]; !ok || len(persister.tokens) != 1 {
+		t.Fatalf("persisted %v, expected only the token of the converting session", persister.tokens)
+	}
+
+	// refreshed tokens of the session are persisted too
+	ts.SetToken("converting_"+string(TIDAL_AC), tokenEntry(expiration))
+
+	if len(persister.tokens) != 2 {
+		t.Fatalf("persisted %d tokens, expected 2", len(persister.tokens))
+	}
+
+	ts.ReleaseSession("converting")
+
+	if len(persister.tokens) != 0 {
+		t.Fatalf("%d tokens left after the session released them", len(persister.tokens))
+	}
+
+	// the session keeps its tokens in memory
+	if !ts.IsTokenValid("converting_" + string(SPOTIFY_AC)) {
+		t.Error("released token is no longer valid")
+	}
+}
+
+func TestPersistedSessionsAreRestored(t *testing.T) {
+	persister := &memoryPersister{tokens: map[string]TokenEntry{
+		"restored_" + string(DEEZER_AC): tokenEntry(time.Now().Add(time.Hour)),
+	}}
+
+	ts := NewTokenStore()
+
+	if err := ts.UsePersister(persister); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ts.IsTokenValid("restored_" + string(DEEZER_AC)) {
+		t.Fatal("persisted token not loaded")
+	}
+
+	ts.SetToken("restored_"+string(DEEZER_AC), tokenEntry(time.Now().Add(2*time.Hour)))
+
+	if entry := persister.tokens["restored_"+string(DEEZER_AC)]; time.Until(entry.Expiration) < time.Hour {
+		t.Error("refreshed token of a restored session not persisted")
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	persister := &memoryPersister{tokens: map[string]TokenEntry{}}
+	ts := NewTokenStore()
+
+	if err := ts.UsePersister(persister); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.PersistSession("session")
+	ts.SetToken("session_"+string(SPOTIFY_AC), tokenEntry(time.Now().Add(-48*time.Hour)))
+	ts.SetToken("session_"+string(TIDAL_AC), tokenEntry(time.Now().Add(-time.Hour)))
+
+	ts.DeleteExpired(time.Now().Add(-24 * time.Hour))
+
+	if token, _ := ts.GetToken("session_" + string(SPOTIFY_AC)); token != nil {
+		t.Error("token expired before the retention still stored")
+	}
+
+	// expired tokens are still returned so they can be refreshed
+	if token, _ := ts.GetToken("session_" + string(TIDAL_AC)); token == nil {
+		t.Error("token expired within the retention deleted")
+	}
+
+	if _, ok := persister.tokens["session_"+string(SPOTIFY_AC)]; ok || len(persister.tokens) != 1 {
+		t.Errorf("persisted %v, expected only the tidal token", persister.tokens)
+	}
+
+	if !ts.IsTokenValid(string(YOUTUBE_CC)) {
+		t.Error("api key deleted")
+	}
+}
@@ -8,4 +8,25 @@ const (
 
 	YOUTUBE_CC TokenName = "youtube_client_token"
 	YOUTUBE_AC TokenName = "youtube_authorization_code_token"
+
+	DEEZER_AC TokenName = "deezer_authorization_code_token"
+
+	SOUNDCLOUD_CC TokenName = "soundcloud_client_token"
+	SOUNDCLOUD_AC TokenName = "soundcloud_authorization_code_token"
+
+	// salted token of the subsonic user, the password isn't stored
+	SUBSONIC_UC TokenName = "subsonic_user_credentials"
+
+	TIDAL_CC TokenName = "tidal_client_token"
+	// obtained with the device code flow
+	TIDAL_AC TokenName = "tidal_authorization_code_token"
+
+	// signed by the server, the music user token comes from MusicKit JS
+	APPLE_MUSIC_DT TokenName = "apple_music_developer_token"
+	APPLE_MUSIC_UT TokenName = "apple_music_user_token"
 )
+
+// tokens stored per session, the other tokens are shared by the server
+var sessionTokenNames = []TokenName{
+	SPOTIFY_AC, YOUTUBE_AC, DEEZER_AC, SOUNDCLOUD_AC, SUBSONIC_UC, TIDAL_AC, APPLE_MUSIC_UT,
+}
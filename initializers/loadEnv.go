@@ -1,14 +1,21 @@
 package initializers
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 
 	"github.com/joho/godotenv"
 )
 
+// loads internal/env/.env, without the file the variables are read from the process environment
 func LoadEnv() {
 	err := godotenv.Load("internal/env/.env")
 
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
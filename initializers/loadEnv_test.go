@@ -0,0 +1,57 @@
+package initializers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a missing .env is silent, the variables set in the process environment are used as they are
+func TestLoadEnvWithoutFile(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("LOAD_ENV_TEST", "from process")
+
+	LoadEnv()
+
+	if got := os.Getenv("LOAD_ENV_TEST"); got != "from process" {
+		t.Errorf("LOAD_ENV_TEST = %q, want the process value", got)
+	}
+}
+
+func TestLoadEnvFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "internal", "env"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "internal", "env", ".env"), []byte("LOAD_ENV_FILE_TEST=from file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	chdir(t, dir)
+	t.Setenv("LOAD_ENV_FILE_TEST", "")
+	os.Unsetenv("LOAD_ENV_FILE_TEST")
+
+	LoadEnv()
+
+	if got := os.Getenv("LOAD_ENV_FILE_TEST"); got != "from file" {
+		t.Errorf("LOAD_ENV_FILE_TEST = %q, want the .env value", got)
+	}
+}
+
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+}